@@ -0,0 +1,219 @@
+package chaincfg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// decodeSegwitAddress 按 BIP173 / BIP350 解码隔离见证地址，v0 使用 bech32，v1 及以上使用 bech32m
+func decodeSegwitAddress(hrp, address string) (byte, []byte, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return 0, nil, fmt.Errorf("mixed case segwit address %s", address)
+	}
+	if len(address) > 90 {
+		return 0, nil, fmt.Errorf("segwit address too long: %s", address)
+	}
+	lower := strings.ToLower(address)
+	sep := strings.LastIndexByte(lower, '1')
+	if lower[:sep] != hrp || len(lower)-sep-1 < 7 {
+		return 0, nil, fmt.Errorf("invalid segwit address %s", address)
+	}
+	data, err := decodeBase32(lower[sep+1:])
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid segwit address %s: %w", address, err)
+	}
+	version := data[0]
+	checksum := bech32Polymod(append(bech32HrpExpand(hrp), data...))
+	if (version == 0 && checksum != bech32Const) || (version > 0 && checksum != bech32mConst) {
+		return 0, nil, fmt.Errorf("invalid checksum for segwit address %s", address)
+	}
+	if version > 16 {
+		return 0, nil, fmt.Errorf("invalid witness version %d in %s", version, address)
+	}
+	program, err := convertBits(data[1:len(data)-6], 5, 8)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid segwit address %s: %w", address, err)
+	}
+	if len(program) < 2 || len(program) > 40 || (version == 0 && len(program) != 20 && len(program) != 32) {
+		return 0, nil, fmt.Errorf("invalid witness program length %d in %s", len(program), address)
+	}
+	return version, program, nil
+}
+
+func cashAddrPolymod(values []byte) uint64 {
+	generator := [5]uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+	chk := uint64(1)
+	for _, v := range values {
+		top := chk >> 35
+		chk = (chk&0x07ffffffff)<<5 ^ uint64(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk ^ 1
+}
+
+// cashAddrPayloadLen 20 字节 hash 的 CashAddr 地址去掉前缀后的长度：版本字节和 hash 共 34 个字符，校验和 8 个字符
+const cashAddrPayloadLen = 42
+
+// isCashAddrPayload 判断省略前缀的地址是否符合 CashAddr 的长度和字符集，旧地址格式不会满足
+func isCashAddrPayload(address string) bool {
+	lower := strings.ToLower(address)
+	if len(lower) != cashAddrPayloadLen || (lower[0] != 'q' && lower[0] != 'p') {
+		return false
+	}
+	if lower != address && strings.ToUpper(address) != address {
+		return false
+	}
+	for _, c := range lower {
+		if !strings.ContainsRune(bech32Charset, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeCashAddress 解码 Bitcoin Cash 的 CashAddr 地址，前缀可以省略，返回是否为 P2SH 地址
+func decodeCashAddress(prefix, address string) (bool, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return false, fmt.Errorf("mixed case cashaddr %s", address)
+	}
+	lower := strings.ToLower(address)
+	if addrPrefix, payload, ok := strings.Cut(lower, ":"); ok {
+		if addrPrefix != prefix {
+			return false, fmt.Errorf("cashaddr %s does not have prefix %s", address, prefix)
+		}
+		lower = payload
+	}
+	data, err := decodeBase32(lower)
+	if err != nil {
+		return false, fmt.Errorf("invalid cashaddr %s: %w", address, err)
+	}
+	if len(data) <= 8 {
+		return false, fmt.Errorf("invalid cashaddr %s", address)
+	}
+	values := make([]byte, 0, len(prefix)+1+len(data))
+	for i := 0; i < len(prefix); i++ {
+		values = append(values, prefix[i]&31)
+	}
+	values = append(values, 0)
+	if cashAddrPolymod(append(values, data...)) != 0 {
+		return false, fmt.Errorf("invalid checksum for cashaddr %s", address)
+	}
+	payload, err := convertBits(data[:len(data)-8], 5, 8)
+	if err != nil {
+		return false, fmt.Errorf("invalid cashaddr %s: %w", address, err)
+	}
+	// 版本字节高 4 位为类型，低 3 位为 hash 长度，只支持 20 字节 hash
+	if len(payload) != 21 || payload[0]&0x07 != 0 {
+		return false, fmt.Errorf("unsupported cashaddr %s", address)
+	}
+	switch payload[0] >> 3 {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported cashaddr type in %s", address)
+}
+
+func decodeBase32(data string) ([]byte, error) {
+	result := make([]byte, 0, len(data))
+	for _, c := range data {
+		index := strings.IndexRune(bech32Charset, c)
+		if index < 0 {
+			return nil, fmt.Errorf("invalid base32 character %q", c)
+		}
+		result = append(result, byte(index))
+	}
+	return result, nil
+}
+
+// convertBits 5 位分组转 8 位分组，剩余的填充位必须为 0
+func convertBits(data []byte, from, to uint) ([]byte, error) {
+	var (
+		acc    uint32
+		bits   uint
+		result []byte
+	)
+	maxValue := uint32(1)<<to - 1
+	for _, v := range data {
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+	if bits >= from || (acc<<(to-bits))&maxValue != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return result, nil
+}
+
+// decodeBase58Check 解码 base58check 编码的地址，返回版本字节
+func decodeBase58Check(address string) (byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range address {
+		index := strings.IndexRune(base58Alphabet, c)
+		if index < 0 {
+			return 0, fmt.Errorf("invalid base58 character %q", c)
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(index)))
+	}
+	leadingZeros := len(address) - len(strings.TrimLeft(address, "1"))
+	decoded := append(make([]byte, leadingZeros), value.Bytes()...)
+	// 版本字节 + 20 字节 hash + 4 字节校验和
+	if len(decoded) != 25 {
+		return 0, fmt.Errorf("invalid address length %d", len(decoded))
+	}
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], decoded[21:]) {
+		return 0, errors.New("invalid checksum")
+	}
+	return decoded[0], nil
+}
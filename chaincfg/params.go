@@ -0,0 +1,326 @@
+package chaincfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
+)
+
+const (
+	Bitcoin     = "Bitcoin"
+	Litecoin    = "Litecoin"
+	Dogecoin    = "Dogecoin"
+	BitcoinCash = "BitcoinCash"
+)
+
+const (
+	MainNet = "mainnet"
+	TestNet = "testnet"
+	SigNet  = "signet"
+	RegTest = "regtest"
+)
+
+// Params 不同链、不同网络的地址格式、创世区块、粉尘值、确认数和费率默认值，
+// 金额和费率都以链的最小单位（聪、litoshi、koinu）计
+type Params struct {
+	Chain   string
+	Coin    string
+	Network string
+	// Bech32HRP 隔离见证地址前缀，为空时链不支持隔离见证
+	Bech32HRP string
+	// CashAddrPrefix Bitcoin Cash 的 CashAddr 地址前缀
+	CashAddrPrefix    string
+	PubKeyHashAddrID  byte
+	ScriptHashAddrIDs []byte
+	GenesisHash       string
+	// DustLimit 低于该金额的输出不会被节点转发，找零低于粉尘值时并入手续费
+	DustLimit int64
+	// DefaultConfirmations 没有配置确认数时使用，按出块时间折算成与比特币 64 个确认相近的时长
+	DefaultConfirmations uint
+	// MinFeeRate 节点默认的最低转发费率（最小单位/虚拟字节），不支持隔离见证的链按字节计
+	MinFeeRate int64
+	// FallbackFeeRate 上游无法估算费率时使用的费率，为 0 时不回退；
+	// 测试网络交易少，节点经常估算不出费率
+	FallbackFeeRate int64
+	// ReplaceByFee 节点是否接受 BIP125 替换交易，Bitcoin Cash 不支持
+	ReplaceByFee bool
+}
+
+var (
+	MainNetParams = Params{
+		Chain:                Bitcoin,
+		Coin:                 "BTC",
+		Network:              MainNet,
+		Bech32HRP:            "bc",
+		PubKeyHashAddrID:     0x00,
+		ScriptHashAddrIDs:    []byte{0x05},
+		GenesisHash:          "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		ReplaceByFee:         true,
+	}
+	TestNetParams = Params{
+		Chain:                Bitcoin,
+		Coin:                 "BTC",
+		Network:              TestNet,
+		Bech32HRP:            "tb",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+		ReplaceByFee:         true,
+	}
+	SigNetParams = Params{
+		Chain:                Bitcoin,
+		Coin:                 "BTC",
+		Network:              SigNet,
+		Bech32HRP:            "tb",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6",
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+		ReplaceByFee:         true,
+	}
+	RegTestParams = Params{
+		Chain:                Bitcoin,
+		Coin:                 "BTC",
+		Network:              RegTest,
+		Bech32HRP:            "bcrt",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+		ReplaceByFee:         true,
+	}
+
+	// 莱特币出块时间 2.5 分钟，P2SH 地址同时兼容旧的 3 开头格式
+	LitecoinMainNetParams = Params{
+		Chain:                Litecoin,
+		Coin:                 "LTC",
+		Network:              MainNet,
+		Bech32HRP:            "ltc",
+		PubKeyHashAddrID:     0x30,
+		ScriptHashAddrIDs:    []byte{0x32, 0x05},
+		GenesisHash:          "12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2",
+		DustLimit:            5460,
+		DefaultConfirmations: 256,
+		MinFeeRate:           1,
+		ReplaceByFee:         true,
+	}
+	LitecoinTestNetParams = Params{
+		Chain:                Litecoin,
+		Coin:                 "LTC",
+		Network:              TestNet,
+		Bech32HRP:            "tltc",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0x3a, 0xc4},
+		GenesisHash:          "4966625a4b2851d9fdee139e56211a0d88575f59ed816ff5e6a63deb4e3e29a0",
+		DustLimit:            5460,
+		DefaultConfirmations: 256,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+		ReplaceByFee:         true,
+	}
+	LitecoinRegTestParams = Params{
+		Chain:                Litecoin,
+		Coin:                 "LTC",
+		Network:              RegTest,
+		Bech32HRP:            "rltc",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0x3a, 0xc4},
+		GenesisHash:          "530827f38f93b43ed12af0b3ad25a288dc02ed74d6d7857862df51fc56c416f9",
+		DustLimit:            5460,
+		DefaultConfirmations: 256,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+		ReplaceByFee:         true,
+	}
+
+	// 狗狗币出块时间 1 分钟，不支持隔离见证，默认最低转发费率为 0.01 DOGE/kB，粉尘值为 0.01 DOGE
+	DogecoinMainNetParams = Params{
+		Chain:                Dogecoin,
+		Coin:                 "DOGE",
+		Network:              MainNet,
+		PubKeyHashAddrID:     0x1e,
+		ScriptHashAddrIDs:    []byte{0x16},
+		GenesisHash:          "1a91e3dace36e2be3bf030a65679fe821aa1d6ef92e7c9902eb318182c355691",
+		DustLimit:            1_000_000,
+		DefaultConfirmations: 640,
+		MinFeeRate:           1000,
+		FallbackFeeRate:      1000,
+		ReplaceByFee:         true,
+	}
+	DogecoinTestNetParams = Params{
+		Chain:                Dogecoin,
+		Coin:                 "DOGE",
+		Network:              TestNet,
+		PubKeyHashAddrID:     0x71,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          "bb0a78264637406b6360aad926284d544d7049f45189db5664f3c4d07350559e",
+		DustLimit:            1_000_000,
+		DefaultConfirmations: 640,
+		MinFeeRate:           1000,
+		FallbackFeeRate:      1000,
+		ReplaceByFee:         true,
+	}
+	DogecoinRegTestParams = Params{
+		Chain:                Dogecoin,
+		Coin:                 "DOGE",
+		Network:              RegTest,
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          "3d2160a3b5dc4a9d62e7e66a295f70313ac808440ef7400d6c0772171ce973a5",
+		DustLimit:            1_000_000,
+		DefaultConfirmations: 640,
+		MinFeeRate:           1000,
+		FallbackFeeRate:      1000,
+		ReplaceByFee:         true,
+	}
+
+	// Bitcoin Cash 从比特币分叉，创世区块相同，不支持隔离见证，同时接受 CashAddr 和旧地址格式
+	BitcoinCashMainNetParams = Params{
+		Chain:                BitcoinCash,
+		Coin:                 "BCH",
+		Network:              MainNet,
+		CashAddrPrefix:       "bitcoincash",
+		PubKeyHashAddrID:     0x00,
+		ScriptHashAddrIDs:    []byte{0x05},
+		GenesisHash:          MainNetParams.GenesisHash,
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+	}
+	BitcoinCashTestNetParams = Params{
+		Chain:                BitcoinCash,
+		Coin:                 "BCH",
+		Network:              TestNet,
+		CashAddrPrefix:       "bchtest",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          TestNetParams.GenesisHash,
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+	}
+	BitcoinCashRegTestParams = Params{
+		Chain:                BitcoinCash,
+		Coin:                 "BCH",
+		Network:              RegTest,
+		CashAddrPrefix:       "bchreg",
+		PubKeyHashAddrID:     0x6f,
+		ScriptHashAddrIDs:    []byte{0xc4},
+		GenesisHash:          RegTestParams.GenesisHash,
+		DustLimit:            546,
+		DefaultConfirmations: 64,
+		MinFeeRate:           1,
+		FallbackFeeRate:      1,
+	}
+)
+
+var registeredParams = map[string][]*Params{
+	Bitcoin:     {&MainNetParams, &TestNetParams, &SigNetParams, &RegTestParams},
+	Litecoin:    {&LitecoinMainNetParams, &LitecoinTestNetParams, &LitecoinRegTestParams},
+	Dogecoin:    {&DogecoinMainNetParams, &DogecoinTestNetParams, &DogecoinRegTestParams},
+	BitcoinCash: {&BitcoinCashMainNetParams, &BitcoinCashTestNetParams, &BitcoinCashRegTestParams},
+}
+
+// Chains 支持的链
+func Chains() []string {
+	chains := make([]string, 0, len(registeredParams))
+	for chain := range registeredParams {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	return chains
+}
+
+func ParamsFor(chain, network string) (*Params, error) {
+	networks, ok := registeredParams[chain]
+	if !ok {
+		return nil, fmt.Errorf("unknown chain %q, supported chains: %s", chain, strings.Join(Chains(), ", "))
+	}
+	for _, params := range networks {
+		if params.Network == network {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q of %s", network, chain)
+}
+
+// Segwit 链是否支持隔离见证
+func (p *Params) Segwit() bool {
+	return p.Bech32HRP != ""
+}
+
+// ValidateAddress 校验地址的编码、校验和以及是否属于当前链和网络
+func (p *Params) ValidateAddress(address string) error {
+	_, err := p.ScriptType(address)
+	return err
+}
+
+// ScriptType 解析地址得到输出脚本类型，用于估算交易大小；
+// P2SH 地址无法区分赎回脚本，按 P2SH-P2WPKH 处理
+func (p *Params) ScriptType(address string) (txfee.ScriptType, error) {
+	lower := strings.ToLower(address)
+	if hrp, _, ok := strings.Cut(lower, "1"); ok && p.Segwit() && hrp == p.Bech32HRP {
+		version, program, err := decodeSegwitAddress(p.Bech32HRP, address)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case version == 0 && len(program) == 20:
+			return txfee.P2WPKH, nil
+		case version == 0 && len(program) == 32:
+			return txfee.P2WSH, nil
+		case version == 1 && len(program) == 32:
+			return txfee.P2TR, nil
+		}
+		return "", fmt.Errorf("unsupported segwit address: %s", address)
+	}
+	if p.CashAddrPrefix != "" && strings.HasPrefix(lower, p.CashAddrPrefix+":") {
+		return p.cashAddrScriptType(address)
+	}
+	// 省略前缀的 CashAddr 地址先按长度和字符集判断，解码失败时按旧地址格式解析
+	if p.CashAddrPrefix != "" && isCashAddrPayload(address) {
+		if scriptType, err := p.cashAddrScriptType(address); err == nil {
+			return scriptType, nil
+		}
+	}
+	version, err := decodeBase58Check(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	if version == p.PubKeyHashAddrID {
+		return txfee.P2PKH, nil
+	}
+	for _, id := range p.ScriptHashAddrIDs {
+		if version == id {
+			return txfee.P2SHP2WPKH, nil
+		}
+	}
+	return "", fmt.Errorf("address %s does not belong to %s %s", address, p.Chain, p.Network)
+}
+
+func (p *Params) cashAddrScriptType(address string) (txfee.ScriptType, error) {
+	isScriptHash, err := decodeCashAddress(p.CashAddrPrefix, address)
+	if err != nil {
+		return "", err
+	}
+	if isScriptHash {
+		return txfee.P2SHP2WPKH, nil
+	}
+	return txfee.P2PKH, nil
+}
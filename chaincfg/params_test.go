@@ -0,0 +1,123 @@
+package chaincfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
+)
+
+func TestValidateAddress(t *testing.T) {
+	cases := []struct {
+		params  *Params
+		address string
+		valid   bool
+	}{
+		{&MainNetParams, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", true},
+		{&MainNetParams, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", true},
+		{&MainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", true},
+		{&MainNetParams, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", true},
+		{&MainNetParams, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", true},
+		// 校验和错误、大小写混用、v1 使用 bech32 而不是 bech32m
+		{&MainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", false},
+		{&MainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7KV8F3T4", false},
+		{&MainNetParams, "bc1pqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sagmhkq", false},
+		{&MainNetParams, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", false},
+		// 其他网络的地址
+		{&MainNetParams, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", false},
+		{&MainNetParams, "mfcHP2WMCVLsVZA8yrovmhMgxNFW9r98xw", false},
+
+		{&TestNetParams, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", true},
+		{&TestNetParams, "tb1pqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0slua5fd", true},
+		{&TestNetParams, "mfcHP2WMCVLsVZA8yrovmhMgxNFW9r98xw", true},
+		{&TestNetParams, "2MsLZ5FqqYpjM1Q1W4X81zMVZTF9gdbhVwd", true},
+		{&TestNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false},
+		{&TestNetParams, "16L5yRNPTuciSgXGHqYwn9N6NeoKqopAu", false},
+		{&SigNetParams, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", true},
+
+		{&RegTestParams, "bcrt1qqypqxpq9qcrsszg2pvxq6rs0zqg3yyc5phstwt", true},
+		{&RegTestParams, "mfcHP2WMCVLsVZA8yrovmhMgxNFW9r98xw", true},
+		{&RegTestParams, "tb1qqypqxpq9qcrsszg2pvxq6rs0zqg3yyc5r7fxez", false},
+
+		{&LitecoinMainNetParams, "ltc1qqypqxpq9qcrsszg2pvxq6rs0zqg3yyc5dyg36p", true},
+		{&LitecoinMainNetParams, "LKKHMBjCU89fyFNgSRprDoD8Jb25N8uWvd", true},
+		{&LitecoinMainNetParams, "M7zVKQKmtV5Rc7erVGVVC3khZbXxsS5HEX", true},
+		{&LitecoinMainNetParams, "31nM1WuowNDzocNxPPW9NQWJEtwWpjfcLj", true},
+		{&LitecoinMainNetParams, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", false},
+		{&LitecoinMainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false},
+		{&LitecoinTestNetParams, "tltc1qqypqxpq9qcrsszg2pvxq6rs0zqg3yyc56ktcft", true},
+		{&LitecoinTestNetParams, "QLhKCGi5ZvnS9amYgdA353vzbdbWYBoxD8", true},
+		{&LitecoinTestNetParams, "ltc1qqypqxpq9qcrsszg2pvxq6rs0zqg3yyc5dyg36p", false},
+
+		{&DogecoinMainNetParams, "D5ERdEN1gsouFSs7zsq7VYJxyWP6dP28H1", true},
+		{&DogecoinMainNetParams, "9rXbkMyi1S6thykRoXAZcY8fwUKYsy6cXE", true},
+		{&DogecoinMainNetParams, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", false},
+		{&DogecoinTestNetParams, "nUHVMF6vcrGd8RSK2hUZjwuGDNmPeNoBRb", true},
+		{&DogecoinTestNetParams, "D5ERdEN1gsouFSs7zsq7VYJxyWP6dP28H1", false},
+
+		{&BitcoinCashMainNetParams, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", true},
+		{&BitcoinCashMainNetParams, "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", true},
+		{&BitcoinCashMainNetParams, "bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", true},
+		{&BitcoinCashMainNetParams, "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", true},
+		{&BitcoinCashMainNetParams, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6b", false},
+		{&BitcoinCashMainNetParams, "bchtest:qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs0pajhc3j", false},
+		{&BitcoinCashMainNetParams, "qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs0pajhc3j", false},
+		{&BitcoinCashMainNetParams, "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6", false},
+		{&BitcoinCashTestNetParams, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false},
+		{&BitcoinCashTestNetParams, "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false},
+		{&BitcoinCashMainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false},
+		{&BitcoinCashTestNetParams, "bchtest:qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs0pajhc3j", true},
+		{&BitcoinCashRegTestParams, "bchreg:pqqsyqcyq5rqwzqfpg9scrgwpugpzysnzszckungff", true},
+	}
+	for _, c := range cases {
+		err := c.params.ValidateAddress(c.address)
+		if c.valid {
+			require.NoError(t, err, "%s %s %s", c.params.Chain, c.params.Network, c.address)
+		} else {
+			require.Error(t, err, "%s %s %s", c.params.Chain, c.params.Network, c.address)
+		}
+	}
+}
+
+func TestScriptType(t *testing.T) {
+	cases := []struct {
+		params     *Params
+		address    string
+		scriptType txfee.ScriptType
+	}{
+		{&MainNetParams, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", txfee.P2PKH},
+		{&MainNetParams, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", txfee.P2SHP2WPKH},
+		{&MainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", txfee.P2WPKH},
+		{&MainNetParams, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", txfee.P2TR},
+		{&LitecoinMainNetParams, "M7zVKQKmtV5Rc7erVGVVC3khZbXxsS5HEX", txfee.P2SHP2WPKH},
+		{&BitcoinCashMainNetParams, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", txfee.P2PKH},
+		{&BitcoinCashMainNetParams, "bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", txfee.P2SHP2WPKH},
+		{&BitcoinCashMainNetParams, "ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", txfee.P2SHP2WPKH},
+		{&BitcoinCashMainNetParams, "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", txfee.P2PKH},
+		{&BitcoinCashMainNetParams, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", txfee.P2SHP2WPKH},
+	}
+	for _, c := range cases {
+		scriptType, err := c.params.ScriptType(c.address)
+		require.NoError(t, err, c.address)
+		require.Equal(t, c.scriptType, scriptType, c.address)
+	}
+}
+
+func TestParamsFor(t *testing.T) {
+	params, err := ParamsFor(Bitcoin, RegTest)
+	require.NoError(t, err)
+	require.Equal(t, "bcrt", params.Bech32HRP)
+
+	params, err = ParamsFor(Dogecoin, MainNet)
+	require.NoError(t, err)
+	require.Equal(t, "DOGE", params.Coin)
+	require.False(t, params.Segwit())
+
+	_, err = ParamsFor(Bitcoin, "testnet3")
+	require.Error(t, err)
+	_, err = ParamsFor(Dogecoin, SigNet)
+	require.Error(t, err)
+	_, err = ParamsFor("Ethereum", MainNet)
+	require.Error(t, err)
+}
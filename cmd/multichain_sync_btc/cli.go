@@ -3,21 +3,22 @@ package main
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
+	multichain_sync_btc "github.com/0xshin-chan/multichain-sync-btc"
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
 	"github.com/0xshin-chan/multichain-sync-btc/common/cliapp"
 	"github.com/0xshin-chan/multichain-sync-btc/common/opio"
 	"github.com/0xshin-chan/multichain-sync-btc/config"
 	"github.com/0xshin-chan/multichain-sync-btc/database"
 	flags2 "github.com/0xshin-chan/multichain-sync-btc/flags"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
-	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
 	"github.com/0xshin-chan/multichain-sync-btc/services"
+	"github.com/0xshin-chan/multichain-sync-btc/worker"
 )
 
 const (
@@ -64,19 +65,237 @@ func runRpc(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycl
 		return nil, err
 	}
 
-	log.Info("Chain utxo rpc", "rpc url", cfg.ChainBtcRpc)
-	conn, err := grpc.NewClient(cfg.ChainBtcRpc, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// 导出地址和构建交易依赖上游钱包服务
+	chains, err := newChainClients(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	var chainServices []services.ChainService
+	for _, chain := range chains {
+		chainDB := db.WithChain(chain.Config.ChainNode.ChainName)
+		chainServices = append(chainServices, services.ChainService{
+			SyncClient:    chain.RpcClient,
+			ChainSource:   chain.ChainSource,
+			Rescanner:     worker.NewRescanner(ctx.Context, chain.Config, chainDB, chain.ChainSource),
+			RbfMaxFeeRate: chain.Config.ChainNode.RbfMaxFeeRate,
+		})
+	}
+	return services.NewBusinessMiddleWareService(db, grpcServerCfg, chainServices)
+}
+
+func runRescan(ctx *cli.Context) error {
+	ctx.Context = opio.CancelOnInterrupt(ctx.Context)
+	log.Info("running rescan...")
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "error", err)
+		return err
+	}
+	db, err := database.NewDB(ctx.Context, cfg.MasterDB)
+	if err != nil {
+		log.Error("failed to open database", "error", err)
+		return err
+	}
+	defer func(db *database.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Error("failed to close database", "error", err)
+		}
+	}(db)
+	// 指定业务方时在业务方注册的链上重扫，否则重扫配置的链上所有业务方
+	businessId := ctx.String("business")
+	chain := cfg.ChainNode.ChainName
+	if businessId != "" {
+		business, err := db.Business.QueryBusinessByUuid(businessId)
+		if err != nil {
+			return fmt.Errorf("query business %s fail: %w", businessId, err)
+		}
+		chain = business.Chain
+	} else if len(cfg.Chains) > 1 {
+		return fmt.Errorf("rescan of all businesses requires a single chain, got %v", cfg.Chains)
+	}
+	chainCfg, err := cfg.ForChain(chain)
+	if err != nil {
+		return err
+	}
+	params, err := chaincfg.ParamsFor(chain, chainCfg.ChainNode.Network)
+	if err != nil {
+		return err
+	}
+	// 只有上游钱包服务作为扫块数据源时才需要连接
+	var utxoClient *syncclient.WalletBtcAccountClient
+	if chainCfg.ChainNode.ChainSource == syncclient.ChainSourceWallet {
+		utxoClient, err = newUtxoClient(chainCfg, params)
+		if err != nil {
+			return err
+		}
+	}
+	chainSource, err := newChainSource(chainCfg, params, utxoClient)
+	if err != nil {
+		return err
+	}
+	rescanner := worker.NewRescanner(ctx.Context, chainCfg, db.WithChain(chain), chainSource)
+	return rescanner.Rescan(businessId, ctx.Uint64("from"), ctx.Uint64("to"))
+}
+
+func runMultichainSync(ctx *cli.Context, shutdown context.CancelCauseFunc) (cliapp.Lifecycle, error) {
+	log.Info("running multichain sync indexer...")
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Error("failed to load config", "error", err)
+		return nil, err
+	}
+	// 自动加速提现需要上游钱包服务构建替换交易
+	chains, err := newChainClients(cfg, cfg.ChainNode.RbfAutoBump)
 	if err != nil {
-		log.Error("failed to new grpc client", "error", err)
 		return nil, err
 	}
-	client := utxo.NewWalletUtxoServiceClient(conn)
-	utxoClient, err := syncclient.NewWalletBtcAccountClient(context.Background(), client, "Bitcoin")
+	return multichain_sync_btc.NewMultiChainSync(ctx.Context, &cfg, chains, shutdown)
+}
+
+// newChainClients 为配置的每条链创建扫块数据源和内存池数据源，多条链共用同一组上游 WalletUtxoService；
+// 数据源不是上游钱包服务且 needWallet 为 false 时不连接上游钱包服务
+func newChainClients(cfg config.Config, needWallet bool) ([]multichain_sync_btc.ChainClients, error) {
+	var chains []multichain_sync_btc.ChainClients
+	for _, chain := range cfg.Chains {
+		chainCfg, err := cfg.ForChain(chain)
+		if err != nil {
+			return nil, err
+		}
+		params, err := chaincfg.ParamsFor(chainCfg.ChainNode.ChainName, chainCfg.ChainNode.Network)
+		if err != nil {
+			return nil, err
+		}
+		var utxoClient *syncclient.WalletBtcAccountClient
+		if needWallet || chainCfg.ChainNode.ChainSource == syncclient.ChainSourceWallet {
+			utxoClient, err = newUtxoClient(chainCfg, params)
+			if err != nil {
+				return nil, err
+			}
+		}
+		chainSource, err := newChainSource(chainCfg, params, utxoClient)
+		if err != nil {
+			return nil, err
+		}
+		mempoolSource, err := newMempoolSource(chainCfg, params, chainSource)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, multichain_sync_btc.ChainClients{
+			Config:        chainCfg,
+			Params:        params,
+			RpcClient:     utxoClient,
+			ChainSource:   chainSource,
+			MempoolSource: mempoolSource,
+		})
+	}
+	return chains, nil
+}
+
+// newUtxoClient 连接上游 WalletUtxoService，作为扫块数据源时校验创世区块
+func newUtxoClient(cfg config.Config, params *chaincfg.Params) (*syncclient.WalletBtcAccountClient, error) {
+	log.Info("Chain utxo rpc", "rpc url", cfg.ChainBtcRpc, "chain", cfg.ChainNode.ChainName, "network", cfg.ChainNode.Network)
+	utxoClient, err := syncclient.DialWalletBtcAccountClient(context.Background(), cfg.ChainBtcRpc, syncclient.FailoverConfig{
+		BreakerThreshold: cfg.ChainNode.RpcBreakerThreshold,
+		BreakerTimeout:   cfg.ChainNode.RpcBreakerTimeout,
+		HealthInterval:   cfg.ChainNode.RpcHealthInterval,
+		Quorum:           cfg.ChainNode.RpcQuorum,
+	}, cfg.ChainNode.ChainName, params)
 	if err != nil {
 		log.Error("failed to new grpc client", "error", err)
 		return nil, err
 	}
-	return services.NewBusinessMiddleWareService(db, grpcServerCfg, utxoClient)
+	if cfg.ChainNode.ChainSource != syncclient.ChainSourceWallet {
+		return utxoClient, nil
+	}
+	if err := verifyGenesis(params, utxoClient); err != nil {
+		_ = utxoClient.Close()
+		return nil, err
+	}
+	return utxoClient, nil
+}
+
+// newChainSource 扫块和广播使用的数据源，默认复用上游 WalletUtxoService
+func newChainSource(cfg config.Config, params *chaincfg.Params, utxoClient *syncclient.WalletBtcAccountClient) (syncclient.ChainSource, error) {
+	var (
+		source syncclient.ChainSource
+		err    error
+	)
+	switch cfg.ChainNode.ChainSource {
+	case syncclient.ChainSourceWallet:
+		return utxoClient, nil
+	case syncclient.ChainSourceBitcoind:
+		source, err = syncclient.NewBitcoindClient(cfg.ChainNode.BitcoindRpc, cfg.ChainNode.BitcoindRpcUser, cfg.ChainNode.BitcoindRpcPassword)
+	case syncclient.ChainSourceEsplora:
+		source, err = syncclient.NewEsploraClient(cfg.ChainNode.EsploraUrl)
+	default:
+		return nil, fmt.Errorf("unknown chain source %q", cfg.ChainNode.ChainSource)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyGenesis(params, source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// newMempoolSource 内存池充值检测使用的数据源，与扫块数据源相同时复用，关闭检测时返回 nil
+func newMempoolSource(cfg config.Config, params *chaincfg.Params, chainSource syncclient.ChainSource) (syncclient.MempoolSource, error) {
+	if cfg.ChainNode.MempoolSource == syncclient.MempoolSourceNone {
+		return nil, nil
+	}
+	if cfg.ChainNode.MempoolSource == cfg.ChainNode.ChainSource {
+		mempool, ok := chainSource.(syncclient.MempoolSource)
+		if !ok {
+			return nil, fmt.Errorf("chain source %s can not list mempool transactions", cfg.ChainNode.ChainSource)
+		}
+		return mempool, nil
+	}
+	var (
+		source interface {
+			syncclient.ChainSource
+			syncclient.MempoolSource
+		}
+		err error
+	)
+	switch cfg.ChainNode.MempoolSource {
+	case syncclient.ChainSourceBitcoind:
+		source, err = syncclient.NewBitcoindClient(cfg.ChainNode.BitcoindRpc, cfg.ChainNode.BitcoindRpcUser, cfg.ChainNode.BitcoindRpcPassword)
+	case syncclient.ChainSourceEsplora:
+		source, err = syncclient.NewEsploraClient(cfg.ChainNode.EsploraUrl)
+	default:
+		return nil, fmt.Errorf("mempool source %q can not list mempool transactions", cfg.ChainNode.MempoolSource)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyGenesis(params, source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// verifyGenesis 启动时确认数据源的创世区块与配置的网络一致，避免用测试网的数据源扫主网业务
+func verifyGenesis(params *chaincfg.Params, source syncclient.ChainSource) error {
+	tip, err := source.GetBlockHeader(nil)
+	if err != nil {
+		return fmt.Errorf("failed to query latest block: %w", err)
+	}
+	// 新建的 regtest 链只有创世区块，否则取高度 1 区块的父区块
+	genesisHash := tip.Hash
+	if tip.Number.Sign() > 0 {
+		header, err := source.GetBlockHeader(big.NewInt(1))
+		if err != nil {
+			return fmt.Errorf("failed to query block 1: %w", err)
+		}
+		genesisHash = header.PrevHash
+	}
+	if genesisHash != params.GenesisHash {
+		return fmt.Errorf("genesis block %s of chain source does not match %s %s genesis block %s", genesisHash, params.Chain, params.Network, params.GenesisHash)
+	}
+	log.Info("verified genesis block", "chain", params.Chain, "network", params.Network, "hash", genesisHash)
+	return nil
 }
 
 func NewCli(GitCommit string, GitData string) *cli.App {
@@ -107,6 +326,18 @@ func NewCli(GitCommit string, GitData string) *cli.App {
 				Description: "Run rpc services",
 				Action:      cliapp.LifecycleCmd(runRpc),
 			},
+			{
+				Name:        "rescan",
+				Flags:       append(append([]cli.Flag{}, flags...), flags2.RescanFlags...),
+				Description: "Re-process blocks [from, to] for one business or all businesses, picking up transactions that were missed or classified as unknown",
+				Action:      runRescan,
+			},
+			{
+				Name:        "index",
+				Flags:       flags,
+				Description: "Run deposit, withdraw, withdraw tracker, internal, fallback and notify workers",
+				Action:      cliapp.LifecycleCmd(runMultichainSync),
+			},
 		},
 	}
 }
@@ -0,0 +1,94 @@
+package coinselect
+
+const bnbMaxTries = 100000
+
+// BranchAndBound 参考 Bitcoin Core 的实现，搜索输入总额落在 [目标, 目标+找零成本] 区间的组合，
+// 命中时不需要找零输出；搜索不到时退化为从大到小累加并找零
+type BranchAndBound struct{}
+
+func (s *BranchAndBound) Select(utxos []Utxo, params Params) (*Selection, error) {
+	sorted := sortByAmountDesc(utxos)
+
+	// 每个 utxo 扣除自身输入手续费后的有效金额
+	var (
+		candidates []Utxo
+		effective  []int64
+		available  int64
+	)
+	for _, utxo := range sorted {
+		value := utxo.Amount - params.inputFee()
+		if value <= 0 {
+			continue
+		}
+		candidates = append(candidates, utxo)
+		effective = append(effective, value)
+		available += value
+	}
+
+	target := params.Target + params.BaseSize*params.FeeRate
+	// 找零成本：找零输出的手续费加上以后花费它的输入手续费
+	costOfChange := params.ChangeSize*params.FeeRate + params.inputFee()
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	if selected := branchAndBound(effective, target, costOfChange, available); selected != nil {
+		var inputs []Utxo
+		for _, i := range selected {
+			inputs = append(inputs, candidates[i])
+		}
+		return params.finish(inputs)
+	}
+	return params.accumulate(sorted)
+}
+
+// branchAndBound 深度优先搜索，返回浪费最少的组合下标，找不到返回 nil
+func branchAndBound(values []int64, target, costOfChange, available int64) []int {
+	var (
+		best      []int
+		bestWaste int64 = -1
+		current   []int
+		total     int64
+		remaining = available
+		tries     int
+	)
+
+	var search func(depth int)
+	search = func(depth int) {
+		tries++
+		if tries > bnbMaxTries {
+			return
+		}
+		if total > target+costOfChange || total+remaining < target {
+			return
+		}
+		if total >= target {
+			waste := total - target
+			if bestWaste < 0 || waste < bestWaste {
+				bestWaste = waste
+				best = append([]int(nil), current...)
+			}
+			return
+		}
+		if depth == len(values) {
+			return
+		}
+
+		remaining -= values[depth]
+		// 上一个金额相同的 utxo 没有被包含时，包含当前 utxo 得到的组合与包含上一个是重复的
+		prevIncluded := len(current) > 0 && current[len(current)-1] == depth-1
+		if depth == 0 || values[depth] != values[depth-1] || prevIncluded {
+			current = append(current, depth)
+			total += values[depth]
+			search(depth + 1)
+			current = current[:len(current)-1]
+			total -= values[depth]
+		}
+
+		// 再尝试跳过当前 utxo
+		search(depth + 1)
+		remaining += values[depth]
+	}
+	search(0)
+	return best
+}
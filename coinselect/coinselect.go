@@ -0,0 +1,120 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	StrategyBranchAndBound     = "branch_and_bound"
+	StrategyLargestFirst       = "largest_first"
+	StrategySmallestSufficient = "smallest_sufficient"
+)
+
+// DefaultDustLimit 找零低于粉尘值时并入手续费
+const DefaultDustLimit = 546
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Utxo 可以作为交易输入的未花费输出，金额单位为聪
+type Utxo struct {
+	TxId   string
+	Vout   uint32
+	Amount int64
+}
+
+// Params 选币参数，大小均为虚拟字节，BaseSize 为交易固定部分加上所有提现输出的大小
+type Params struct {
+	Target     int64
+	FeeRate    int64
+	BaseSize   int64
+	InputSize  int64
+	ChangeSize int64
+	DustLimit  int64
+}
+
+// Selection 选币结果，Change 为 0 表示不需要找零输出
+type Selection struct {
+	Inputs []Utxo
+	Fee    int64
+	Change int64
+}
+
+type Selector interface {
+	Select(utxos []Utxo, params Params) (*Selection, error)
+}
+
+// NewSelector 根据策略名返回选币器，策略为空时使用 branch and bound
+func NewSelector(strategy string) (Selector, error) {
+	switch strategy {
+	case "", StrategyBranchAndBound:
+		return &BranchAndBound{}, nil
+	case StrategyLargestFirst:
+		return &LargestFirst{}, nil
+	case StrategySmallestSufficient:
+		return &SmallestSufficient{}, nil
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy: %s", strategy)
+	}
+}
+
+func (p Params) inputFee() int64 {
+	return p.InputSize * p.FeeRate
+}
+
+// fee 返回 n 个输入、是否带找零输出时的手续费
+func (p Params) fee(n int, withChange bool) int64 {
+	size := p.BaseSize + int64(n)*p.InputSize
+	if withChange {
+		size += p.ChangeSize
+	}
+	return size * p.FeeRate
+}
+
+// finish 根据选中的输入计算手续费和找零，找零不超过粉尘值时不生成找零输出
+func (p Params) finish(inputs []Utxo) (*Selection, error) {
+	var total int64
+	for _, input := range inputs {
+		total += input.Amount
+	}
+	noChangeFee := p.fee(len(inputs), false)
+	if total < p.Target+noChangeFee {
+		return nil, ErrInsufficientFunds
+	}
+	changeFee := p.fee(len(inputs), true)
+	change := total - p.Target - changeFee
+	if change > p.DustLimit {
+		return &Selection{Inputs: inputs, Fee: changeFee, Change: change}, nil
+	}
+	return &Selection{Inputs: inputs, Fee: total - p.Target, Change: 0}, nil
+}
+
+// accumulate 按给定顺序逐个加入输入，直到足够支付提现金额和手续费
+func (p Params) accumulate(utxos []Utxo) (*Selection, error) {
+	var (
+		inputs []Utxo
+		total  int64
+	)
+	for _, utxo := range utxos {
+		// 输入本身的手续费都不够的 utxo 不选
+		if utxo.Amount <= p.inputFee() {
+			continue
+		}
+		inputs = append(inputs, utxo)
+		total += utxo.Amount
+		if total >= p.Target+p.fee(len(inputs), false) {
+			return p.finish(inputs)
+		}
+	}
+	return nil, ErrInsufficientFunds
+}
+
+func sortByAmountDesc(utxos []Utxo) []Utxo {
+	sorted := make([]Utxo, len(utxos))
+	copy(sorted, utxos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Amount > sorted[j].Amount
+	})
+	return sorted
+}
@@ -0,0 +1,93 @@
+package coinselect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testParams(target int64) Params {
+	return Params{
+		Target:     target,
+		FeeRate:    1,
+		BaseSize:   44,
+		InputSize:  148,
+		ChangeSize: 34,
+		DustLimit:  DefaultDustLimit,
+	}
+}
+
+func testUtxos(amounts ...int64) []Utxo {
+	var utxos []Utxo
+	for i, amount := range amounts {
+		utxos = append(utxos, Utxo{TxId: "tx", Vout: uint32(i), Amount: amount})
+	}
+	return utxos
+}
+
+func selectionTotal(selection *Selection) int64 {
+	var total int64
+	for _, input := range selection.Inputs {
+		total += input.Amount
+	}
+	return total
+}
+
+func TestLargestFirst(t *testing.T) {
+	selection, err := (&LargestFirst{}).Select(testUtxos(10_000, 50_000, 20_000), testParams(40_000))
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 1)
+	require.Equal(t, int64(50_000), selection.Inputs[0].Amount)
+	require.Equal(t, selectionTotal(selection), 40_000+selection.Fee+selection.Change)
+	require.Greater(t, selection.Change, int64(DefaultDustLimit))
+}
+
+func TestSmallestSufficient(t *testing.T) {
+	selection, err := (&SmallestSufficient{}).Select(testUtxos(100_000, 30_000, 60_000), testParams(25_000))
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 1)
+	require.Equal(t, int64(30_000), selection.Inputs[0].Amount)
+
+	// 没有单个足够的 utxo 时从大到小累加
+	selection, err = (&SmallestSufficient{}).Select(testUtxos(10_000, 30_000, 20_000), testParams(45_000))
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 2)
+	require.Equal(t, selectionTotal(selection), 45_000+selection.Fee+selection.Change)
+}
+
+func TestBranchAndBoundExactMatch(t *testing.T) {
+	params := testParams(0)
+	// 两个输入、无找零时恰好匹配
+	params.Target = 30_000 + 20_000 - params.fee(2, false)
+	selection, err := (&BranchAndBound{}).Select(testUtxos(70_000, 30_000, 5_000, 20_000), params)
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 2)
+	require.Equal(t, int64(0), selection.Change)
+	require.Equal(t, params.fee(2, false), selection.Fee)
+}
+
+func TestBranchAndBoundDuplicateValues(t *testing.T) {
+	// 唯一的精确匹配需要跳过两个金额相同的 utxo
+	require.Equal(t, []int{2}, branchAndBound([]int64{5_000, 5_000, 3_000}, 3_000, 0, 13_000))
+	// 金额相同时只搜索包含前一个的组合，仍然能找到两个都包含的匹配
+	require.Equal(t, []int{0, 1}, branchAndBound([]int64{5_000, 5_000, 3_000}, 10_000, 0, 13_000))
+}
+
+func TestBranchAndBoundFallback(t *testing.T) {
+	selection, err := (&BranchAndBound{}).Select(testUtxos(100_000, 100_000), testParams(10_000))
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 1)
+	require.Greater(t, selection.Change, int64(0))
+	require.Equal(t, selectionTotal(selection), 10_000+selection.Fee+selection.Change)
+}
+
+func TestInsufficientFunds(t *testing.T) {
+	for _, strategy := range []string{StrategyBranchAndBound, StrategyLargestFirst, StrategySmallestSufficient} {
+		selector, err := NewSelector(strategy)
+		require.NoError(t, err)
+		_, err = selector.Select(testUtxos(1_000, 2_000), testParams(10_000))
+		require.ErrorIs(t, err, ErrInsufficientFunds, strategy)
+	}
+	_, err := NewSelector("unknown")
+	require.Error(t, err)
+}
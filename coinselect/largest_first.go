@@ -0,0 +1,8 @@
+package coinselect
+
+// LargestFirst 从金额最大的 utxo 开始累加，输入数量最少
+type LargestFirst struct{}
+
+func (s *LargestFirst) Select(utxos []Utxo, params Params) (*Selection, error) {
+	return params.accumulate(sortByAmountDesc(utxos))
+}
@@ -0,0 +1,15 @@
+package coinselect
+
+// SmallestSufficient 选择单个就足够支付的最小 utxo，没有时退化为从大到小累加
+type SmallestSufficient struct{}
+
+func (s *SmallestSufficient) Select(utxos []Utxo, params Params) (*Selection, error) {
+	sorted := sortByAmountDesc(utxos)
+	required := params.Target + params.fee(1, false)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].Amount >= required {
+			return params.finish([]Utxo{sorted[i]})
+		}
+	}
+	return params.accumulate(sorted)
+}
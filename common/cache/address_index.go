@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+)
+
+var (
+	addressIndex     *AddressIndex
+	addressIndexOnce sync.Once
+)
+
+// AddressIndex 按业务方索引钱包地址，扫块分类交易时先用布隆过滤器排除不属于钱包的地址，
+// 命中后再查 ristretto 缓存，缓存未命中才回源数据库
+type AddressIndex struct {
+	db         *database.DB
+	mu         sync.RWMutex
+	businesses map[string]*businessAddresses
+}
+
+type businessAddresses struct {
+	filter *bloomFilter
+	hot    *database.Addresses
+	cold   *database.Addresses
+	// loadedAt 已加载地址的最大时间戳，增量刷新时从这里开始
+	loadedAt uint64
+}
+
+// InitAddressIndex 初始化全局地址索引，只会执行一次
+func InitAddressIndex(db *database.DB) *AddressIndex {
+	addressIndexOnce.Do(func() {
+		addressIndex = &AddressIndex{
+			db:         db,
+			businesses: make(map[string]*businessAddresses),
+		}
+	})
+	return addressIndex
+}
+
+// GetAddressIndex 获取全局地址索引，当前进程没有初始化时返回 nil
+func GetAddressIndex() *AddressIndex {
+	return addressIndex
+}
+
+// Load 从数据库全量加载业务方的地址
+func (idx *AddressIndex) Load(businessId string) error {
+	addresses, err := idx.db.Addresses.GetAllAddresses(businessId)
+	if err != nil {
+		return err
+	}
+	entry := &businessAddresses{filter: newBloomFilter(uint64(len(addresses)) * 2)}
+	for _, address := range addresses {
+		entry.add(businessId, address)
+	}
+	idx.mu.Lock()
+	idx.businesses[businessId] = entry
+	idx.mu.Unlock()
+	log.Info("load address index success", "businessId", businessId, "addresses", len(addresses))
+	return nil
+}
+
+// Refresh 加载其他进程新写入的地址；还没有加载过或者布隆过滤器已满时全量重建
+func (idx *AddressIndex) Refresh(businessId string) error {
+	idx.mu.RLock()
+	entry, ok := idx.businesses[businessId]
+	idx.mu.RUnlock()
+	if !ok || entry.filter.full() {
+		return idx.Load(businessId)
+	}
+	// 同一秒写入的地址可能只加载了一部分，从 loadedAt 开始重复加载，布隆过滤器和缓存的写入是幂等的
+	addresses, err := idx.db.Addresses.QueryAddressesSince(businessId, entry.loadedAt)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	for _, address := range addresses {
+		entry.add(businessId, address)
+	}
+	idx.mu.Unlock()
+	return nil
+}
+
+// Add 记录新生成的地址，还没有加载过的业务方在 Refresh 时全量加载
+func (idx *AddressIndex) Add(businessId string, addresses []database.Addresses) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	entry, ok := idx.businesses[businessId]
+	if !ok {
+		return
+	}
+	for i := range addresses {
+		entry.add(businessId, &addresses[i])
+	}
+}
+
+// Lookup 返回属于业务方钱包的地址，不属于钱包时返回 nil
+func (idx *AddressIndex) Lookup(businessId string, address string) (*database.Addresses, error) {
+	idx.mu.RLock()
+	entry, ok := idx.businesses[businessId]
+	hit := ok && entry.filter.test(address)
+	idx.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("address index not loaded: " + businessId)
+	}
+	if !hit {
+		return nil, nil
+	}
+	if cached, found := GetGlobalCache().Get(cacheKey(businessId, address)); found {
+		return cached, nil
+	}
+	// 缓存可能被淘汰，也可能是布隆过滤器误判，回源数据库确认
+	addressEntry, err := idx.db.Addresses.QueryAddressesByToAddress(businessId, address)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	GetGlobalCache().Set(cacheKey(businessId, address), addressEntry, 1)
+	return addressEntry, nil
+}
+
+// HotWallet 业务方的热钱包地址，没有时返回 nil
+func (idx *AddressIndex) HotWallet(businessId string) *database.Addresses {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if entry, ok := idx.businesses[businessId]; ok {
+		return entry.hot
+	}
+	return nil
+}
+
+// ColdWallet 业务方的冷钱包地址，没有时返回 nil
+func (idx *AddressIndex) ColdWallet(businessId string) *database.Addresses {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if entry, ok := idx.businesses[businessId]; ok {
+		return entry.cold
+	}
+	return nil
+}
+
+func (entry *businessAddresses) add(businessId string, address *database.Addresses) {
+	entry.filter.add(address.Address)
+	GetGlobalCache().Set(cacheKey(businessId, address.Address), address, 1)
+	switch address.AddressType {
+	case 1:
+		entry.hot = address
+	case 2:
+		entry.cold = address
+	}
+	if address.Timestamp > entry.loadedAt {
+		entry.loadedAt = address.Timestamp
+	}
+}
+
+func cacheKey(businessId string, address string) string {
+	return businessId + ":" + address
+}
@@ -0,0 +1,61 @@
+package cache
+
+import "hash/fnv"
+
+const (
+	bloomBitsPerItem = 10 // 每个元素 10 位、7 个哈希函数时误判率约为 1%
+	bloomHashCount   = 7
+	bloomMinCapacity = 1 << 16
+)
+
+// bloomFilter 只会把不存在的元素误判为存在，不会漏判已添加的元素
+type bloomFilter struct {
+	bits     []uint64
+	size     uint64
+	count    uint64
+	capacity uint64
+}
+
+func newBloomFilter(capacity uint64) *bloomFilter {
+	if capacity < bloomMinCapacity {
+		capacity = bloomMinCapacity
+	}
+	size := capacity * bloomBitsPerItem
+	return &bloomFilter{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		capacity: capacity,
+	}
+}
+
+func (b *bloomFilter) add(item string) {
+	h1, h2 := bloomHash(item)
+	for i := uint64(0); i < bloomHashCount; i++ {
+		pos := (h1 + i*h2) % b.size
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+	b.count++
+}
+
+func (b *bloomFilter) test(item string) bool {
+	h1, h2 := bloomHash(item)
+	for i := uint64(0); i < bloomHashCount; i++ {
+		pos := (h1 + i*h2) % b.size
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// full 元素数超过容量后误判率上升，需要按更大的容量重建
+func (b *bloomFilter) full() bool {
+	return b.count > b.capacity
+}
+
+func bloomHash(item string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(item))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
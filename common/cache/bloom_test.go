@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(10_000)
+	for i := 0; i < 10_000; i++ {
+		filter.add(fmt.Sprintf("bc1qwallet%d", i))
+	}
+	for i := 0; i < 10_000; i++ {
+		require.True(t, filter.test(fmt.Sprintf("bc1qwallet%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 100_000; i++ {
+		if filter.test(fmt.Sprintf("bc1qother%d", i)) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 2_000)
+	require.False(t, filter.full())
+}
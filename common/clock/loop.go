@@ -11,6 +11,7 @@ type LoopFn struct {
 	cancel context.CancelFunc
 
 	ticker  Ticker
+	trigger <-chan struct{}
 	fn      func(ctx context.Context)
 	onClose func() error
 
@@ -34,22 +35,33 @@ func (lf *LoopFn) work() {
 		case <-lf.ctx.Done():
 			return
 		case <-lf.ticker.Ch():
-			ctx, cancel := context.WithCancel(lf.ctx)
-			func() {
-				defer cancel()
-				lf.fn(ctx)
-			}()
+			lf.call()
+		case <-lf.trigger:
+			lf.call()
 		}
 	}
 }
 
+func (lf *LoopFn) call() {
+	ctx, cancel := context.WithCancel(lf.ctx)
+	defer cancel()
+	lf.fn(ctx)
+}
+
 func NewLoopFn(clock Clock, fn func(ctx context.Context), onClose func() error, interval time.Duration) *LoopFn {
+	return NewTriggeredLoopFn(clock, fn, onClose, interval, nil)
+}
+
+// NewTriggeredLoopFn is like NewLoopFn, but additionally runs fn whenever a signal is received on trigger.
+// A nil trigger never fires.
+func NewTriggeredLoopFn(clock Clock, fn func(ctx context.Context), onClose func() error, interval time.Duration, trigger <-chan struct{}) *LoopFn {
 	ctx, cancel := context.WithCancel(context.Background())
 	lf := &LoopFn{
 		ctx:     ctx,
 		cancel:  cancel,
 		fn:      fn,
 		ticker:  clock.NewTicker(interval),
+		trigger: trigger,
 		onClose: onClose,
 	}
 	lf.wg.Add(1)
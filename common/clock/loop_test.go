@@ -30,3 +30,22 @@ func TestLoopFn(t *testing.T) {
 	}
 	require.ErrorIs(t, loopFn.Close(), testErr)
 }
+
+func TestTriggeredLoopFn(t *testing.T) {
+	cl := NewDeterministicClock(time.Now())
+	calls := make(chan struct{}, 10)
+	trigger := make(chan struct{})
+	loopFn := NewTriggeredLoopFn(cl, func(ctx context.Context) {
+		calls <- struct{}{}
+	}, nil, time.Second*10, trigger)
+	trigger <- struct{}{}
+	<-calls
+	cl.AdvanceTime(time.Second * 10)
+	<-calls
+	select {
+	case <-calls:
+		t.Fatal("more calls than expected")
+	default:
+	}
+	require.NoError(t, loopFn.Close())
+}
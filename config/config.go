@@ -1,23 +1,37 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
 	"github.com/0xshin-chan/multichain-sync-btc/flags"
 )
 
 const (
-	defaultConfirmations        = 64
 	defaultSynchronizerInterval = 5000
 	defaultWorkerInterval       = 500
 	defaultBlocksStep           = 500
+	defaultUtxoLockTimeout      = 30 * time.Minute
+	defaultWithdrawStuckTimeout = time.Hour
+	defaultRbfMaxFeeRate        = 200
+	defaultMempoolInterval      = 15 * time.Second
+	defaultFetchParallelism     = 8
+	defaultRpcHealthInterval    = 15 * time.Second
+	defaultRpcBreakerThreshold  = 3
+	defaultRpcBreakerTimeout    = 30 * time.Second
+	defaultChainSource          = "wallet"
+	disabledMempoolSource       = "none"
+	defaultNetwork              = "mainnet"
+	defaultZmqQuietTimeout      = 5 * time.Minute
 )
 
 type Config struct {
 	Migrations     string
+	Chains         []string
 	ChainNode      ChainNodeConfig
 	MasterDB       DBConfig
 	SlaveDB        DBConfig
@@ -26,18 +40,38 @@ type Config struct {
 	CacheConfig    CacheConfig
 	RpcServer      ServerConfig
 	MetricsServer  ServerConfig
-	ChainBtcRpc    string
+	ChainBtcRpc    []string
 }
 
 type ChainNodeConfig struct {
 	ChainId              uint64
 	ChainName            string
+	Network              string
 	RpcUrl               string
 	StartingHeight       uint
 	Confirmations        uint
 	SynchronizerInterval time.Duration
 	WorkerInterval       time.Duration
 	BlocksStep           uint64
+	FetchParallelism     int
+	UtxoLockTimeout      time.Duration
+	WithdrawStuckTimeout time.Duration
+	RbfAutoBump          bool
+	RbfMaxFeeRate        int64
+	MempoolInterval      time.Duration
+	RpcHealthInterval    time.Duration
+	RpcBreakerThreshold  int
+	RpcBreakerTimeout    time.Duration
+	RpcQuorum            int
+	ChainSource          string
+	BitcoindRpc          string
+	BitcoindRpcUser      string
+	BitcoindRpcPassword  string
+	EsploraUrl           string
+	MempoolSource        string
+	ZmqBlockAddr         string
+	ZmqTxAddr            string
+	ZmqQuietTimeout      time.Duration
 }
 
 type DBConfig struct {
@@ -64,10 +98,6 @@ func LoadConfig(cliCtx *cli.Context) (Config, error) {
 	var cfg Config
 	cfg = NewConfig(cliCtx)
 
-	if cfg.ChainNode.Confirmations == 0 {
-		cfg.ChainNode.Confirmations = defaultConfirmations
-	}
-
 	if cfg.ChainNode.SynchronizerInterval == 0 {
 		cfg.ChainNode.SynchronizerInterval = defaultSynchronizerInterval
 	}
@@ -80,23 +110,168 @@ func LoadConfig(cliCtx *cli.Context) (Config, error) {
 		cfg.ChainNode.BlocksStep = defaultBlocksStep
 	}
 
+	if cfg.ChainNode.FetchParallelism <= 0 {
+		cfg.ChainNode.FetchParallelism = defaultFetchParallelism
+	}
+
+	if cfg.ChainNode.UtxoLockTimeout == 0 {
+		cfg.ChainNode.UtxoLockTimeout = defaultUtxoLockTimeout
+	}
+
+	if cfg.ChainNode.WithdrawStuckTimeout == 0 {
+		cfg.ChainNode.WithdrawStuckTimeout = defaultWithdrawStuckTimeout
+	}
+
+	if cfg.ChainNode.RbfMaxFeeRate == 0 {
+		cfg.ChainNode.RbfMaxFeeRate = defaultRbfMaxFeeRate
+	}
+
+	if cfg.ChainNode.MempoolInterval == 0 {
+		cfg.ChainNode.MempoolInterval = defaultMempoolInterval
+	}
+
+	if cfg.ChainNode.RpcHealthInterval == 0 {
+		cfg.ChainNode.RpcHealthInterval = defaultRpcHealthInterval
+	}
+
+	if cfg.ChainNode.RpcBreakerThreshold <= 0 {
+		cfg.ChainNode.RpcBreakerThreshold = defaultRpcBreakerThreshold
+	}
+
+	if cfg.ChainNode.RpcBreakerTimeout == 0 {
+		cfg.ChainNode.RpcBreakerTimeout = defaultRpcBreakerTimeout
+	}
+
+	if cfg.ChainNode.ZmqQuietTimeout == 0 {
+		cfg.ChainNode.ZmqQuietTimeout = defaultZmqQuietTimeout
+	}
+
+	if cfg.ChainNode.Network == "" {
+		cfg.ChainNode.Network = defaultNetwork
+	}
+
+	if len(cfg.Chains) == 0 {
+		return cfg, fmt.Errorf("at least one chain is required")
+	}
+	seen := make(map[string]bool)
+	for _, chain := range cfg.Chains {
+		if seen[chain] {
+			return cfg, fmt.Errorf("duplicate chain %s", chain)
+		}
+		seen[chain] = true
+		if _, err := chaincfg.ParamsFor(chain, cfg.ChainNode.Network); err != nil {
+			return cfg, err
+		}
+	}
+	cfg.ChainNode.ChainName = cfg.Chains[0]
+
+	if cfg.ChainNode.ChainSource == "" {
+		cfg.ChainNode.ChainSource = defaultChainSource
+	}
+
+	if cfg.ChainNode.ChainSource == defaultChainSource && len(cfg.ChainBtcRpc) == 0 {
+		return cfg, fmt.Errorf("btc rpc hosts are required when chain source is wallet")
+	}
+
+	if cfg.ChainNode.ChainSource == "bitcoind" && cfg.ChainNode.BitcoindRpc == "" {
+		return cfg, fmt.Errorf("bitcoind rpc url is required when chain source is bitcoind")
+	}
+
+	if cfg.ChainNode.ChainSource == "esplora" && cfg.ChainNode.EsploraUrl == "" {
+		return cfg, fmt.Errorf("esplora url is required when chain source is esplora")
+	}
+
+	if cfg.ChainNode.MempoolSource == "" {
+		cfg.ChainNode.MempoolSource = cfg.ChainNode.ChainSource
+	}
+
+	// 上游钱包服务没有内存池接口，内存池充值检测需要单独的 bitcoind 或 esplora，或者显式关闭
+	switch cfg.ChainNode.MempoolSource {
+	case "bitcoind":
+		if cfg.ChainNode.BitcoindRpc == "" {
+			return cfg, fmt.Errorf("bitcoind rpc url is required when mempool source is bitcoind")
+		}
+	case "esplora":
+		if cfg.ChainNode.EsploraUrl == "" {
+			return cfg, fmt.Errorf("esplora url is required when mempool source is esplora")
+		}
+	case disabledMempoolSource:
+	case defaultChainSource:
+		return cfg, fmt.Errorf("chain account rpc can not list mempool transactions, set mempool source to bitcoind or esplora, or %s to disable mempool deposit detection", disabledMempoolSource)
+	default:
+		return cfg, fmt.Errorf("unknown mempool source %q", cfg.ChainNode.MempoolSource)
+	}
+
+	if cfg.ChainNode.RpcQuorum > len(cfg.ChainBtcRpc) {
+		return cfg, fmt.Errorf("btc rpc quorum %d exceeds the number of rpc hosts %d", cfg.ChainNode.RpcQuorum, len(cfg.ChainBtcRpc))
+	}
+
+	// 钱包服务按请求中的链名区分链，可以多条链共用；bitcoind、esplora、ZMQ 和起始高度都只对应一条链
+	if len(cfg.Chains) > 1 {
+		if cfg.ChainNode.ChainSource != defaultChainSource {
+			return cfg, fmt.Errorf("chain source %s only supports a single chain", cfg.ChainNode.ChainSource)
+		}
+		if cfg.ChainNode.MempoolSource != disabledMempoolSource {
+			return cfg, fmt.Errorf("mempool source %s only supports a single chain", cfg.ChainNode.MempoolSource)
+		}
+		if cfg.ChainNode.ZmqBlockAddr != "" || cfg.ChainNode.ZmqTxAddr != "" {
+			return cfg, fmt.Errorf("zmq notifications only support a single chain")
+		}
+		if cfg.ChainNode.StartingHeight > 0 {
+			return cfg, fmt.Errorf("starting height only supports a single chain")
+		}
+	}
+
 	log.Info("loaded chain config", "config", cfg.ChainNode)
 	return cfg, nil
 }
 
+// ForChain 返回 chain 对应的配置，没有配置确认数时使用该链的默认确认数
+func (c Config) ForChain(chain string) (Config, error) {
+	params, err := chaincfg.ParamsFor(chain, c.ChainNode.Network)
+	if err != nil {
+		return c, err
+	}
+	c.ChainNode.ChainName = chain
+	if c.ChainNode.Confirmations == 0 {
+		c.ChainNode.Confirmations = params.DefaultConfirmations
+	}
+	return c, nil
+}
+
 func NewConfig(ctx *cli.Context) Config {
 	return Config{
 		Migrations:  ctx.String(flags.MigrationsFlag.Name),
-		ChainBtcRpc: ctx.String(flags.ChainBtcRpcFlag.Name),
+		Chains:      ctx.StringSlice(flags.ChainNameFlag.Name),
+		ChainBtcRpc: ctx.StringSlice(flags.ChainBtcRpcFlag.Name),
 		ChainNode: ChainNodeConfig{
 			ChainId:              ctx.Uint64(flags.ChainIdFlag.Name),
-			ChainName:            ctx.String(flags.ChainNameFlag.Name),
+			Network:              ctx.String(flags.NetworkFlag.Name),
 			RpcUrl:               ctx.String(flags.RpcUrlFlag.Name),
 			StartingHeight:       ctx.Uint(flags.StartingHeightFlag.Name),
 			Confirmations:        ctx.Uint(flags.ConfirmationsFlag.Name),
 			SynchronizerInterval: ctx.Duration(flags.SynchronizerIntervalFlag.Name),
 			WorkerInterval:       ctx.Duration(flags.WorkerIntervalFlag.Name),
 			BlocksStep:           ctx.Uint64(flags.BlocksStepFlag.Name),
+			FetchParallelism:     ctx.Int(flags.FetchParallelismFlag.Name),
+			UtxoLockTimeout:      ctx.Duration(flags.UtxoLockTimeoutFlag.Name),
+			WithdrawStuckTimeout: ctx.Duration(flags.WithdrawStuckTimeoutFlag.Name),
+			RbfAutoBump:          ctx.Bool(flags.RbfAutoBumpFlag.Name),
+			RbfMaxFeeRate:        ctx.Int64(flags.RbfMaxFeeRateFlag.Name),
+			MempoolInterval:      ctx.Duration(flags.MempoolIntervalFlag.Name),
+			RpcHealthInterval:    ctx.Duration(flags.RpcHealthIntervalFlag.Name),
+			RpcBreakerThreshold:  ctx.Int(flags.RpcBreakerThresholdFlag.Name),
+			RpcBreakerTimeout:    ctx.Duration(flags.RpcBreakerTimeoutFlag.Name),
+			RpcQuorum:            ctx.Int(flags.RpcQuorumFlag.Name),
+			ChainSource:          ctx.String(flags.ChainSourceFlag.Name),
+			BitcoindRpc:          ctx.String(flags.BitcoindRpcFlag.Name),
+			BitcoindRpcUser:      ctx.String(flags.BitcoindRpcUserFlag.Name),
+			BitcoindRpcPassword:  ctx.String(flags.BitcoindRpcPasswordFlag.Name),
+			EsploraUrl:           ctx.String(flags.EsploraUrlFlag.Name),
+			MempoolSource:        ctx.String(flags.MempoolSourceFlag.Name),
+			ZmqBlockAddr:         ctx.String(flags.ZmqBlockFlag.Name),
+			ZmqTxAddr:            ctx.String(flags.ZmqTxFlag.Name),
+			ZmqQuietTimeout:      ctx.Duration(flags.ZmqQuietTimeoutFlag.Name),
 		},
 		MasterDB: DBConfig{
 			Host:     ctx.String(flags.MasterDbHostFlag.Name),
@@ -22,6 +22,7 @@ type AddressesView interface {
 	QueryHotWalletInfo(string) (*Addresses, error)
 	QueryColdWalletInfo(string) (*Addresses, error)
 	GetAllAddresses(string) ([]*Addresses, error)
+	QueryAddressesSince(requestId string, timestamp uint64) ([]*Addresses, error)
 }
 
 type AddressesDB interface {
@@ -103,3 +104,13 @@ func (db *addressesDB) GetAllAddresses(requestId string) ([]*Addresses, error) {
 	}
 	return addresses, nil
 }
+
+// QueryAddressesSince 查询 timestamp 及之后生成的地址
+func (db *addressesDB) QueryAddressesSince(requestId string, timestamp uint64) ([]*Addresses, error) {
+	var addresses []*Addresses
+	err := db.gorm.Table("addresses_"+requestId).Where("timestamp >= ?", timestamp).Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
@@ -27,6 +27,7 @@ type BalancesDB interface {
 	BalancesView
 
 	UpdateOrCreate(string, []TokenBalance) error
+	RollbackBalances(string, []TokenBalance) error
 	StoreBalances(string, []Balances) error
 	UpdateBalances(string, []Balances) error
 }
@@ -90,108 +91,94 @@ func (db *balancesDB) QueryWalletBalanceByAddress(requestId string, addressType
 	return &balanceEntry, nil
 }
 
+// UpdateOrCreate 记入已上链交易的余额变动：ToAddress 加上收到的金额，FromAddress 扣减花出的金额
 func (db *balancesDB) UpdateOrCreate(requestId string, balanceList []TokenBalance) error {
 	for _, value := range balanceList {
-		log.Info("Query wallet balance by token and address", "toAddress", value.ToAddress, "TokenAddress", value.TokenAddress, "Balance", value.Balance, "TxType", value.TxType)
-		if value.TxType == "deposit" {
-			userAddress, err := db.QueryWalletBalanceByAddress(requestId, 0, value.ToAddress)
-			if err != nil {
-				log.Error("Query user address fail", "err", err)
-				return err
-			}
-			userAddress.Balance = new(big.Int).Add(userAddress.Balance, value.Balance)
-			log.Info("Query user address success", "AddressType", userAddress.AddressType, "Address", userAddress.Address, "Balance", userAddress.Balance)
-
-			errU := db.gorm.Table("balances_" + requestId).Save(&userAddress).Error
-			if errU != nil {
-				log.Error("Update user balance fail", "err", err)
-				return errU
-			}
-		} else if value.TxType == "withdraw" {
-			hotWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 1, value.FromAddress)
-			if err != nil {
-				log.Error("Query user address fail", "err", err)
-				return err
-			}
-			hotWalletAddress.Balance = new(big.Int).Sub(hotWalletAddress.LockBalance, value.Balance)
-			errU := db.gorm.Table("balances_" + requestId).Save(&hotWalletAddress).Error
-			if errU != nil {
-				log.Error("Update hot wallet balance fail", "err", err)
-				return errU
-			}
-		} else if value.TxType == "collection" {
-			userWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 0, value.FromAddress)
-			if err != nil {
-				log.Error("Query user address fail", "err", err)
-				return err
-			}
-			userWalletAddress.Balance = new(big.Int).Sub(userWalletAddress.LockBalance, value.Balance)
-			errU := db.gorm.Table("balances_" + requestId).Save(&userWalletAddress).Error
-			if errU != nil {
-				log.Error("Update user balance fail", "err", err)
-				return errU
-			}
+		log.Info("Update wallet balance", "fromAddress", value.FromAddress, "toAddress", value.ToAddress, "Balance", value.Balance, "TxType", value.TxType)
+		if err := db.applyTokenBalance(requestId, value, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			hotWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 1, value.ToAddress)
-			if err != nil {
-				log.Error("Query hot wallet balance fail", "err", err)
-				return err
-			}
-			hotWalletAddress.Balance = new(big.Int).Add(hotWalletAddress.Balance, value.Balance)
-			errHot := db.gorm.Table("balances_" + requestId).Save(&hotWalletAddress).Error
-			if errHot != nil {
-				log.Error("Update hot wallet balance fail", "err", err)
-				return errHot
-			}
-		} else if value.TxType == "hot2cold" {
-			hotWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 1, value.FromAddress)
-			if err != nil {
-				log.Error("Query hot wallet info fail", "err", err)
-				return err
-			}
-			hotWalletAddress.Balance = new(big.Int).Sub(hotWalletAddress.LockBalance, value.Balance)
-			errHot := db.gorm.Table("balances_" + requestId).Save(&hotWalletAddress).Error
-			if errHot != nil {
-				log.Error("Update user balance fail", "err", err)
-				return errHot
-			}
+// RollbackBalances 撤销 UpdateOrCreate 记入的余额变动，余额和锁定余额恢复到记入之前，
+// 用于区块重组后回滚孤块中的交易
+func (db *balancesDB) RollbackBalances(requestId string, balanceList []TokenBalance) error {
+	for _, value := range balanceList {
+		if err := db.applyTokenBalance(requestId, value, true); err != nil {
+			return err
+		}
+		log.Info("Rollback balance success", "txType", value.TxType, "from", value.FromAddress, "to", value.ToAddress, "amount", value.Balance)
+	}
+	return nil
+}
 
-			coldWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 2, value.ToAddress)
-			if err != nil {
-				log.Error("Query hot wallet balance fail", "err", err)
-				return err
-			}
-			coldWalletAddress.Balance = new(big.Int).Add(coldWalletAddress.Balance, value.Balance)
-			errCold := db.gorm.Table("balances_" + requestId).Save(&coldWalletAddress).Error
-			if errCold != nil {
-				log.Error("Update hot wallet balance fail", "err", err)
-				return errCold
-			}
+func (db *balancesDB) applyTokenBalance(requestId string, value TokenBalance, rollback bool) error {
+	fromType, toType := balanceAddressTypes(value.TxType)
+	var from, to *Balances
+	if value.FromAddress != "" {
+		fromAddress, err := db.QueryWalletBalanceByAddress(requestId, fromType, value.FromAddress)
+		if err != nil {
+			log.Error("Query from address balance fail", "err", err)
+			return err
+		}
+		from = fromAddress
+	}
+	if value.ToAddress != "" {
+		if value.ToAddress == value.FromAddress {
+			to = from
 		} else {
-			coldWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 2, value.ToAddress)
-			if err != nil {
-				log.Error("Query hot wallet balance fail", "err", err)
-				return err
-			}
-			coldWalletAddress.Balance = new(big.Int).Sub(coldWalletAddress.LockBalance, value.Balance)
-			errCold := db.gorm.Table("balances_" + requestId).Save(&coldWalletAddress).Error
-			if errCold != nil {
-				log.Error("Update hot wallet balance fail", "err", err)
-				return errCold
-			}
-
-			hotWalletAddress, err := db.QueryWalletBalanceByAddress(requestId, 1, value.FromAddress)
+			toAddress, err := db.QueryWalletBalanceByAddress(requestId, toType, value.ToAddress)
 			if err != nil {
-				log.Error("Query hot wallet info fail", "err", err)
+				log.Error("Query to address balance fail", "err", err)
 				return err
 			}
-			hotWalletAddress.Balance = new(big.Int).Add(hotWalletAddress.Balance, value.Balance)
-			errHot := db.gorm.Table("balances_" + requestId).Save(&hotWalletAddress).Error
-			if errHot != nil {
-				log.Error("Update user balance fail", "err", err)
-				return errHot
-			}
+			to = toAddress
+		}
+	}
+	applyBalance(from, to, value.Balance, rollback)
+	for _, balance := range []*Balances{from, to} {
+		if balance == nil {
+			continue
+		}
+		if err := db.gorm.Table("balances_" + requestId).Save(balance).Error; err != nil {
+			log.Error("Update address balance fail", "address", balance.Address, "err", err)
+			return err
+		}
+		if from == to {
+			break
 		}
 	}
 	return nil
 }
+
+// applyBalance from 扣减 value、to 加上 value，rollback 为 true 时反向操作；锁定余额不变
+func applyBalance(from, to *Balances, value *big.Int, rollback bool) {
+	delta := new(big.Int).Set(value)
+	if rollback {
+		delta.Neg(delta)
+	}
+	if from != nil {
+		from.Balance = new(big.Int).Sub(from.Balance, delta)
+	}
+	if to != nil {
+		to.Balance = new(big.Int).Add(to.Balance, delta)
+	}
+}
+
+// balanceAddressTypes 返回交易类型对应的转出、转入地址类型
+func balanceAddressTypes(txType string) (uint8, uint8) {
+	switch txType {
+	case "withdraw":
+		return 1, 0
+	case "collection":
+		return 0, 1
+	case "hot2cold":
+		return 1, 2
+	case "cold2hot":
+		return 2, 1
+	default:
+		return 0, 0
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceAddressTypes(t *testing.T) {
+	tests := []struct {
+		txType   string
+		fromType uint8
+		toType   uint8
+	}{
+		{"deposit", 0, 0},
+		{"withdraw", 1, 0},
+		{"collection", 0, 1},
+		{"hot2cold", 1, 2},
+		{"cold2hot", 2, 1},
+	}
+	for _, tt := range tests {
+		fromType, toType := balanceAddressTypes(tt.txType)
+		require.Equal(t, tt.fromType, fromType, tt.txType)
+		require.Equal(t, tt.toType, toType, tt.txType)
+	}
+}
+
+func TestApplyBalanceRollbackRestoresBalances(t *testing.T) {
+	from := &Balances{Address: "from", Balance: big.NewInt(1000), LockBalance: big.NewInt(300)}
+	to := &Balances{Address: "to", Balance: big.NewInt(50), LockBalance: big.NewInt(20)}
+
+	applyBalance(from, to, big.NewInt(400), false)
+	require.Equal(t, "600", from.Balance.String())
+	require.Equal(t, "450", to.Balance.String())
+
+	applyBalance(from, to, big.NewInt(400), true)
+	require.Equal(t, "1000", from.Balance.String())
+	require.Equal(t, "300", from.LockBalance.String())
+	require.Equal(t, "50", to.Balance.String())
+	require.Equal(t, "20", to.LockBalance.String())
+}
+
+func TestApplyBalanceSingleSide(t *testing.T) {
+	to := &Balances{Address: "to", Balance: big.NewInt(0), LockBalance: big.NewInt(0)}
+	applyBalance(nil, to, big.NewInt(7), false)
+	require.Equal(t, "7", to.Balance.String())
+	applyBalance(nil, to, big.NewInt(7), true)
+	require.Equal(t, "0", to.Balance.String())
+
+	from := &Balances{Address: "from", Balance: big.NewInt(10), LockBalance: big.NewInt(10)}
+	applyBalance(from, nil, big.NewInt(3), false)
+	require.Equal(t, "7", from.Balance.String())
+	require.Equal(t, "10", from.LockBalance.String())
+	applyBalance(from, nil, big.NewInt(3), true)
+	require.Equal(t, "10", from.Balance.String())
+	require.Equal(t, "10", from.LockBalance.String())
+}
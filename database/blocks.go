@@ -15,6 +15,8 @@ type Blocks struct {
 	PrevHash  string
 	Number    *big.Int `gorm:"serializer:u256"`
 	Timestamp uint64
+	Source    string
+	Chain     string `gorm:"primaryKey"`
 }
 
 func BlockHeaderFromHeader(header *types.Header) syncclient.BlockHeader {
@@ -23,30 +25,37 @@ func BlockHeaderFromHeader(header *types.Header) syncclient.BlockHeader {
 
 type BlocksView interface {
 	LatestBlocks() (*syncclient.BlockHeader, error)
+	BlockHeaderByNumber(*big.Int) (*syncclient.BlockHeader, error)
 }
 
 type BlocksDB interface {
 	BlocksView
 
 	StoreBlockss([]Blocks) error
+	DeleteBlocksAfterNumber(*big.Int) error
 }
 
 type blocksDB struct {
-	gorm *gorm.DB
+	gorm  *gorm.DB
+	chain string
 }
 
-func NewBlocksDB(db *gorm.DB) BlocksDB {
-	return &blocksDB{gorm: db}
+// NewBlocksDB 区块按链区分，chain 为空时不按链过滤
+func NewBlocksDB(db *gorm.DB, chain string) BlocksDB {
+	return &blocksDB{gorm: db, chain: chain}
 }
 
 func (db *blocksDB) StoreBlockss(headers []Blocks) error {
+	for i := range headers {
+		headers[i].Chain = db.chain
+	}
 	result := db.gorm.CreateInBatches(&headers, len(headers))
 	return result.Error
 }
 
 func (db *blocksDB) LatestBlocks() (*syncclient.BlockHeader, error) {
 	var header Blocks
-	result := db.gorm.Order("number DESC").Take(&header)
+	result := db.scoped().Order("number DESC").Take(&header)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -56,3 +65,28 @@ func (db *blocksDB) LatestBlocks() (*syncclient.BlockHeader, error) {
 	// 类型转换
 	return (*syncclient.BlockHeader)(&header), nil
 }
+
+func (db *blocksDB) BlockHeaderByNumber(number *big.Int) (*syncclient.BlockHeader, error) {
+	var header Blocks
+	result := db.scoped().Where("number = ?", number.Uint64()).Take(&header)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return (*syncclient.BlockHeader)(&header), nil
+}
+
+// DeleteBlocksAfterNumber 删除高度大于 number 的区块，回滚时调用
+func (db *blocksDB) DeleteBlocksAfterNumber(number *big.Int) error {
+	result := db.scoped().Where("number > ?", number.Uint64()).Delete(&Blocks{})
+	return result.Error
+}
+
+func (db *blocksDB) scoped() *gorm.DB {
+	if db.chain == "" {
+		return db.gorm
+	}
+	return db.gorm.Where("chain = ?", db.chain)
+}
@@ -9,11 +9,28 @@ import (
 )
 
 type Business struct {
-	GUID        uuid.UUID `gorm:"primaryKey" json:"guid"`
-	BusinessUid string    `json:"business_uid"`
-	NotifyUrl   string    `json:"notify_url"`
-	CallBackUrl string    `json:"call_back_url"`
-	Timestamp   uint64
+	GUID           uuid.UUID `gorm:"primaryKey" json:"guid"`
+	BusinessUid    string    `json:"business_uid"`
+	Chain          string    `json:"chain"` // 业务方接入的链，一个业务方只接入一条链
+	NotifyUrl      string    `json:"notify_url"`
+	CallBackUrl    string    `json:"call_back_url"`
+	CoinSelection  string    `json:"coin_selection"`    // 提现选币策略，为空时使用默认策略
+	CpfpMaxFeeRate uint64    `json:"cpfp_max_fee_rate"` // CPFP 加速允许的最高费率（聪/虚拟字节），为 0 时不开启
+	StartHeight    uint64    `json:"start_height"`      // 业务方指定的起始扫块高度，为 0 时从注册时的区块开始
+	SyncHeight     uint64    `json:"sync_height"`       // 业务方已扫描到的区块高度
+	Timestamp      uint64
+}
+
+// CatchingUp 业务方的扫块进度落后于全局进度 tip，需要先补扫历史区块再加入实时扫块；
+// 起始高度和扫块进度都为 0 的业务方直接跟随实时扫块
+func (b *Business) CatchingUp(tip uint64) bool {
+	return (b.StartHeight > 0 || b.SyncHeight > 0) && b.SyncHeight < tip
+}
+
+// StartPending 业务方指定的起始高度在全局进度 tip 的下一个区块之后，等全局进度到达起始高度后再加入实时扫块；
+// 只看起始高度，重组后扫块进度仍在 tip 之后的业务方照常跟随实时扫块，重新扫描的区块按幂等写入处理
+func (b *Business) StartPending(tip uint64) bool {
+	return b.StartHeight > tip+1
 }
 
 type BusinessView interface {
@@ -25,14 +42,19 @@ type BusinessDB interface {
 	BusinessView
 
 	StoreBusiness(*Business) error
+	UpdateBusinessSyncHeight(businessUids []string, height uint64) error
+	RollbackBusinessSyncHeight(height uint64) error
+	LockBusiness(businessUid string) error
 }
 
 type businessDB struct {
-	gorm *gorm.DB
+	gorm  *gorm.DB
+	chain string
 }
 
-func NewBusinessDB(db *gorm.DB) BusinessDB {
-	return &businessDB{gorm: db}
+// NewBusinessDB chain 不为空时业务方列表只包含接入该链的业务方
+func NewBusinessDB(db *gorm.DB, chain string) BusinessDB {
+	return &businessDB{gorm: db, chain: chain}
 }
 
 func (db *businessDB) StoreBusiness(business *Business) error {
@@ -42,7 +64,11 @@ func (db *businessDB) StoreBusiness(business *Business) error {
 
 func (db *businessDB) QueryBusinessList() ([]Business, error) {
 	var business []Business
-	err := db.gorm.Table("business").Find(&business).Error
+	query := db.gorm.Table("business")
+	if db.chain != "" {
+		query = query.Where("chain = ?", db.chain)
+	}
+	err := query.Find(&business).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, gorm.ErrRecordNotFound
@@ -61,3 +87,44 @@ func (db *businessDB) QueryBusinessByUuid(businessUid string) (*Business, error)
 	}
 	return business, nil
 }
+
+// UpdateBusinessSyncHeight 更新业务方的扫块进度，只会向前推进
+func (db *businessDB) UpdateBusinessSyncHeight(businessUids []string, height uint64) error {
+	if len(businessUids) == 0 {
+		return nil
+	}
+	result := db.gorm.Table("business").
+		Where("business_uid IN ? and sync_height < ?", businessUids, height).
+		Update("sync_height", height)
+	if result.Error != nil {
+		log.Error("update business sync height fail", "height", height, "err", result.Error)
+		return result.Error
+	}
+	return nil
+}
+
+// RollbackBusinessSyncHeight 链重组回滚到 height 后，把扫描进度超过 height 的业务方退回到 height，
+// 起始高度在 height 之后的业务方退回到起始高度之前
+func (db *businessDB) RollbackBusinessSyncHeight(height uint64) error {
+	query := db.gorm.Table("business").Where("sync_height > ?", height)
+	if db.chain != "" {
+		query = query.Where("chain = ?", db.chain)
+	}
+	result := query.Update("sync_height", gorm.Expr("GREATEST(?, start_height - 1)", height))
+	if result.Error != nil {
+		log.Error("rollback business sync height fail", "height", height, "err", result.Error)
+		return result.Error
+	}
+	return nil
+}
+
+// LockBusiness 获取业务方的事务级 advisory lock，事务结束时自动释放；需要在事务中调用，
+// 扫块、重扫和重组回滚写入同一业务方的数据时串行执行，它们可能运行在不同进程中
+func (db *businessDB) LockBusiness(businessUid string) error {
+	err := db.gorm.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "business_"+businessUid).Error
+	if err != nil {
+		log.Error("lock business fail", "businessId", businessUid, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessScanGate(t *testing.T) {
+	tests := []struct {
+		name         string
+		business     Business
+		tip          uint64
+		catchingUp   bool
+		startPending bool
+	}{
+		{"follows live scan", Business{SyncHeight: 100}, 100, false, false},
+		{"behind tip", Business{StartHeight: 50, SyncHeight: 80}, 100, true, false},
+		{"start height ahead", Business{StartHeight: 120, SyncHeight: 119}, 100, false, true},
+		{"start height is next block", Business{StartHeight: 101, SyncHeight: 100}, 100, false, false},
+		// 重组回滚到 100 后扫块进度退回到祖先，替换链上的区块由实时扫块重新分类
+		{"cursor rolled back to ancestor", Business{StartHeight: 50, SyncHeight: 100}, 100, false, false},
+		// 扫块进度还停留在孤块上时同样加入实时扫块，不能因为进度超过 tip 而跳过替换链
+		{"cursor ahead after reorg", Business{StartHeight: 50, SyncHeight: 102}, 100, false, false},
+		{"zero start height ahead", Business{SyncHeight: 102}, 100, false, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.catchingUp, tt.business.CatchingUp(tt.tip), tt.name)
+		require.Equal(t, tt.startPending, tt.business.StartPending(tt.tip), tt.name)
+	}
+}
@@ -22,12 +22,14 @@ type ChildTxs struct {
 
 type ChildTxsView interface {
 	QueryChildTxnByTxId(businessId string, txId string) ([]ChildTxs, error)
+	QueryChildTxnByHashes(businessId string, hashes []string, txTypes []string) ([]ChildTxs, error)
 }
 
 type ChildTxsDB interface {
 	ChildTxsView
 
 	StoreChildTxs(businessId string, txs []ChildTxs) error
+	ReplaceChildTxs(businessId string, txs []ChildTxs) error
 }
 
 type childTxsDB struct {
@@ -47,6 +49,26 @@ func (c childTxsDB) StoreChildTxs(businessId string, txs []ChildTxs) error {
 	return nil
 }
 
+// ReplaceChildTxs 保存扫块得到的子交易，先删除相同交易 hash 和类型下已有的记录，
+// 构建提现时按 tx_id 记录的子交易不受影响
+func (c childTxsDB) ReplaceChildTxs(businessId string, txs []ChildTxs) error {
+	hashes := make(map[string][]string)
+	for _, tx := range txs {
+		hashes[tx.TxType] = append(hashes[tx.TxType], tx.Hash)
+	}
+	for txType, txHashes := range hashes {
+		err := c.gorm.Table("child_txs_"+businessId).Where("tx_id = '' and tx_type = ? and hash IN ?", txType, txHashes).Delete(&ChildTxs{}).Error
+		if err != nil {
+			log.Error("delete child txn fail", "err", err)
+			return err
+		}
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	return c.StoreChildTxs(businessId, txs)
+}
+
 func (c childTxsDB) QueryChildTxnByTxId(businessId string, txId string) ([]ChildTxs, error) {
 	var childTxList []ChildTxs
 	err := c.gorm.Table("child_txs_"+businessId).Where("tx_id = ?", txId).Find(&childTxList).Error
@@ -56,3 +78,16 @@ func (c childTxsDB) QueryChildTxnByTxId(businessId string, txId string) ([]Child
 	}
 	return childTxList, nil
 }
+
+func (c childTxsDB) QueryChildTxnByHashes(businessId string, hashes []string, txTypes []string) ([]ChildTxs, error) {
+	var childTxList []ChildTxs
+	if len(hashes) == 0 {
+		return childTxList, nil
+	}
+	err := c.gorm.Table("child_txs_"+businessId).Where("hash IN ? and tx_type IN ?", hashes, txTypes).Find(&childTxList).Error
+	if err != nil {
+		log.Error("query child txn by hashes fail", "err", err)
+		return nil, err
+	}
+	return childTxList, nil
+}
@@ -6,6 +6,7 @@ type TxStatus string
 const (
 	//====================父交易的状态==========================
 	TxStatusWaitSign             TxStatus = "wait_sign"           // 交易等待签名
+	TxStatusSigned               TxStatus = "signed"              // 交易已签名，等待发送
 	TxStatusUnSent               TxStatus = "unsend"              // 交易未发送
 	TxStatusSent                 TxStatus = "sent"                // 交易已发送
 	TxStatusSentNotify           TxStatus = "sent_notify_success" // 交易以广播通知
@@ -24,17 +25,173 @@ const (
 	TxStatusSafeNotifyFail      TxStatus = "safe_notify_fail"         // 交易过了安全确认位通知失败
 	TxStatusFinalizedNotifyFail TxStatus = "finalized_notify_fail"    // 交易完成通知失败
 
-	TxStatusSuccess        TxStatus = "done_success"
-	TxStatusFail           TxStatus = "done_fail"
-	TxStatusFailNotify     TxStatus = "done_fail_notify_success"
-	TxStatusFailNotifyFail TxStatus = "done_fail_notify_fail"
+	TxStatusSuccess           TxStatus = "done_success"
+	TxStatusSuccessNotify     TxStatus = "done_success_notify_success"
+	TxStatusSuccessNotifyFail TxStatus = "done_success_notify_fail"
+	TxStatusFail              TxStatus = "done_fail"
+	TxStatusFailNotify        TxStatus = "done_fail_notify_success"
+	TxStatusFailNotifyFail    TxStatus = "done_fail_notify_fail"
 
 	TxStatusFallback           TxStatus = "fallback"                // 交易回滚状态
 	TxStatusFallbackNotify     TxStatus = "fallback_notify_success" // 交易回滚通知成功
 	TxStatusFallbackNotifyFail TxStatus = "fallback_notify_fail"    // 交易回滚通知失败
 	TxStatusFallbackDone       TxStatus = "done_fallback"           // 交易回滚状态
 
+	TxStatusStuck             TxStatus = "stuck"                  // 提现广播后超时未确认
+	TxStatusDropped           TxStatus = "dropped"                // 交易已不在内存池中，也没有上链
+	TxStatusDroppedNotify     TxStatus = "dropped_notify_success" // 内存池充值被丢弃已通知
+	TxStatusDroppedNotifyFail TxStatus = "dropped_notify_fail"    // 内存池充值被丢弃通知失败
+
+	TxStatusConflicted           TxStatus = "conflicted"                // 内存池充值的输入被其他交易花费（双花或 RBF 替换）
+	TxStatusConflictedNotify     TxStatus = "conflicted_notify_success" // 充值冲突已通知
+	TxStatusConflictedNotifyFail TxStatus = "conflicted_notify_fail"    // 充值冲突通知失败
+
+	TxStatusPending           TxStatus = "pending"                // 内存池中发现的充值，还没有上链
+	TxStatusPendingNotify     TxStatus = "pending_notify_success" // 内存池充值已通知
+	TxStatusPendingNotifyFail TxStatus = "pending_notify_fail"    // 内存池充值通知失败
+
+	TxStatusReplaceWaitSign TxStatus = "replace_wait_sign" // 自动加速生成的替换交易，等待推送业务方签名
+	TxStatusReplaced        TxStatus = "replaced"          // 同一提现的其他版本已上链
+
 	TxStatusInternalCallBack TxStatus = "send_to_business_for_sign"
+	TxStatusWalletDone       TxStatus = "wallet_done" // 钱包侧处理完成，等待链上确认
 
 	//====================子交易的状体==========================
 )
+
+// InternalTxTypeCpfp 为加速低费率充值构建的 CPFP 子交易
+const InternalTxTypeCpfp = "cpfp"
+
+// FallbackStatuses 已进入回滚流程的状态，回滚检测时跳过这些交易
+var FallbackStatuses = []TxStatus{
+	TxStatusFallback,
+	TxStatusFallbackNotify,
+	TxStatusFallbackNotifyFail,
+	TxStatusFallbackDone,
+}
+
+// NotifyTransition 通知业务方成功、失败之后交易进入的状态
+type NotifyTransition struct {
+	Success TxStatus
+	Fail    TxStatus
+}
+
+// NotifyTransitions 需要通知业务方的状态；通知失败的状态在下一轮会重新通知
+var NotifyTransitions = map[TxStatus]NotifyTransition{
+	TxStatusUnSafe:               {TxStatusUnSafeNotify, TxStatusUnSafeNotifyFail},
+	TxStatusUnSafeNotifyFail:     {TxStatusUnSafeNotify, TxStatusUnSafeNotifyFail},
+	TxStatusSafe:                 {TxStatusSafeNotify, TxStatusSafeNotifyFail},
+	TxStatusSafeNotifyFail:       {TxStatusSafeNotify, TxStatusSafeNotifyFail},
+	TxStatusFinalized:            {TxStatusFinalizedNotify, TxStatusFinalizedNotifyFail},
+	TxStatusFinalizedNotifyFail:  {TxStatusFinalizedNotify, TxStatusFinalizedNotifyFail},
+	TxStatusSent:                 {TxStatusSentNotify, TxStatusSentNotifyFail},
+	TxStatusSentNotifyFail:       {TxStatusSentNotify, TxStatusSentNotifyFail},
+	TxStatusWithdrawed:           {TxStatusWithdrawedNotify, TxStatusWithdrawedNotifyFail},
+	TxStatusWithdrawedNotifyFail: {TxStatusWithdrawedNotify, TxStatusWithdrawedNotifyFail},
+	TxStatusSuccess:              {TxStatusSuccessNotify, TxStatusSuccessNotifyFail},
+	TxStatusSuccessNotifyFail:    {TxStatusSuccessNotify, TxStatusSuccessNotifyFail},
+	TxStatusFail:                 {TxStatusFailNotify, TxStatusFailNotifyFail},
+	TxStatusFailNotifyFail:       {TxStatusFailNotify, TxStatusFailNotifyFail},
+	TxStatusFallback:             {TxStatusFallbackNotify, TxStatusFallbackNotifyFail},
+	TxStatusFallbackNotifyFail:   {TxStatusFallbackNotify, TxStatusFallbackNotifyFail},
+	TxStatusReplaceWaitSign:      {TxStatusWaitSign, TxStatusReplaceWaitSign},
+	TxStatusPending:              {TxStatusPendingNotify, TxStatusPendingNotifyFail},
+	TxStatusPendingNotifyFail:    {TxStatusPendingNotify, TxStatusPendingNotifyFail},
+	TxStatusDropped:              {TxStatusDroppedNotify, TxStatusDroppedNotifyFail},
+	TxStatusDroppedNotifyFail:    {TxStatusDroppedNotify, TxStatusDroppedNotifyFail},
+	TxStatusConflicted:           {TxStatusConflictedNotify, TxStatusConflictedNotifyFail},
+	TxStatusConflictedNotifyFail: {TxStatusConflictedNotify, TxStatusConflictedNotifyFail},
+}
+
+// 各类交易需要通知业务方的状态
+var (
+	DepositNotifyStatuses = []TxStatus{
+		TxStatusPending, TxStatusPendingNotifyFail,
+		TxStatusDropped, TxStatusDroppedNotifyFail,
+		TxStatusConflicted, TxStatusConflictedNotifyFail,
+		TxStatusUnSafe, TxStatusUnSafeNotifyFail,
+		TxStatusSafe, TxStatusSafeNotifyFail,
+		TxStatusFinalized, TxStatusFinalizedNotifyFail,
+		TxStatusFallback, TxStatusFallbackNotifyFail,
+	}
+	WithdrawNotifyStatuses = []TxStatus{
+		TxStatusSent, TxStatusSentNotifyFail,
+		TxStatusWithdrawed, TxStatusWithdrawedNotifyFail,
+		TxStatusFail, TxStatusFailNotifyFail,
+		TxStatusFallback, TxStatusFallbackNotifyFail,
+		TxStatusReplaceWaitSign,
+	}
+	InternalNotifyStatuses = []TxStatus{
+		TxStatusSuccess, TxStatusSuccessNotifyFail,
+		TxStatusFail, TxStatusFailNotifyFail,
+		TxStatusFallback, TxStatusFallbackNotifyFail,
+	}
+)
+
+// UnConfirmedDepositStatuses 还没有过确认位的充值状态
+var UnConfirmedDepositStatuses = []TxStatus{
+	TxStatusUnSafe,
+	TxStatusUnSafeNotify,
+	TxStatusUnSafeNotifyFail,
+}
+
+// PendingDepositStatuses 内存池中发现、还没有上链的充值状态
+var PendingDepositStatuses = []TxStatus{
+	TxStatusPending,
+	TxStatusPendingNotify,
+	TxStatusPendingNotifyFail,
+}
+
+// ConflictedDepositStatuses 输入被冲突交易花费的内存池充值状态
+var ConflictedDepositStatuses = []TxStatus{
+	TxStatusConflicted,
+	TxStatusConflictedNotify,
+	TxStatusConflictedNotifyFail,
+}
+
+// CpfpOpenStatuses 还没有上链的 CPFP 子交易状态，被加速的充值丢弃或冲突后置为失败
+var CpfpOpenStatuses = []TxStatus{
+	TxStatusWaitSign,
+	TxStatusSigned,
+	TxStatusUnSent,
+	TxStatusSent,
+	TxStatusSentNotify,
+	TxStatusSentNotifyFail,
+}
+
+// WithdrawPendingStatuses 还没有广播的提现状态，超时后置为失败并释放锁定的 utxo
+var WithdrawPendingStatuses = []TxStatus{
+	TxStatusWaitSign,
+	TxStatusSigned,
+	TxStatusUnSent,
+	TxStatusReplaceWaitSign,
+}
+
+// WithdrawSignableStatuses 可以写入签名结果的提现状态
+var WithdrawSignableStatuses = []TxStatus{
+	TxStatusWaitSign,
+	TxStatusReplaceWaitSign,
+}
+
+// WithdrawFailStatuses 已失败的提现状态，锁定的 utxo 需要释放
+var WithdrawFailStatuses = []TxStatus{
+	TxStatusFail,
+	TxStatusFailNotify,
+	TxStatusFailNotifyFail,
+}
+
+// WithdrawReleaseStatuses 失败或从内存池丢弃的提现状态，原始提现和所有替换交易都处于这些状态时释放锁定的 utxo
+var WithdrawReleaseStatuses = []TxStatus{
+	TxStatusFail,
+	TxStatusFailNotify,
+	TxStatusFailNotifyFail,
+	TxStatusDropped,
+}
+
+// WithdrawTrackStatuses 已广播、等待确认的提现状态
+var WithdrawTrackStatuses = []TxStatus{
+	TxStatusSent,
+	TxStatusSentNotify,
+	TxStatusSentNotifyFail,
+	TxStatusStuck,
+}
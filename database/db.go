@@ -16,6 +16,8 @@ import (
 
 type DB struct {
 	gorm *gorm.DB
+	// chain 区块、重组区块和业务方列表所属的链，为空时不按链区分
+	chain string
 
 	CreateTable  CreateTableDB
 	Blocks       BlocksDB
@@ -62,42 +64,39 @@ func NewDB(ctx context.Context, dbConfig config.DBConfig) (*DB, error) {
 		return nil, err
 	}
 
-	db := &DB{
+	return newChainDB(gorm, ""), nil
+}
+
+// WithChain 返回按 chain 区分区块、重组区块和业务方列表的 DB，与原 DB 共用连接；
+// 其余表按业务方分表，业务方只接入一条链，不需要再区分
+func (db *DB) WithChain(chain string) *DB {
+	return newChainDB(db.gorm, chain)
+}
+
+func (db *DB) Transaction(fn func(db *DB) error) error {
+	return db.gorm.Transaction(func(tx *gorm.DB) error {
+		return fn(newChainDB(tx, db.chain))
+	})
+}
+
+func newChainDB(gorm *gorm.DB, chain string) *DB {
+	return &DB{
 		gorm:         gorm,
+		chain:        chain,
 		CreateTable:  NewCreateTableDB(gorm),
-		Blocks:       NewBlocksDB(gorm),
-		ReorgBlocks:  NewReorgBlocksDB(gorm),
+		Blocks:       NewBlocksDB(gorm, chain),
+		ReorgBlocks:  NewReorgBlocksDB(gorm, chain),
 		Addresses:    NewAddressesDB(gorm),
 		Balances:     NewBalancesDB(gorm),
-		Business:     NewBusinessDB(gorm),
+		Business:     NewBusinessDB(gorm, chain),
 		Deposits:     NewDepositsDB(gorm),
 		Withdraws:    NewWithdrawsDB(gorm),
 		Internals:    NewInternalsDB(gorm),
 		Transactions: NewTransactionsDB(gorm),
 		Vins:         NewVinsDB(gorm),
 		Vouts:        NewVoutsDB(gorm),
+		ChildTxs:     NewChildTxsDB(gorm),
 	}
-	return db, nil
-}
-
-func (db *DB) Transaction(fn func(db *DB) error) error {
-	return db.gorm.Transaction(func(tx *gorm.DB) error {
-		txDB := &DB{
-			gorm:         tx,
-			Blocks:       NewBlocksDB(tx),
-			ReorgBlocks:  NewReorgBlocksDB(tx),
-			Addresses:    NewAddressesDB(tx),
-			Balances:     NewBalancesDB(tx),
-			Business:     NewBusinessDB(tx),
-			Deposits:     NewDepositsDB(tx),
-			Withdraws:    NewWithdrawsDB(tx),
-			Internals:    NewInternalsDB(tx),
-			Transactions: NewTransactionsDB(tx),
-			Vins:         NewVinsDB(tx),
-			Vouts:        NewVoutsDB(tx),
-		}
-		return fn(txDB)
-	})
 }
 
 func (db *DB) Close() error {
@@ -2,11 +2,13 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Deposits struct {
@@ -17,21 +19,32 @@ type Deposits struct {
 	Fee         *big.Int `gorm:"serializer:u256"`
 	LockTime    *big.Int `gorm:"serializer:u256"`
 	Version     string   `json:"version"`
-	Confirms    uint8    `json:"confirms"`
+	Confirms    uint16   `json:"confirms"`
 	Status      TxStatus `json:"status"`
-	Timestamp   uint64   `json:"timestamp"`
+	// SeenHeight 内存池中发现充值时已同步的区块高度，ConflictHash 为花费了相同输入的冲突交易
+	SeenHeight   uint64 `json:"seen_height"`
+	ConflictHash string `json:"conflict_hash"`
+	Timestamp    uint64 `json:"timestamp"`
 }
 
 type DepositsView interface {
 	QueryNotifyDeposits(string) ([]Deposits, error)
+	QueryFallbackDeposits(requestId string) ([]Deposits, error)
+	QueryDepositsByStatus(requestId string, status TxStatus) ([]Deposits, error)
+	QueryPendingDeposits(requestId string) ([]Deposits, error)
+	QueryDepositHashes(requestId string, hashes []string) (map[string]bool, error)
 }
 
 type DepositsDB interface {
 	DepositsView
 
 	StoreDeposits(string, []Deposits) error
+	UpdateDepositsStatus(requestId string, status TxStatus, depositList []Deposits) error
 	UpdateDepositsComfirms(requestId string, blockNumber uint64, confirms uint64) error
-	UpdateDepositsNotifyStatus(requestId string, status uint8, depositList []Deposits) error
+	UpdateDepositsNotifyStatus(requestId string, status TxStatus, depositList []Deposits) error
+	UpdatePendingDepositsMined(requestId string, depositList []Deposits) ([]Deposits, error)
+	UpdateOrphanedDepositsMined(requestId string, depositList []Deposits) (map[string]bool, error)
+	UpdateDepositConflicted(requestId string, deposit Deposits, conflictHash string) error
 }
 
 type depositsDB struct {
@@ -42,8 +55,12 @@ func NewDepositsDB(db *gorm.DB) DepositsDB {
 	return &depositsDB{gorm: db}
 }
 
+// StoreDeposits 按交易 hash 去重，已经记录过的充值保持原状态
 func (db *depositsDB) StoreDeposits(requestId string, depositList []Deposits) error {
-	result := db.gorm.Table("deposits_"+requestId).CreateInBatches(&depositList, len(depositList))
+	result := db.gorm.Table("deposits_"+requestId).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoNothing: true,
+	}).CreateInBatches(&depositList, len(depositList))
 	if result.Error != nil {
 		log.Error("create deposit batch fail", "Err", result.Error)
 		return result.Error
@@ -51,22 +68,85 @@ func (db *depositsDB) StoreDeposits(requestId string, depositList []Deposits) er
 	return nil
 }
 
+// QueryNotifyDeposits 查询状态变化后还没有成功通知业务方的充值
 func (db *depositsDB) QueryNotifyDeposits(requestId string) ([]Deposits, error) {
 	var notifyDeposits []Deposits
-	result := db.gorm.Table("deposits_"+requestId).Where("status = ? or status = ?", 0, 1).Find(notifyDeposits)
+	result := db.gorm.Table("deposits_"+requestId).Where("status IN ?", DepositNotifyStatuses).Find(&notifyDeposits)
 	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, gorm.ErrRecordNotFound
-		}
 		return nil, result.Error
 	}
 	return notifyDeposits, nil
 }
 
+// QueryFallbackDeposits 查询所在区块已被重组掉、还没有进入回滚流程的充值
+func (db *depositsDB) QueryFallbackDeposits(requestId string) ([]Deposits, error) {
+	var depositList []Deposits
+	result := db.gorm.Table("deposits_"+requestId).
+		Where(orphanedBlockCondition).
+		Where("status NOT IN ?", FallbackStatuses).
+		Find(&depositList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return depositList, nil
+}
+
+func (db *depositsDB) QueryDepositsByStatus(requestId string, status TxStatus) ([]Deposits, error) {
+	var depositList []Deposits
+	result := db.gorm.Table("deposits_"+requestId).Where("status = ?", status).Find(&depositList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return depositList, nil
+}
+
+// QueryPendingDeposits 查询内存池中发现、还没有上链的充值
+func (db *depositsDB) QueryPendingDeposits(requestId string) ([]Deposits, error) {
+	var depositList []Deposits
+	result := db.gorm.Table("deposits_"+requestId).Where("status IN ?", PendingDepositStatuses).Find(&depositList)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return depositList, nil
+}
+
+// QueryDepositHashes 返回已经记录过充值的交易 hash
+func (db *depositsDB) QueryDepositHashes(requestId string, hashes []string) (map[string]bool, error) {
+	exists := make(map[string]bool)
+	if len(hashes) == 0 {
+		return exists, nil
+	}
+	var existHashes []string
+	result := db.gorm.Table("deposits_"+requestId).Where("hash IN ?", hashes).Pluck("hash", &existHashes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, hash := range existHashes {
+		exists[hash] = true
+	}
+	return exists, nil
+}
+
+func (db *depositsDB) UpdateDepositsStatus(requestId string, status TxStatus, depositList []Deposits) error {
+	if len(depositList) == 0 {
+		return nil
+	}
+	var guids []uuid.UUID
+	for _, deposit := range depositList {
+		guids = append(guids, deposit.GUID)
+	}
+	result := db.gorm.Table("deposits_"+requestId).Where("guid IN ?", guids).Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("batch update deposits status failed: %w", result.Error)
+	}
+	log.Info("Batch update deposits status success", "requestId", requestId, "count", result.RowsAffected, "status", status)
+	return nil
+}
+
 // UpdateDepositsComfirms 查询所有还没有过确认位交易，用最新区块减去对应区块更新确认，如果这个大于我们预设的确认位，那么这笔交易可以认为已经入账
 func (db *depositsDB) UpdateDepositsComfirms(requestId string, blockNumber uint64, confirms uint64) error {
 	var unConfirmDeposits []Deposits
-	result := db.gorm.Table("deposits_"+requestId).Where("block_number <= ? and status = ?", blockNumber, 0).Find(&unConfirmDeposits)
+	result := db.gorm.Table("deposits_"+requestId).Where("block_number <= ? and status IN ?", blockNumber, UnConfirmedDepositStatuses).Find(&unConfirmDeposits)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil
@@ -76,10 +156,10 @@ func (db *depositsDB) UpdateDepositsComfirms(requestId string, blockNumber uint6
 	for _, deposit := range unConfirmDeposits {
 		chainConfirm := blockNumber - deposit.BlockNumber.Uint64()
 		if chainConfirm >= confirms {
-			deposit.Confirms = uint8(confirms)
-			deposit.Status = 1 // 已经过了确认位
+			deposit.Confirms = uint16(confirms)
+			deposit.Status = TxStatusSafe // 已经过了确认位
 		} else {
-			deposit.Confirms = uint8(chainConfirm)
+			deposit.Confirms = uint16(chainConfirm)
 		}
 		err := db.gorm.Table("deposits_" + requestId).Save(&deposit).Error
 		if err != nil {
@@ -89,21 +169,89 @@ func (db *depositsDB) UpdateDepositsComfirms(requestId string, blockNumber uint6
 	return nil
 }
 
-func (db *depositsDB) UpdateDepositsNotifyStatus(requestId string, status uint8, depositList []Deposits) error {
-	for i := 0; i < len(depositList); i++ {
-		var depositSingle = Deposits{}
-		result := db.gorm.Table("deposits_" + requestId).Where(&Transactions{Hash: depositList[i].Hash}).Take(&depositSingle)
+// UpdateDepositsNotifyStatus 记录通知结果，只更新通知期间状态没有变化的充值
+func (db *depositsDB) UpdateDepositsNotifyStatus(requestId string, status TxStatus, depositList []Deposits) error {
+	for _, deposit := range depositList {
+		result := db.gorm.Table("deposits_"+requestId).
+			Where("guid = ? and status = ?", deposit.GUID, deposit.Status).
+			Update("status", status)
 		if result.Error != nil {
-			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				return nil
-			}
-			return result.Error
+			return fmt.Errorf("update deposit notify status failed: %w", result.Error)
 		}
-		depositSingle.Status = status
-		err := db.gorm.Table("transactions_" + requestId).Save(&depositSingle).Error
-		if err != nil {
-			return err
+	}
+	return nil
+}
+
+// UpdatePendingDepositsMined 扫块发现的充值如果之前在内存池中记录过或者已经回滚，更新所在区块并进入 unsafe 流程，
+// 返回之前没有记录过、需要新建的充值
+func (db *depositsDB) UpdatePendingDepositsMined(requestId string, depositList []Deposits) ([]Deposits, error) {
+	// 被判定为丢弃或冲突的交易也可能重新广播后上链，重组回滚的交易也可能在新的区块中上链
+	statuses := []TxStatus{TxStatusDropped, TxStatusDroppedNotify, TxStatusDroppedNotifyFail}
+	statuses = append(statuses, PendingDepositStatuses...)
+	statuses = append(statuses, ConflictedDepositStatuses...)
+	statuses = append(statuses, FallbackStatuses...)
+
+	var newDeposits []Deposits
+	for _, deposit := range depositList {
+		fee := uint64(0)
+		if deposit.Fee != nil {
+			fee = deposit.Fee.Uint64()
 		}
+		result := db.gorm.Table("deposits_"+requestId).
+			Where("hash = ? and status IN ?", deposit.Hash, statuses).
+			Updates(map[string]interface{}{
+				"block_hash":   deposit.BlockHash,
+				"block_number": deposit.BlockNumber.Uint64(),
+				"fee":          fee,
+				"status":       deposit.Status,
+			})
+		if result.Error != nil {
+			return nil, fmt.Errorf("update pending deposit mined failed: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			newDeposits = append(newDeposits, deposit)
+		}
+	}
+	return newDeposits, nil
+}
+
+// UpdateOrphanedDepositsMined 所在区块被重组掉、还没有进入回滚流程的充值在新的区块中重新上链时，更新所在区块并回到 unsafe 流程；
+// 这些充值的余额没有被撤销，返回它们的交易 hash，重新上链时不再计入余额
+func (db *depositsDB) UpdateOrphanedDepositsMined(requestId string, depositList []Deposits) (map[string]bool, error) {
+	remined := make(map[string]bool)
+	for _, deposit := range depositList {
+		result := db.gorm.Table("deposits_"+requestId).
+			Where("hash = ? and block_hash <> ? and status NOT IN ?", deposit.Hash, deposit.BlockHash, FallbackStatuses).
+			Where(orphanedBlockCondition).
+			Updates(map[string]interface{}{
+				"block_hash":   deposit.BlockHash,
+				"block_number": deposit.BlockNumber.Uint64(),
+				"confirms":     0,
+				"status":       deposit.Status,
+			})
+		if result.Error != nil {
+			return nil, fmt.Errorf("update orphaned deposit mined failed: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Info("orphaned deposit mined again", "requestId", requestId, "hash", deposit.Hash, "blockHash", deposit.BlockHash)
+			remined[deposit.Hash] = true
+		}
+	}
+	return remined, nil
+}
+
+// UpdateDepositConflicted 内存池充值的输入被其他交易花费，记录冲突交易并置为 conflicted；
+// 按查询时的状态更新，扫块已经把充值更新为上链状态时不会被覆盖
+func (db *depositsDB) UpdateDepositConflicted(requestId string, deposit Deposits, conflictHash string) error {
+	result := db.gorm.Table("deposits_"+requestId).
+		Where("guid = ? and status = ?", deposit.GUID, deposit.Status).
+		Updates(map[string]interface{}{
+			"status":        TxStatusConflicted,
+			"conflict_hash": conflictHash,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("update deposit conflicted failed: %w", result.Error)
 	}
+	log.Info("Update deposit conflicted success", "requestId", requestId, "hash", deposit.Hash, "conflictHash", conflictHash, "count", result.RowsAffected)
 	return nil
 }
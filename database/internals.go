@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -12,7 +13,7 @@ import (
 type Internals struct {
 	Guid        uuid.UUID `gorm:"primaryKey" json:"guid"`
 	BlockHash   string    `json:"block_hash"`
-	BlockNumber *big.Int  `gorm:"serializer:u256;check:block_number > 0" json:"block_number"`
+	BlockNumber *big.Int  `gorm:"serializer:u256;check:block_number >= 0" json:"block_number"`
 	Hash        string    `json:"hash"`
 	Fee         *big.Int  `gorm:"serializer:u256" json:"fee"`
 	LockTime    *big.Int  `gorm:"serializer:u256" json:"lock_time"`
@@ -26,6 +27,9 @@ type Internals struct {
 type InternalsView interface {
 	QueryNotifyInternal(requestId string) ([]Internals, error)
 	UnSendInternalsList(requestId string) ([]Internals, error)
+	QueryFallbackInternals(requestId string) ([]Internals, error)
+	QueryInternalsByStatus(requestId string, status TxStatus) ([]Internals, error)
+	QueryInternalByGuid(requestId string, guid string) (*Internals, error)
 }
 
 type InternalsDB interface {
@@ -34,6 +38,12 @@ type InternalsDB interface {
 	StoreInternal(string, *Internals) error
 	UpdateInternalTx(requestId string, transactionId string, signedTx string, status TxStatus) error
 	UpdateInternalStatus(requestId string, status TxStatus, internalsList []Internals) error
+	UpdateInternalStatusByGuids(requestId string, status TxStatus, internalsList []Internals) error
+	UpdateInternalsOnChain(requestId string, internalsList []Internals) error
+	UpdateOrphanedInternalsMined(requestId string, internalsList []Internals) (map[string]bool, error)
+	UpdateInternalsNotifyStatus(requestId string, status TxStatus, internalsList []Internals) error
+	UpdateInternalsSent(requestId string, internalsList []Internals) error
+	FailCpfpInternals(requestId string, parentHashes []string) error
 }
 
 type internalsDB struct {
@@ -44,10 +54,11 @@ func NewInternalsDB(db *gorm.DB) InternalsDB {
 	return &internalsDB{gorm: db}
 }
 
+// QueryNotifyInternal 查询状态变化后还没有成功通知业务方的内部交易
 func (db *internalsDB) QueryNotifyInternal(requestId string) ([]Internals, error) {
 	var notifyInternals []Internals
 	result := db.gorm.Table("internals_"+requestId).
-		Where("status = ?", TxStatusWalletDone).
+		Where("status IN ?", InternalNotifyStatuses).
 		Find(&notifyInternals)
 	if result.Error != nil {
 		return nil, result.Error
@@ -131,3 +142,154 @@ func (db *internalsDB) UnSendInternalsList(requestId string) ([]Internals, error
 	}
 	return internalsList, nil
 }
+
+// QueryFallbackInternals 查询所在区块已被重组掉、还没有进入回滚流程的内部交易
+func (db *internalsDB) QueryFallbackInternals(requestId string) ([]Internals, error) {
+	var internalsList []Internals
+	err := db.gorm.Table("internals_"+requestId).
+		Where(orphanedBlockCondition).
+		Where("status NOT IN ?", FallbackStatuses).
+		Find(&internalsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return internalsList, nil
+}
+
+func (db *internalsDB) QueryInternalsByStatus(requestId string, status TxStatus) ([]Internals, error) {
+	var internalsList []Internals
+	err := db.gorm.Table("internals_"+requestId).
+		Where("status = ?", status).
+		Find(&internalsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return internalsList, nil
+}
+
+func (db *internalsDB) QueryInternalByGuid(requestId string, guid string) (*Internals, error) {
+	var internal Internals
+	err := db.gorm.Table("internals_"+requestId).Where("guid = ?", guid).Take(&internal).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &internal, nil
+}
+
+func (db *internalsDB) UpdateInternalStatusByGuids(requestId string, status TxStatus, internalsList []Internals) error {
+	if len(internalsList) == 0 {
+		return nil
+	}
+	var guids []uuid.UUID
+	for _, internal := range internalsList {
+		guids = append(guids, internal.Guid)
+	}
+	result := db.gorm.Table("internals_"+requestId).
+		Where("guid IN ?", guids).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("batch update status failed: %w", result.Error)
+	}
+	log.Info("Batch update internals status success", "requestId", requestId, "count", result.RowsAffected, "status", status)
+	return nil
+}
+
+// UpdateInternalsOnChain 扫块发现内部交易上链后，按交易 hash 记录所在区块并更新为成功；
+// CPFP 子交易扫块时会被识别为归集，保留原来的 cpfp 类型。需要先调用 UpdateOrphanedInternalsMined，
+// 剩下的孤块内部交易都已进入回滚流程、余额已经撤销，重新上链时回到成功状态
+func (db *internalsDB) UpdateInternalsOnChain(requestId string, internalsList []Internals) error {
+	for _, internal := range internalsList {
+		result := db.gorm.Table("internals_"+requestId).
+			Where("hash = ?", internal.Hash).
+			Updates(map[string]interface{}{
+				"block_hash":   internal.BlockHash,
+				"block_number": internal.BlockNumber.Uint64(),
+				"tx_type":      gorm.Expr("CASE WHEN tx_type = ? THEN tx_type ELSE ? END", InternalTxTypeCpfp, internal.TxType),
+				"status":       TxStatusSuccess,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("update internal on chain failed: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			log.Warn("No internal matched on chain tx", "requestId", requestId, "hash", internal.Hash)
+		}
+	}
+	return nil
+}
+
+// UpdateOrphanedInternalsMined 所在区块被重组掉、还没有进入回滚流程的内部交易在新的区块中重新上链时，更新所在区块并回到成功状态；
+// 这些内部交易的余额没有被撤销，返回它们的交易 hash，重新上链时不再计入余额
+func (db *internalsDB) UpdateOrphanedInternalsMined(requestId string, internalsList []Internals) (map[string]bool, error) {
+	remined := make(map[string]bool)
+	for _, internal := range internalsList {
+		result := db.gorm.Table("internals_"+requestId).
+			Where("hash = ? and block_hash <> ? and status NOT IN ?", internal.Hash, internal.BlockHash, FallbackStatuses).
+			Where(orphanedBlockCondition).
+			Updates(map[string]interface{}{
+				"block_hash":   internal.BlockHash,
+				"block_number": internal.BlockNumber.Uint64(),
+				"status":       TxStatusSuccess,
+			})
+		if result.Error != nil {
+			return nil, fmt.Errorf("update orphaned internal mined failed: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Info("orphaned internal mined again", "requestId", requestId, "hash", internal.Hash, "blockHash", internal.BlockHash)
+			remined[internal.Hash] = true
+		}
+	}
+	return remined, nil
+}
+
+// UpdateInternalsNotifyStatus 记录通知结果，只更新通知期间状态没有变化的内部交易
+func (db *internalsDB) UpdateInternalsNotifyStatus(requestId string, status TxStatus, internalsList []Internals) error {
+	for _, internal := range internalsList {
+		result := db.gorm.Table("internals_"+requestId).
+			Where("guid = ? and status = ?", internal.Guid, internal.Status).
+			Update("status", status)
+		if result.Error != nil {
+			return fmt.Errorf("update internal notify status failed: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+// UpdateInternalsSent 广播成功后记录交易 hash 并更新为已发送，上链后由扫块更新为成功
+func (db *internalsDB) UpdateInternalsSent(requestId string, internalsList []Internals) error {
+	for _, internal := range internalsList {
+		result := db.gorm.Table("internals_"+requestId).
+			Where("guid = ?", internal.Guid).
+			Updates(map[string]interface{}{
+				"hash":   internal.Hash,
+				"status": TxStatusSent,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("update internal sent failed: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+// FailCpfpInternals 被加速的充值从内存池丢弃或被冲突交易替换后，CPFP 子交易花费的输出已经不存在，
+// 把还没有上链的子交易置为失败；cpfp_vin 子交易的 hash 记录被加速的充值交易
+func (db *internalsDB) FailCpfpInternals(requestId string, parentHashes []string) error {
+	if len(parentHashes) == 0 {
+		return nil
+	}
+	cpfpGuids := db.gorm.Table("child_txs_"+requestId).
+		Select("tx_id").
+		Where("tx_type = ? and hash IN ?", "cpfp_vin", parentHashes)
+	result := db.gorm.Table("internals_"+requestId).
+		Where("tx_type = ? and status IN ? and guid IN (?)", InternalTxTypeCpfp, CpfpOpenStatuses, cpfpGuids).
+		Update("status", TxStatusFail)
+	if result.Error != nil {
+		return fmt.Errorf("fail cpfp internals failed: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Info("Fail cpfp internals of dropped deposits", "requestId", requestId, "count", result.RowsAffected)
+	}
+	return nil
+}
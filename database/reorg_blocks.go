@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/core/types"
@@ -10,11 +11,16 @@ import (
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
 )
 
+// orphanedBlockCondition 匹配所在区块已经被重组掉（在 reorg_blocks 中且不在主链 blocks 中）的交易
+const orphanedBlockCondition = "block_hash IN (SELECT hash FROM reorg_blocks) AND block_hash NOT IN (SELECT hash FROM blocks)"
+
 type ReorgBlocks struct {
 	Hash      string `gorm:"primaryKey"`
 	PrevHash  string
 	Number    *big.Int `gorm:"serializer:u256"`
 	Timestamp uint64
+	Source    string
+	Chain     string `gorm:"primaryKey"`
 }
 
 func ReorgBlockHeaderFromHeader(header *types.Header) syncclient.BlockHeader {
@@ -37,21 +43,30 @@ type ReorgBlocksDB interface {
 }
 
 type reorgBlocksDB struct {
-	gorm *gorm.DB
+	gorm  *gorm.DB
+	chain string
 }
 
-func NewReorgBlocksDB(db *gorm.DB) ReorgBlocksDB {
-	return &reorgBlocksDB{gorm: db}
+func NewReorgBlocksDB(db *gorm.DB, chain string) ReorgBlocksDB {
+	return &reorgBlocksDB{gorm: db, chain: chain}
 }
 
 func (db *reorgBlocksDB) StoreReorgBlocks(headers []ReorgBlocks) error {
-	result := db.gorm.CreateInBatches(&headers, len(headers))
+	for i := range headers {
+		headers[i].Chain = db.chain
+	}
+	// 同一个区块可能在多次重组中被回滚，已经记录过的直接忽略
+	result := db.gorm.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&headers, len(headers))
 	return result.Error
 }
 
 func (db *reorgBlocksDB) LatestReorgBlocks() (*syncclient.BlockHeader, error) {
 	var header ReorgBlocks
-	result := db.gorm.Order("number DESC").Take(&header)
+	query := db.gorm
+	if db.chain != "" {
+		query = query.Where("chain = ?", db.chain)
+	}
+	result := query.Order("number DESC").Take(&header)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"fmt"
 	"math/big"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Transactions struct {
@@ -21,12 +23,15 @@ type Transactions struct {
 }
 
 type TransactionsView interface {
+	QueryTransactionsAfterBlock(requestId string, blockNumber *big.Int) ([]Transactions, error)
+	QueryTransactionTypes(requestId string, hashes []string) (map[string]string, error)
 }
 
 type TransactionsDB interface {
 	TransactionsView
 
 	StoreTransactions(string, []Transactions) error
+	DeleteTransactionsAfterBlock(requestId string, blockNumber *big.Int) error
 }
 
 type tansactionsDB struct {
@@ -37,7 +42,48 @@ func NewTransactionsDB(db *gorm.DB) TransactionsDB {
 	return &tansactionsDB{gorm: db}
 }
 
+// StoreTransactions 按交易 hash 更新或插入，重新识别为 unknown 时保留原来的交易类型
 func (db *tansactionsDB) StoreTransactions(requestId string, transactionsList []Transactions) error {
-	result := db.gorm.Table("transactions_"+requestId).CreateInBatches(&transactionsList, len(transactionsList))
+	tableName := "transactions_" + requestId
+	result := db.gorm.Table(tableName).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"block_hash":   gorm.Expr("excluded.block_hash"),
+			"block_number": gorm.Expr("excluded.block_number"),
+			"fee":          gorm.Expr("excluded.fee"),
+			"tx_type":      gorm.Expr(fmt.Sprintf("CASE WHEN excluded.tx_type = 'unknown' THEN %s.tx_type ELSE excluded.tx_type END", tableName)),
+		}),
+	}).CreateInBatches(&transactionsList, len(transactionsList))
+	return result.Error
+}
+
+func (db *tansactionsDB) QueryTransactionsAfterBlock(requestId string, blockNumber *big.Int) ([]Transactions, error) {
+	var transactionsList []Transactions
+	err := db.gorm.Table("transactions_"+requestId).Where("block_number > ?", blockNumber.Uint64()).Find(&transactionsList).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactionsList, nil
+}
+
+// QueryTransactionTypes 返回已经记录过的交易 hash 及其交易类型
+func (db *tansactionsDB) QueryTransactionTypes(requestId string, hashes []string) (map[string]string, error) {
+	txTypes := make(map[string]string)
+	if len(hashes) == 0 {
+		return txTypes, nil
+	}
+	var transactionsList []Transactions
+	err := db.gorm.Table("transactions_"+requestId).Select("hash", "tx_type").Where("hash IN ?", hashes).Find(&transactionsList).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactionsList {
+		txTypes[transaction.Hash] = transaction.TxType
+	}
+	return txTypes, nil
+}
+
+func (db *tansactionsDB) DeleteTransactionsAfterBlock(requestId string, blockNumber *big.Int) error {
+	result := db.gorm.Table("transactions_"+requestId).Where("block_number > ?", blockNumber.Uint64()).Delete(&Transactions{})
 	return result.Error
 }
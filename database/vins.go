@@ -2,36 +2,52 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrVinsAlreadyLocked = errors.New("vins already locked by other withdraw")
+
 type Vins struct {
 	GUID             uuid.UUID `gorm:"primaryKey" json:"guid"`
 	Address          string    `json:"address"`                                   // 资金来源地址
 	TxId             string    `json:"tx_id"`                                     // 本次交易id
-	Vout             uint8     `json:"vout"`                                      // 上笔交易的输出序号
+	Vout             uint32    `json:"vout"`                                      // 上笔交易的输出序号
 	Script           string    `json:"script"`                                    // 解锁脚本（scriptSig） ，证明可以花费上一个输出
 	Witness          string    `json:"witness"`                                   // 隔离见证数据， 如果是 segwit 交易，放在这里
 	Amount           *big.Int  `gorm:"serializer:u256" json:"amount"`             // 输入金额
 	SpendTxHash      string    `json:"spend_tx_hash"`                             // 花费该输入的交易hash
 	SpendBlockHeight *big.Int  `gorm:"serializer:u256" json:"spend_block_height"` // 被花费所在块高
 	IsSpend          bool      `json:"is_spend"`
+	LockTxId         string    `json:"lock_tx_id"` // 锁定该 utxo 的提现 guid，为空表示未锁定
+	LockedAt         uint64    `json:"locked_at"`
 	Timestamp        uint64    `json:"timestamp"`
 }
 
 type VinsView interface {
 	QueryVinByTxId(businessId, address, txId string) (*Vins, error)
+	QueryVinByOutpoint(businessId, txId string, vout uint32) (*Vins, error)
 	QueryVinsByAddress(businessId, address string) ([]Vins, error)
+	QueryAvailableVins(businessId, address string) ([]Vins, error)
+	QueryVinsByLockTxId(businessId, lockTxId string) ([]Vins, error)
 }
 
 type VinsDB interface {
 	VinsView
 
 	StoreVins(businessId string, vins []Vins) error
-	UpdateVinsTx(businessId, txId, address string, isSpend bool, spendTxHash string, spendBlockHeight *big.Int) error
+	UpdateVinsTx(businessId, txId string, vout uint32, isSpend bool, spendTxHash string, spendBlockHeight *big.Int) error
+	DeleteVinsByTxIds(businessId string, txIds []string) error
+	RevertVinsSpend(businessId string, spendTxHashes []string) error
+	LockVins(businessId string, lockTxId string, guids []uuid.UUID) error
+	UnlockVins(businessId string, lockTxIds []string) error
+	UnlockFailedWithdrawVins(businessId string) error
+	SpendLockedVins(businessId string, spendTxHash string, spendBlockHeight *big.Int) error
 }
 
 type vinsDB struct {
@@ -54,9 +70,19 @@ func (v vinsDB) QueryVinByTxId(businessId, address, txId string) (*Vins, error)
 	return &vinEntry, nil
 }
 
+// QueryVinByOutpoint 按输出所在交易和序号查询 utxo，没有记录时返回 gorm.ErrRecordNotFound
+func (v vinsDB) QueryVinByOutpoint(businessId, txId string, vout uint32) (*Vins, error) {
+	var vinEntry Vins
+	err := v.gorm.Table("vins_"+businessId).Where("tx_id = ? and vout = ?", txId, vout).Take(&vinEntry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &vinEntry, nil
+}
+
 func (v vinsDB) QueryVinsByAddress(businessId, address string) ([]Vins, error) {
 	var vins []Vins
-	err := v.gorm.Table("vins_"+businessId).Where("address = ?", address).Find(vins).Error
+	err := v.gorm.Table("vins_"+businessId).Where("address = ?", address).Find(&vins).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, gorm.ErrRecordNotFound
@@ -66,12 +92,43 @@ func (v vinsDB) QueryVinsByAddress(businessId, address string) ([]Vins, error) {
 	return vins, nil
 }
 
+// QueryAvailableVins 查询地址下未花费且未被提现锁定的 utxo，并对查出的行加锁；
+// 需要在事务中调用，并发的选币会跳过已被其他事务加锁的行
+func (v vinsDB) QueryAvailableVins(businessId, address string) ([]Vins, error) {
+	var vins []Vins
+	err := v.gorm.Table("vins_"+businessId).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("address = ? and is_spend = ? and lock_tx_id = ?", address, false, "").
+		Find(&vins).Error
+	if err != nil {
+		return nil, err
+	}
+	return vins, nil
+}
+
+// QueryVinsByLockTxId 查询提现锁定的 utxo，RBF 替换交易使用与原交易相同的输入
+func (v vinsDB) QueryVinsByLockTxId(businessId, lockTxId string) ([]Vins, error) {
+	var vins []Vins
+	err := v.gorm.Table("vins_"+businessId).
+		Where("lock_tx_id = ? and is_spend = ?", lockTxId, false).
+		Find(&vins).Error
+	if err != nil {
+		return nil, err
+	}
+	return vins, nil
+}
+
+// StoreVins 按 (tx_id, vout) 去重，已经记录过的输出保留花费和锁定状态
 func (v vinsDB) StoreVins(businessId string, vins []Vins) error {
-	result := v.gorm.Table("vins_"+businessId).CreateInBatches(vins, len(vins))
+	result := v.gorm.Table("vins_"+businessId).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_id"}, {Name: "vout"}},
+		DoNothing: true,
+	}).CreateInBatches(vins, len(vins))
 	return result.Error
 }
 
-func (v vinsDB) UpdateVinsTx(businessId, txId, address string, isSpend bool, spendTxHash string, spendBlockHeight *big.Int) error {
+// UpdateVinsTx 按 (tx_id, vout) 更新 utxo 的花费状态，不是本业务方的输出时没有记录，直接忽略
+func (v vinsDB) UpdateVinsTx(businessId, txId string, vout uint32, isSpend bool, spendTxHash string, spendBlockHeight *big.Int) error {
 
 	updates := map[string]interface{}{
 		"is_spend": false,
@@ -83,17 +140,108 @@ func (v vinsDB) UpdateVinsTx(businessId, txId, address string, isSpend bool, spe
 		updates["is_spend"] = true
 	}
 
-	result := v.gorm.Table("internals_"+businessId).
-		Where("txId = ? and address = ?", txId, address).
+	result := v.gorm.Table("vins_"+businessId).
+		Where("tx_id = ? and vout = ?", txId, vout).
+		Updates(updates)
+	return result.Error
+}
+
+// DeleteVinsByTxIds 删除由指定交易产生的 utxo，区块回滚时使用
+func (v vinsDB) DeleteVinsByTxIds(businessId string, txIds []string) error {
+	if len(txIds) == 0 {
+		return nil
+	}
+	result := v.gorm.Table("vins_"+businessId).Where("tx_id IN ?", txIds).Delete(&Vins{})
+	return result.Error
+}
+
+// RevertVinsSpend 将被回滚交易花费掉的 utxo 恢复成未花费状态
+func (v vinsDB) RevertVinsSpend(businessId string, spendTxHashes []string) error {
+	if len(spendTxHashes) == 0 {
+		return nil
+	}
+	updates := map[string]interface{}{
+		"is_spend":           false,
+		"spend_tx_hash":      "",
+		"spend_block_height": 0,
+	}
+	result := v.gorm.Table("vins_"+businessId).
+		Where("spend_tx_hash IN ?", spendTxHashes).
 		Updates(updates)
+	return result.Error
+}
 
+// LockVins 把选中的 utxo 锁定到提现 guid，有任意一个已被锁定时返回 ErrVinsAlreadyLocked
+func (v vinsDB) LockVins(businessId string, lockTxId string, guids []uuid.UUID) error {
+	if len(guids) == 0 {
+		return nil
+	}
+	result := v.gorm.Table("vins_"+businessId).
+		Where("guid IN ? and is_spend = ? and lock_tx_id = ?", guids, false, "").
+		Updates(map[string]interface{}{
+			"lock_tx_id": lockTxId,
+			"locked_at":  time.Now().Unix(),
+		})
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected != int64(len(guids)) {
+		return ErrVinsAlreadyLocked
+	}
+	return nil
+}
+
+// UnlockVins 释放提现锁定但还没有花费的 utxo
+func (v vinsDB) UnlockVins(businessId string, lockTxIds []string) error {
+	if len(lockTxIds) == 0 {
+		return nil
+	}
+	result := v.gorm.Table("vins_"+businessId).
+		Where("lock_tx_id IN ? and is_spend = ?", lockTxIds, false).
+		Updates(map[string]interface{}{
+			"lock_tx_id": "",
+			"locked_at":  0,
+		})
+	return result.Error
+}
 
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+// UnlockFailedWithdrawVins 释放已失败或被丢弃的提现锁定的 utxo；RBF 替换交易沿用原始提现锁定的 utxo，
+// 同组还有其他版本没有失败时不释放，例如广播的替换交易把原始提现挤出内存池后原始提现也会被标记为丢弃
+func (v vinsDB) UnlockFailedWithdrawVins(businessId string) error {
+	tableName := "withdraws_" + businessId
+	releasedGroups := v.gorm.Table(tableName).
+		Select("CASE WHEN replace_guid = '' THEN guid ELSE replace_guid END").
+		Where("status IN ?", WithdrawReleaseStatuses)
+	activeGroups := v.gorm.Table(tableName).
+		Select("CASE WHEN replace_guid = '' THEN guid ELSE replace_guid END").
+		Where("status NOT IN ?", WithdrawReleaseStatuses)
+	result := v.gorm.Table("vins_"+businessId).
+		Where("lock_tx_id IN (?) and lock_tx_id NOT IN (?) and is_spend = ?", releasedGroups, activeGroups, false).
+		Updates(map[string]interface{}{
+			"lock_tx_id": "",
+			"locked_at":  0,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("unlock failed withdraw vins fail: %w", result.Error)
 	}
+	return nil
+}
 
+// SpendLockedVins 提现交易上链后，把该提现锁定的 utxo 记为已花费；
+// RBF 替换交易沿用原始提现锁定的 utxo
+func (v vinsDB) SpendLockedVins(businessId string, spendTxHash string, spendBlockHeight *big.Int) error {
+	withdraws := v.gorm.Table("withdraws_"+businessId).
+		Select("CASE WHEN replace_guid = '' THEN guid ELSE replace_guid END").
+		Where("hash = ?", spendTxHash)
+	result := v.gorm.Table("vins_"+businessId).
+		Where("lock_tx_id IN (?)", withdraws).
+		Updates(map[string]interface{}{
+			"is_spend":           true,
+			"spend_tx_hash":      spendTxHash,
+			"spend_block_height": spendBlockHeight,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("spend locked vins fail: %w", result.Error)
+	}
 	return nil
 }
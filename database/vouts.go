@@ -8,8 +8,9 @@ import (
 
 type Vouts struct {
 	GUID      uuid.UUID `gorm:"primaryKey" json:"guid"`
+	TxId      string    `json:"tx_id"`   // 所属交易 hash
 	Address   string    `json:"address"` // 资金接收方
-	N         uint8     `json:"n"`       // 当前输出在交易里的序号
+	N         uint32    `json:"n"`       // 当前输出在交易里的序号
 	Script    string    `json:"script"`  // 锁定脚本，用于与 vins 的scriptSig验证
 	Amount    *big.Int  `gorm:"serializer:u256" json:"amount"`
 	Timestamp uint64    `json:"timestamp"`
@@ -21,6 +22,8 @@ type VoutsView interface {
 type VoutsDB interface {
 	VoutsView
 	StoreVouts(businessId string, vouts []Vouts) error
+	ReplaceVouts(businessId string, vouts []Vouts) error
+	DeleteVoutsByTxIds(businessId string, txIds []string) error
 }
 
 type voutsDB struct {
@@ -32,6 +35,33 @@ func NewVoutsDB(db *gorm.DB) VoutsDB {
 }
 
 func (v voutsDB) StoreVouts(businessId string, vouts []Vouts) error {
-	result := v.gorm.Table("vouts"+businessId).CreateInBatches(&vouts, len(vouts))
+	result := v.gorm.Table("vouts_"+businessId).CreateInBatches(&vouts, len(vouts))
+	return result.Error
+}
+
+// ReplaceVouts 先删除同一交易已经记录的输入再插入，重复处理同一区块结果不变
+func (v voutsDB) ReplaceVouts(businessId string, vouts []Vouts) error {
+	txIds := make(map[string]bool)
+	var txIdList []string
+	for _, vout := range vouts {
+		if !txIds[vout.TxId] {
+			txIds[vout.TxId] = true
+			txIdList = append(txIdList, vout.TxId)
+		}
+	}
+	if err := v.DeleteVoutsByTxIds(businessId, txIdList); err != nil {
+		return err
+	}
+	if len(vouts) == 0 {
+		return nil
+	}
+	return v.StoreVouts(businessId, vouts)
+}
+
+func (v voutsDB) DeleteVoutsByTxIds(businessId string, txIds []string) error {
+	if len(txIds) == 0 {
+		return nil
+	}
+	result := v.gorm.Table("vouts_"+businessId).Where("tx_id IN ?", txIds).Delete(&Vouts{})
 	return result.Error
 }
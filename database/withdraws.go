@@ -1,24 +1,33 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrWithdrawNotSignable 提现已经签名或者不再等待签名，签名结果不再写入
+var ErrWithdrawNotSignable = errors.New("withdraw is not waiting for signature")
+
 type Withdraws struct {
 	Guid        uuid.UUID `gorm:"primaryKey" json:"guid"`
 	BlockHash   string    `json:"block_hash"`
-	BlockNumber *big.Int  `gorm:"serializer:u256;check:block_number > 0" json:"block_number"`
+	BlockNumber *big.Int  `gorm:"serializer:u256;check:block_number >= 0" json:"block_number"`
 	Hash        string    `json:"hash"`
 	Fee         *big.Int  `gorm:"serializer:u256" json:"fee"`
 	LockTime    *big.Int  `gorm:"serializer:u256" json:"lock_time"`
 	Version     string    `json:"version"`
 	TxSignHex   string    `json:"tx_sign_hex"`
 	Status      TxStatus  `json:"status"`
+	SentAt      uint64    `json:"sent_at"`      // 广播时间，未广播时为 0
+	ReplaceGuid string    `json:"replace_guid"` // RBF 替换交易对应的原始提现 guid，原始提现为空
+	UnSignTx    string    `json:"un_sign_tx"`   // 替换交易待签名的 hash，自动加速时推送给业务方签名
+	TxData      string    `json:"tx_data"`
 	Timestamp   uint64    `json:"timestamp"`
 }
 
@@ -26,6 +35,12 @@ type WithdrawsView interface {
 	QueryNotifyWithdraws(requestId string) ([]Withdraws, error)
 
 	UnSendWithdrawsList(requestId string) ([]Withdraws, error)
+	QueryFallbackWithdraws(requestId string) ([]Withdraws, error)
+	QueryWithdrawsByStatus(requestId string, status TxStatus) ([]Withdraws, error)
+	QueryExpiredWithdraws(requestId string, before uint64) ([]Withdraws, error)
+	QueryTrackWithdraws(requestId string) ([]Withdraws, error)
+	QueryWithdrawByGuid(requestId string, guid string) (*Withdraws, error)
+	QueryReplaceWithdraws(requestId string, originGuid string) ([]Withdraws, error)
 }
 
 type WithdrawsDB interface {
@@ -34,6 +49,12 @@ type WithdrawsDB interface {
 	StoreWithdraws(string, *Withdraws) error
 	UpdateWithdrawStatus(requestId string, status TxStatus, withdrawsList []Withdraws) error
 	UpdateWithdrawByGuid(requestId string, transactionId string, txSignedHex string) error
+	UpdateWithdrawStatusByGuids(requestId string, status TxStatus, withdrawsList []Withdraws) error
+	UpdateWithdrawsOnChain(requestId string, withdrawsList []Withdraws) error
+	UpdateOrphanedWithdrawsMined(requestId string, withdrawsList []Withdraws) (map[string]bool, error)
+	UpdateWithdrawsNotifyStatus(requestId string, status TxStatus, withdrawsList []Withdraws) error
+	UpdateWithdrawsSent(requestId string, withdrawsList []Withdraws) error
+	UpdateWithdrawsReplaced(requestId string, hash string) error
 }
 
 type withdrawsDB struct {
@@ -87,29 +108,29 @@ func (db *withdrawsDB) UpdateWithdrawStatus(requestId string, status TxStatus, w
 	})
 }
 
+// UpdateWithdrawByGuid 记录签名后的交易，只更新等待签名的提现，重复提交或提现已经失败时返回 ErrWithdrawNotSignable
 func (db *withdrawsDB) UpdateWithdrawByGuid(requestId string, transactionId string, txSignedHex string) error {
-	tableName := fmt.Sprintf("withdraws_%s", requestId)
-	var withdrawItem Withdraws
-	result := db.gorm.Table(tableName).Where("guid = ?", transactionId).Take(&withdrawItem)
+	result := db.gorm.Table("withdraws_"+requestId).
+		Where("guid = ? and status IN ?", transactionId, WithdrawSignableStatuses).
+		Updates(map[string]interface{}{
+			"tx_sign_hex": txSignedHex,
+			"status":      TxStatusUnSent,
+		})
 	if result.Error != nil {
-		log.Error("query fail", "err", result.Error)
+		log.Error("update tx fail", "err", result.Error)
+		return result.Error
 	}
-
-	withdrawItem.TxSignHex = txSignedHex
-	withdrawItem.Status = TxStatusUnSent
-
-	err := db.gorm.Table(tableName).Save(withdrawItem).Error
-	if err != nil {
-		log.Error("update tx fail", "err", err)
-		return err
+	if result.RowsAffected == 0 {
+		return ErrWithdrawNotSignable
 	}
 	return nil
 }
 
+// QueryNotifyWithdraws 查询状态变化后还没有成功通知业务方的提现
 func (db *withdrawsDB) QueryNotifyWithdraws(requestId string) ([]Withdraws, error) {
 	var notifyWithdraws []Withdraws
 	result := db.gorm.Table("withdraws_"+requestId).
-		Where("status = ?", TxStatusSent).
+		Where("status IN ?", WithdrawNotifyStatuses).
 		Find(&notifyWithdraws)
 
 	if result.Error != nil {
@@ -131,3 +152,188 @@ func (db *withdrawsDB) UnSendWithdrawsList(requestId string) ([]Withdraws, error
 
 	return withdrawsList, nil
 }
+
+// QueryFallbackWithdraws 查询所在区块已被重组掉、还没有进入回滚流程的提现
+func (db *withdrawsDB) QueryFallbackWithdraws(requestId string) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).
+		Where(orphanedBlockCondition).
+		Where("status NOT IN ?", FallbackStatuses).
+		Find(&withdrawsList).Error
+	if err != nil {
+		return nil, fmt.Errorf("query fallback withdraws failed: %w", err)
+	}
+	return withdrawsList, nil
+}
+
+func (db *withdrawsDB) QueryWithdrawsByStatus(requestId string, status TxStatus) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).
+		Where("status = ?", status).
+		Find(&withdrawsList).Error
+	if err != nil {
+		return nil, fmt.Errorf("query withdraws by status failed: %w", err)
+	}
+	return withdrawsList, nil
+}
+
+// QueryExpiredWithdraws 查询 before 之前创建、一直没有广播的提现
+func (db *withdrawsDB) QueryExpiredWithdraws(requestId string, before uint64) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).
+		Where("status IN ? and timestamp < ?", WithdrawPendingStatuses, before).
+		Find(&withdrawsList).Error
+	if err != nil {
+		return nil, fmt.Errorf("query expired withdraws failed: %w", err)
+	}
+	return withdrawsList, nil
+}
+
+// QueryTrackWithdraws 查询已广播、还没有确认的提现
+func (db *withdrawsDB) QueryTrackWithdraws(requestId string) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).
+		Where("status IN ?", WithdrawTrackStatuses).
+		Find(&withdrawsList).Error
+	if err != nil {
+		return nil, fmt.Errorf("query track withdraws failed: %w", err)
+	}
+	return withdrawsList, nil
+}
+
+func (db *withdrawsDB) QueryWithdrawByGuid(requestId string, guid string) (*Withdraws, error) {
+	var withdraw Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).Where("guid = ?", guid).Take(&withdraw).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query withdraw by guid failed: %w", err)
+	}
+	return &withdraw, nil
+}
+
+// QueryReplaceWithdraws 查询原始提现及其所有 RBF 替换交易，按创建时间排序
+func (db *withdrawsDB) QueryReplaceWithdraws(requestId string, originGuid string) ([]Withdraws, error) {
+	var withdrawsList []Withdraws
+	err := db.gorm.Table("withdraws_"+requestId).
+		Where("guid = ? or replace_guid = ?", originGuid, originGuid).
+		Order("timestamp asc").
+		Find(&withdrawsList).Error
+	if err != nil {
+		return nil, fmt.Errorf("query replace withdraws failed: %w", err)
+	}
+	return withdrawsList, nil
+}
+
+func (db *withdrawsDB) UpdateWithdrawStatusByGuids(requestId string, status TxStatus, withdrawsList []Withdraws) error {
+	if len(withdrawsList) == 0 {
+		return nil
+	}
+	var guids []uuid.UUID
+	for _, withdraw := range withdrawsList {
+		guids = append(guids, withdraw.Guid)
+	}
+	result := db.gorm.Table("withdraws_"+requestId).
+		Where("guid IN ?", guids).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("batch update status failed: %w", result.Error)
+	}
+	log.Info("Batch update withdraws status success", "requestId", requestId, "count", result.RowsAffected, "status", status)
+	return nil
+}
+
+// UpdateWithdrawsOnChain 扫块发现提现交易上链后，按交易 hash 记录所在区块并更新为已提现；
+// 需要先调用 UpdateOrphanedWithdrawsMined，剩下的孤块提现都已进入回滚流程、余额已经撤销，重新上链时回到已提现状态
+func (db *withdrawsDB) UpdateWithdrawsOnChain(requestId string, withdrawsList []Withdraws) error {
+	for _, withdraw := range withdrawsList {
+		result := db.gorm.Table("withdraws_"+requestId).
+			Where("hash = ?", withdraw.Hash).
+			Updates(map[string]interface{}{
+				"block_hash":   withdraw.BlockHash,
+				"block_number": withdraw.BlockNumber.Uint64(),
+				"status":       TxStatusWithdrawed,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("update withdraw on chain failed: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			log.Warn("No withdraw matched on chain tx", "requestId", requestId, "hash", withdraw.Hash)
+		}
+	}
+	return nil
+}
+
+// UpdateOrphanedWithdrawsMined 所在区块被重组掉、还没有进入回滚流程的提现在新的区块中重新上链时，更新所在区块并回到已提现状态；
+// 这些提现的余额没有被撤销，返回它们的交易 hash，重新上链时不再计入余额
+func (db *withdrawsDB) UpdateOrphanedWithdrawsMined(requestId string, withdrawsList []Withdraws) (map[string]bool, error) {
+	remined := make(map[string]bool)
+	for _, withdraw := range withdrawsList {
+		result := db.gorm.Table("withdraws_"+requestId).
+			Where("hash = ? and block_hash <> ? and status NOT IN ?", withdraw.Hash, withdraw.BlockHash, FallbackStatuses).
+			Where(orphanedBlockCondition).
+			Updates(map[string]interface{}{
+				"block_hash":   withdraw.BlockHash,
+				"block_number": withdraw.BlockNumber.Uint64(),
+				"status":       TxStatusWithdrawed,
+			})
+		if result.Error != nil {
+			return nil, fmt.Errorf("update orphaned withdraw mined failed: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Info("orphaned withdraw mined again", "requestId", requestId, "hash", withdraw.Hash, "blockHash", withdraw.BlockHash)
+			remined[withdraw.Hash] = true
+		}
+	}
+	return remined, nil
+}
+
+// UpdateWithdrawsNotifyStatus 记录通知结果，只更新通知期间状态没有变化的提现
+func (db *withdrawsDB) UpdateWithdrawsNotifyStatus(requestId string, status TxStatus, withdrawsList []Withdraws) error {
+	for _, withdraw := range withdrawsList {
+		result := db.gorm.Table("withdraws_"+requestId).
+			Where("guid = ? and status = ?", withdraw.Guid, withdraw.Status).
+			Update("status", status)
+		if result.Error != nil {
+			return fmt.Errorf("update withdraw notify status failed: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+// UpdateWithdrawsSent 广播成功后记录交易 hash 并更新为已发送
+func (db *withdrawsDB) UpdateWithdrawsSent(requestId string, withdrawsList []Withdraws) error {
+	for _, withdraw := range withdrawsList {
+		result := db.gorm.Table("withdraws_"+requestId).
+			Where("guid = ?", withdraw.Guid).
+			Updates(map[string]interface{}{
+				"hash":    withdraw.Hash,
+				"status":  TxStatusSent,
+				"sent_at": time.Now().Unix(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("update withdraw sent failed: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+// UpdateWithdrawsReplaced 原始提现或某个替换交易上链后，把同组的其他版本置为已替换
+func (db *withdrawsDB) UpdateWithdrawsReplaced(requestId string, hash string) error {
+	tableName := "withdraws_" + requestId
+	origins := db.gorm.Table(tableName).
+		Select("CASE WHEN replace_guid = '' THEN guid ELSE replace_guid END").
+		Where("hash = ?", hash)
+	result := db.gorm.Table(tableName).
+		Where("guid IN (?) or replace_guid IN (?)", origins, origins).
+		Where("hash <> ? and status NOT IN ?", hash, WithdrawFailStatuses).
+		Update("status", TxStatusReplaced)
+	if result.Error != nil {
+		return fmt.Errorf("update withdraws replaced failed: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Info("Withdraws replaced", "requestId", requestId, "hash", hash, "count", result.RowsAffected)
+	}
+	return nil
+}
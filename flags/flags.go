@@ -27,12 +27,18 @@ var (
 		Required: true,
 	}
 
-	ChainNameFlag = &cli.StringFlag{
+	ChainNameFlag = &cli.StringSliceFlag{
 		Name:     "chain-name",
-		Usage:    "chain name",
+		Usage:    "The chains to sync, Bitcoin, Litecoin, Dogecoin or BitcoinCash, repeat the flag or separate with commas to sync several chains in one instance",
 		EnvVars:  prefixEnvVars("CHAIN_NAME"),
 		Required: true,
 	}
+	NetworkFlag = &cli.StringFlag{
+		Name:    "network",
+		Usage:   "The network of the chains, mainnet, testnet, signet or regtest",
+		EnvVars: prefixEnvVars("NETWORK"),
+		Value:   "mainnet",
+	}
 
 	RpcUrlFlag = &cli.StringFlag{
 		Name:     "rpc-url",
@@ -49,9 +55,9 @@ var (
 	}
 	ConfirmationsFlag = &cli.UintFlag{
 		Name:    "confirmations",
-		Usage:   "The confirmation depth of l1",
+		Usage:   "The confirmation depth of l1, 0 uses the default of each chain",
 		EnvVars: prefixEnvVars("CONFIRMATIONS"),
-		Value:   64,
+		Value:   0,
 	}
 	SynchronizerIntervalFlag = &cli.DurationFlag{
 		Name:    "sync-interval",
@@ -65,12 +71,119 @@ var (
 		EnvVars: prefixEnvVars("WORKER_INTERVAL"),
 		Value:   time.Second * 5,
 	}
+	UtxoLockTimeoutFlag = &cli.DurationFlag{
+		Name:    "utxo-lock-timeout",
+		Usage:   "The timeout after which utxos locked by an unsent withdraw are released",
+		EnvVars: prefixEnvVars("UTXO_LOCK_TIMEOUT"),
+		Value:   time.Minute * 30,
+	}
+	WithdrawStuckTimeoutFlag = &cli.DurationFlag{
+		Name:    "withdraw-stuck-timeout",
+		Usage:   "The age after which a broadcast but unconfirmed withdraw is flagged as stuck",
+		EnvVars: prefixEnvVars("WITHDRAW_STUCK_TIMEOUT"),
+		Value:   time.Hour,
+	}
+	RbfAutoBumpFlag = &cli.BoolFlag{
+		Name:    "rbf-auto-bump",
+		Usage:   "Automatically build an RBF replacement when a withdraw is flagged as stuck",
+		EnvVars: prefixEnvVars("RBF_AUTO_BUMP"),
+		Value:   true,
+	}
+	RbfMaxFeeRateFlag = &cli.Int64Flag{
+		Name:    "rbf-max-fee-rate",
+		Usage:   "The highest fee rate in sat/vB an automatic RBF replacement may pay",
+		EnvVars: prefixEnvVars("RBF_MAX_FEE_RATE"),
+		Value:   200,
+	}
+	MempoolIntervalFlag = &cli.DurationFlag{
+		Name:    "mempool-interval",
+		Usage:   "The interval of polling user addresses for unconfirmed deposits",
+		EnvVars: prefixEnvVars("MEMPOOL_INTERVAL"),
+		Value:   time.Second * 15,
+	}
+	RpcHealthIntervalFlag = &cli.DurationFlag{
+		Name:    "btc-rpc-health-interval",
+		Usage:   "The interval of checking chain account rpc hosts health",
+		EnvVars: prefixEnvVars("CHAIN_BTC_RPC_HEALTH_INTERVAL"),
+		Value:   time.Second * 15,
+	}
+	RpcBreakerThresholdFlag = &cli.IntFlag{
+		Name:    "btc-rpc-breaker-threshold",
+		Usage:   "The number of consecutive failures before a chain account rpc host is skipped",
+		EnvVars: prefixEnvVars("CHAIN_BTC_RPC_BREAKER_THRESHOLD"),
+		Value:   3,
+	}
+	RpcBreakerTimeoutFlag = &cli.DurationFlag{
+		Name:    "btc-rpc-breaker-timeout",
+		Usage:   "How long a failing chain account rpc host is skipped",
+		EnvVars: prefixEnvVars("CHAIN_BTC_RPC_BREAKER_TIMEOUT"),
+		Value:   time.Second * 30,
+	}
+	RpcQuorumFlag = &cli.IntFlag{
+		Name:    "btc-rpc-quorum",
+		Usage:   "Fetch every block header from this many chain account rpc hosts and accept it only when a majority agree, disabled when less than 2",
+		EnvVars: prefixEnvVars("CHAIN_BTC_RPC_QUORUM"),
+		Value:   0,
+	}
+	ChainSourceFlag = &cli.StringFlag{
+		Name:    "chain-source",
+		Usage:   "The source of blocks, transactions and fee rate, wallet for chain account rpc, bitcoind for bitcoin core json-rpc or esplora for esplora/electrs rest api",
+		EnvVars: prefixEnvVars("CHAIN_SOURCE"),
+		Value:   "wallet",
+	}
+	BitcoindRpcFlag = &cli.StringFlag{
+		Name:    "bitcoind-rpc",
+		Usage:   "The url of bitcoin core json-rpc, the node requires txindex",
+		EnvVars: prefixEnvVars("BITCOIND_RPC"),
+	}
+	BitcoindRpcUserFlag = &cli.StringFlag{
+		Name:    "bitcoind-rpc-user",
+		Usage:   "The user of bitcoin core json-rpc",
+		EnvVars: prefixEnvVars("BITCOIND_RPC_USER"),
+	}
+	BitcoindRpcPasswordFlag = &cli.StringFlag{
+		Name:    "bitcoind-rpc-password",
+		Usage:   "The password of bitcoin core json-rpc",
+		EnvVars: prefixEnvVars("BITCOIND_RPC_PASSWORD"),
+	}
+	EsploraUrlFlag = &cli.StringFlag{
+		Name:    "esplora-url",
+		Usage:   "The base url of esplora/electrs rest api, such as https://blockstream.info/api",
+		EnvVars: prefixEnvVars("ESPLORA_URL"),
+	}
+	MempoolSourceFlag = &cli.StringFlag{
+		Name:    "mempool-source",
+		Usage:   "The source of mempool transactions for unconfirmed deposits, bitcoind, esplora or none to disable, defaults to the chain source; chain account rpc can not list mempool transactions",
+		EnvVars: prefixEnvVars("MEMPOOL_SOURCE"),
+	}
+	ZmqBlockFlag = &cli.StringFlag{
+		Name:    "zmq-block",
+		Usage:   "The zmqpubhashblock address of bitcoind, such as tcp://127.0.0.1:28332, new blocks are scanned immediately when set",
+		EnvVars: prefixEnvVars("ZMQ_BLOCK"),
+	}
+	ZmqTxFlag = &cli.StringFlag{
+		Name:    "zmq-tx",
+		Usage:   "The zmqpubrawtx address of bitcoind, new transactions trigger the mempool deposit check when set",
+		EnvVars: prefixEnvVars("ZMQ_TX"),
+	}
+	ZmqQuietTimeoutFlag = &cli.DurationFlag{
+		Name:    "zmq-quiet-timeout",
+		Usage:   "Reconnect zmq when no message is received for this long, polling continues meanwhile",
+		EnvVars: prefixEnvVars("ZMQ_QUIET_TIMEOUT"),
+		Value:   time.Minute * 5,
+	}
 	BlocksStepFlag = &cli.UintFlag{
 		Name:    "blocks-step",
 		Usage:   "Scanner blocks step",
 		EnvVars: prefixEnvVars("BLOCKS_STEP"),
 		Value:   500,
 	}
+	FetchParallelismFlag = &cli.IntFlag{
+		Name:    "fetch-parallelism",
+		Usage:   "The max number of concurrent block header and block body requests",
+		EnvVars: prefixEnvVars("FETCH_PARALLELISM"),
+		Value:   8,
+	}
 
 	// RpcHostFlag rpc api flags
 	RpcHostFlag = &cli.StringFlag{
@@ -86,11 +199,10 @@ var (
 		Value:    8987,
 		Required: true,
 	}
-	ChainBtcRpcFlag = &cli.StringFlag{
-		Name:     "btc-rpc",
-		Usage:    "The host of chain account rpc",
-		EnvVars:  prefixEnvVars("CHAIN_BTC_RPC"),
-		Required: true,
+	ChainBtcRpcFlag = &cli.StringSliceFlag{
+		Name:    "btc-rpc",
+		Usage:   "The hosts of chain account rpc, separated by comma, failover to the next host when one is unavailable; required by the rpc command, rbf auto bump and the wallet chain source",
+		EnvVars: prefixEnvVars("CHAIN_BTC_RPC"),
 	}
 
 	// MetricsHostFlag Metrics flags
@@ -210,11 +322,13 @@ var requireFlags = []cli.Flag{
 	RpcUrlFlag,
 	ChainIdFlag,
 	ChainNameFlag,
+	NetworkFlag,
 	StartingHeightFlag,
 	ConfirmationsFlag,
 	SynchronizerIntervalFlag,
 	WorkerIntervalFlag,
 	BlocksStepFlag,
+	FetchParallelismFlag,
 	RpcHostFlag,
 	RpcPortFlag,
 	ChainBtcRpcFlag,
@@ -238,6 +352,42 @@ var optionalFlags = []cli.Flag{
 	ApiCacheDetailSizeFlag,
 	ApiCacheListExpireTimeFlag,
 	ApiCacheDetailExpireTimeFlag,
+	UtxoLockTimeoutFlag,
+	WithdrawStuckTimeoutFlag,
+	RbfAutoBumpFlag,
+	RbfMaxFeeRateFlag,
+	MempoolIntervalFlag,
+	RpcHealthIntervalFlag,
+	RpcBreakerThresholdFlag,
+	RpcBreakerTimeoutFlag,
+	RpcQuorumFlag,
+	ChainSourceFlag,
+	BitcoindRpcFlag,
+	BitcoindRpcUserFlag,
+	BitcoindRpcPasswordFlag,
+	EsploraUrlFlag,
+	MempoolSourceFlag,
+	ZmqBlockFlag,
+	ZmqTxFlag,
+	ZmqQuietTimeoutFlag,
+}
+
+// RescanFlags rescan 命令的参数
+var RescanFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "business",
+		Usage: "The business to rescan, rescan all businesses of the chain when empty",
+	},
+	&cli.Uint64Flag{
+		Name:     "from",
+		Usage:    "The first block height to rescan",
+		Required: true,
+	},
+	&cli.Uint64Flag{
+		Name:     "to",
+		Usage:    "The last block height to rescan",
+		Required: true,
+	},
 }
 
 func init() {
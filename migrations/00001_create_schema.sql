@@ -174,7 +174,7 @@ CREATE TABLE IF NOT EXISTS child_txs (
     amount        VARCHAR  NOT NULL,
     tx_type       VARCHAR  NOT NULL,
     timestamp     INTEGER  NOT NULL CHECK (timestamp > 0)
-)
+);
 CREATE INDEX IF NOT EXISTS child_txs_tx_hash ON child_txs (hash);
 CREATE INDEX IF NOT EXISTS child_txs_timestamp ON child_txs (timestamp);
 
@@ -0,0 +1,97 @@
+-- 迁移用到的辅助函数。按业务方分表的 <模板表>_<业务方 id> 用 like 模板表创建，只继承创建时模板表的结构，
+-- 之后对模板表的修改都要同时作用到已经存在的分表上；迁移每次启动都会执行，所有操作都需要可以重复执行
+
+-- business_tables 模板表以及它的所有业务方分表
+CREATE OR REPLACE FUNCTION business_tables(template TEXT) RETURNS SETOF TEXT AS
+$$
+SELECT table_name::TEXT
+FROM information_schema.tables
+WHERE table_schema = current_schema()
+  AND table_type = 'BASE TABLE'
+  AND (table_name = template OR table_name LIKE template || '\_%');
+$$ LANGUAGE sql STABLE;
+
+-- business_add_column 给模板表和所有分表加列，definition 为列定义
+CREATE OR REPLACE FUNCTION business_add_column(template TEXT, definition TEXT) RETURNS VOID AS
+$$
+DECLARE
+    t TEXT;
+BEGIN
+    FOR t IN SELECT business_tables(template)
+        LOOP
+            EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS %s', t, definition);
+        END LOOP;
+END
+$$ LANGUAGE plpgsql;
+
+-- business_has_index 表上是否已经有按 columns 建立的索引；分表复制模板表索引时会自动命名，不能按索引名判断
+CREATE OR REPLACE FUNCTION business_has_index(t TEXT, columns TEXT[], is_unique BOOLEAN) RETURNS BOOLEAN AS
+$$
+SELECT EXISTS (SELECT 1
+               FROM pg_index i
+               WHERE i.indrelid = format('%I', t)::REGCLASS
+                 AND i.indpred IS NULL
+                 AND (i.indisunique OR NOT is_unique)
+                 AND ARRAY(SELECT a.attname::TEXT
+                           FROM unnest(i.indkey::SMALLINT[]) WITH ORDINALITY AS k(attnum, ord)
+                                    JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+                           ORDER BY k.ord) = columns);
+$$ LANGUAGE sql STABLE;
+
+-- business_index 给模板表和所有分表按 columns 建索引，索引名为 <表名>_<suffix>
+CREATE OR REPLACE FUNCTION business_index(template TEXT, suffix TEXT, columns TEXT[]) RETURNS VOID AS
+$$
+DECLARE
+    t TEXT;
+BEGIN
+    FOR t IN SELECT business_tables(template)
+        LOOP
+            IF NOT business_has_index(t, columns, FALSE) THEN
+                EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (%s)', t || '_' || suffix, t,
+                               (SELECT string_agg(format('%I', c), ', ') FROM unnest(columns) AS c));
+            END IF;
+        END LOOP;
+END
+$$ LANGUAGE plpgsql;
+
+-- business_unique_index 建唯一索引前先删除重复的行，每组重复行按 keep_order 排序只保留第一行
+CREATE OR REPLACE FUNCTION business_unique_index(template TEXT, suffix TEXT, columns TEXT[], keep_order TEXT) RETURNS VOID AS
+$$
+DECLARE
+    t    TEXT;
+    cols TEXT := (SELECT string_agg(format('%I', c), ', ') FROM unnest(columns) AS c);
+BEGIN
+    FOR t IN SELECT business_tables(template)
+        LOOP
+            IF NOT business_has_index(t, columns, TRUE) THEN
+                EXECUTE format('DELETE FROM %I WHERE ctid IN (SELECT ctid FROM (SELECT ctid, row_number() OVER (PARTITION BY %s ORDER BY %s) AS rn FROM %I) d WHERE d.rn > 1)',
+                               t, cols, keep_order, t);
+                EXECUTE format('CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I (%s)', t || '_' || suffix, t, cols);
+            END IF;
+        END LOOP;
+END
+$$ LANGUAGE plpgsql;
+
+-- business_allow_zero_block_number 还没有上链的记录 block_number 为 0，把 block_number > 0 的检查放宽为 >= 0
+CREATE OR REPLACE FUNCTION business_allow_zero_block_number(template TEXT) RETURNS VOID AS
+$$
+DECLARE
+    t TEXT;
+    c TEXT;
+BEGIN
+    FOR t IN SELECT business_tables(template)
+        LOOP
+            FOR c IN SELECT con.conname
+                     FROM pg_constraint con
+                              JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = ANY (con.conkey)
+                     WHERE con.conrelid = format('%I', t)::REGCLASS
+                       AND con.contype = 'c'
+                       AND a.attname = 'block_number'
+                       AND pg_get_constraintdef(con.oid) NOT LIKE '%>=%'
+                LOOP
+                    EXECUTE format('ALTER TABLE %I DROP CONSTRAINT %I', t, c);
+                    EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I CHECK (block_number >= 0)', t, c);
+                END LOOP;
+        END LOOP;
+END
+$$ LANGUAGE plpgsql;
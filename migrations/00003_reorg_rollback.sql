@@ -0,0 +1,29 @@
+-- 区块重组回滚：utxo 和花费记录按交易 hash 删除，孤块中的交易按区块高度查找
+DO
+$$
+    DECLARE
+        t TEXT;
+    BEGIN
+        FOR t IN SELECT business_tables('vins')
+            LOOP
+                IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = t AND column_name = 'txid') THEN
+                    EXECUTE format('ALTER TABLE %I RENAME COLUMN txid TO tx_id', t);
+                END IF;
+            END LOOP;
+        IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'reorg_blocks' AND column_name = 'parent_hash') THEN
+            ALTER TABLE reorg_blocks RENAME COLUMN parent_hash TO prev_hash;
+        END IF;
+    END
+$$;
+
+-- 同一区块可能被多次重组掉，reorg_blocks 中的高度和父区块不再唯一
+ALTER TABLE reorg_blocks DROP CONSTRAINT IF EXISTS reorg_blocks_parent_hash_key;
+ALTER TABLE reorg_blocks DROP CONSTRAINT IF EXISTS reorg_blocks_number_key;
+
+SELECT business_add_column('vouts', 'tx_id VARCHAR NOT NULL DEFAULT ''''');
+
+SELECT business_index('vins', 'tx_id', ARRAY ['tx_id']);
+SELECT business_index('vins', 'spend_tx_hash', ARRAY ['spend_tx_hash']);
+SELECT business_index('vouts', 'tx_id', ARRAY ['tx_id']);
+SELECT business_index('deposits', 'block_number', ARRAY ['block_number']);
+SELECT business_index('transactions', 'block_number', ARRAY ['block_number']);
@@ -0,0 +1,2 @@
+-- 业务方使用的选币策略，为空时使用默认策略
+ALTER TABLE business ADD COLUMN IF NOT EXISTS coin_selection VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- 提现构建交易时锁定选中的 utxo，避免并发提现重复花费
+SELECT business_add_column('vins', 'lock_tx_id VARCHAR NOT NULL DEFAULT ''''');
+SELECT business_add_column('vins', 'locked_at INTEGER NOT NULL DEFAULT 0');
+SELECT business_index('vins', 'lock_tx_id', ARRAY ['lock_tx_id']);
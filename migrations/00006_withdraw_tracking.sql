@@ -0,0 +1,3 @@
+-- 记录提现的广播时间，用于发现长时间没有上链或被丢弃的交易；已广播未上链的提现 block_number 为 0
+SELECT business_add_column('withdraws', 'sent_at INTEGER NOT NULL DEFAULT 0');
+SELECT business_allow_zero_block_number('withdraws');
@@ -0,0 +1,5 @@
+-- RBF 加速：记录被替换的提现以及重新签名需要的未签名交易
+SELECT business_add_column('withdraws', 'replace_guid VARCHAR NOT NULL DEFAULT ''''');
+SELECT business_add_column('withdraws', 'un_sign_tx VARCHAR NOT NULL DEFAULT ''''');
+SELECT business_add_column('withdraws', 'tx_data VARCHAR NOT NULL DEFAULT ''''');
+SELECT business_index('withdraws', 'replace_guid', ARRAY ['replace_guid']);
@@ -0,0 +1,3 @@
+-- CPFP 加速充值：业务方可以设置子交易的最高费率，加速交易广播后还没有上链时 block_number 为 0
+ALTER TABLE business ADD COLUMN IF NOT EXISTS cpfp_max_fee_rate INTEGER NOT NULL DEFAULT 0;
+SELECT business_allow_zero_block_number('internals');
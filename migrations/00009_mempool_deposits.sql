@@ -0,0 +1,2 @@
+-- 内存池中发现的充值还没有上链，block_number 为 0
+SELECT business_allow_zero_block_number('deposits');
@@ -0,0 +1,3 @@
+-- 内存池充值首次发现的高度，以及花费了同一输入的冲突交易
+SELECT business_add_column('deposits', 'seen_height INTEGER NOT NULL DEFAULT 0');
+SELECT business_add_column('deposits', 'conflict_hash VARCHAR NOT NULL DEFAULT ''''');
@@ -0,0 +1,3 @@
+-- 每个业务方的起始高度和扫块进度
+ALTER TABLE business ADD COLUMN IF NOT EXISTS start_height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE business ADD COLUMN IF NOT EXISTS sync_height INTEGER NOT NULL DEFAULT 0;
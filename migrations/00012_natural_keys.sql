@@ -0,0 +1,5 @@
+-- 同一区块重复处理时按自然键去重。建唯一索引前删除之前重复写入的行：
+-- utxo 保留已花费、已锁定的记录，充值保留确认数最多的记录，交易保留最早的记录
+SELECT business_unique_index('vins', 'tx_id_vout', ARRAY ['tx_id', 'vout'], 'is_spend DESC, lock_tx_id DESC, timestamp, guid');
+SELECT business_unique_index('deposits', 'hash_unique', ARRAY ['hash'], 'confirms DESC, timestamp, guid');
+SELECT business_unique_index('transactions', 'hash_unique', ARRAY ['hash'], 'timestamp, guid');
@@ -0,0 +1,18 @@
+-- 输出序号按 uint32 记录，SMALLINT 放不下序号较大的输出
+DO
+$$
+    DECLARE
+        t RECORD;
+    BEGIN
+        FOR t IN
+            SELECT table_name, column_name
+            FROM information_schema.columns
+            WHERE table_schema = current_schema()
+              AND ((column_name = 'vout' AND table_name IN (SELECT business_tables('vins')))
+                OR (column_name = 'n' AND table_name IN (SELECT business_tables('vouts'))))
+              AND data_type <> 'bigint'
+            LOOP
+                EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE BIGINT', t.table_name, t.column_name);
+            END LOOP;
+    END
+$$;
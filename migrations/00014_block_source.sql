@@ -0,0 +1,3 @@
+-- 记录区块头由哪个上游节点提供
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS source VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE reorg_blocks ADD COLUMN IF NOT EXISTS source VARCHAR NOT NULL DEFAULT '';
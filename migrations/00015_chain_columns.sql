@@ -0,0 +1,36 @@
+-- 多链支持：业务方和区块记录所属的链，不同链的区块 hash 和高度可以相同
+ALTER TABLE business ADD COLUMN IF NOT EXISTS chain VARCHAR NOT NULL DEFAULT 'Bitcoin';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS chain VARCHAR NOT NULL DEFAULT 'Bitcoin';
+ALTER TABLE reorg_blocks ADD COLUMN IF NOT EXISTS chain VARCHAR NOT NULL DEFAULT 'Bitcoin';
+
+DO
+$$
+    BEGIN
+        IF NOT EXISTS (SELECT 1
+                       FROM information_schema.key_column_usage
+                       WHERE table_schema = current_schema()
+                         AND table_name = 'blocks'
+                         AND constraint_name = 'blocks_pkey'
+                         AND column_name = 'chain') THEN
+            ALTER TABLE blocks DROP CONSTRAINT blocks_pkey;
+            ALTER TABLE blocks ADD PRIMARY KEY (chain, hash);
+        END IF;
+        IF NOT EXISTS (SELECT 1
+                       FROM information_schema.key_column_usage
+                       WHERE table_schema = current_schema()
+                         AND table_name = 'reorg_blocks'
+                         AND constraint_name = 'reorg_blocks_pkey'
+                         AND column_name = 'chain') THEN
+            ALTER TABLE reorg_blocks DROP CONSTRAINT reorg_blocks_pkey;
+            ALTER TABLE reorg_blocks ADD PRIMARY KEY (chain, hash);
+        END IF;
+        IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'blocks_chain_prev_hash_key') THEN
+            ALTER TABLE blocks ADD CONSTRAINT blocks_chain_prev_hash_key UNIQUE (chain, prev_hash);
+        END IF;
+        IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'blocks_chain_number_key') THEN
+            ALTER TABLE blocks ADD CONSTRAINT blocks_chain_number_key UNIQUE (chain, number);
+        END IF;
+    END
+$$;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS blocks_prev_hash_key;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS blocks_number_key;
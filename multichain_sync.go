@@ -0,0 +1,243 @@
+package multichain_sync_btc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rbf"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/worker"
+)
+
+// ChainClients 一条链的配置、网络参数、钱包客户端、扫块数据源和内存池数据源；
+// 没有用到上游 WalletUtxoService 时 RpcClient 为 nil，MempoolSource 为 nil 时不检测内存池充值
+type ChainClients struct {
+	Config        config.Config
+	Params        *chaincfg.Params
+	RpcClient     *syncclient.WalletBtcAccountClient
+	ChainSource   syncclient.ChainSource
+	MempoolSource syncclient.MempoolSource
+}
+
+// ChainSync 管理一条链的 ZMQ 订阅、扫块、内存池充值、提现、提现跟踪、内部交易、回滚和通知任务的生命周期
+type ChainSync struct {
+	Chain    string
+	Deposit  *worker.Deposit
+	Zmq      *worker.ZmqListener
+	Mempool  *worker.MempoolWatcher
+	Withdraw *worker.Withdraw
+	Tracker  *worker.WithdrawTracker
+	Internal *worker.Internal
+	FallBack *worker.FallBack
+	Notifier *worker.Notifier
+}
+
+// MultiChainSync 同一个进程同步多条链，各链的任务互相独立，共用数据库和指标服务
+type MultiChainSync struct {
+	Chains []*ChainSync
+
+	db            *database.DB
+	metricsServer *http.Server
+	shutdown      context.CancelCauseFunc
+	stopped       atomic.Bool
+}
+
+func NewMultiChainSync(ctx context.Context, cfg *config.Config, chains []ChainClients, shutdown context.CancelCauseFunc) (*MultiChainSync, error) {
+	db, err := database.NewDB(ctx, cfg.MasterDB)
+	if err != nil {
+		log.Error("init database fail", "err", err)
+		return nil, err
+	}
+
+	var chainSyncs []*ChainSync
+	for _, chain := range chains {
+		chainSync, err := newChainSync(chain, db.WithChain(chain.Config.ChainNode.ChainName), shutdown)
+		if err != nil {
+			return nil, err
+		}
+		chainSyncs = append(chainSyncs, chainSync)
+	}
+
+	// 上游节点校验等指标以 prometheus 格式暴露
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler(metrics.DefaultRegistry))
+	metricsServer := &http.Server{
+		Addr:    net.JoinHostPort(cfg.MetricsServer.Host, strconv.Itoa(cfg.MetricsServer.Port)),
+		Handler: mux,
+	}
+
+	return &MultiChainSync{
+		Chains:        chainSyncs,
+		db:            db,
+		metricsServer: metricsServer,
+		shutdown:      shutdown,
+	}, nil
+}
+
+func newChainSync(chain ChainClients, db *database.DB, shutdown context.CancelCauseFunc) (*ChainSync, error) {
+	cfg := &chain.Config
+	chainName := cfg.ChainNode.ChainName
+	chainSource := chain.ChainSource
+	// 未连接上游钱包服务时不能传入 nil 指针，否则接口不为 nil
+	var txBuilder rbf.TxBuilder
+	if chain.RpcClient != nil {
+		txBuilder = chain.RpcClient
+	}
+
+	zmq := worker.NewZmqListener(cfg, shutdown)
+	deposit, err := worker.NewDeposit(*cfg, db, chainSource, zmq.Blocks(), shutdown)
+	if err != nil {
+		log.Error("new deposit fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	mempool, err := worker.NewMempoolWatcher(cfg, db, chainSource, chain.MempoolSource, zmq.Txs(), shutdown)
+	if err != nil {
+		log.Error("new mempool watcher fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	withdraw, err := worker.NewWithdraw(cfg, db, chainSource, shutdown)
+	if err != nil {
+		log.Error("new withdraw fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	tracker, err := worker.NewWithdrawTracker(cfg, db, chain.Params, txBuilder, chainSource, shutdown)
+	if err != nil {
+		log.Error("new withdraw tracker fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	internal, err := worker.NewInternal(cfg, db, chainSource, shutdown)
+	if err != nil {
+		log.Error("new internal fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	fallBack, err := worker.NewFallBack(cfg, db, chainSource, shutdown)
+	if err != nil {
+		log.Error("new fallback fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+	notifier, err := worker.NewNotifier(cfg, db, shutdown)
+	if err != nil {
+		log.Error("new notifier fail", "chain", chainName, "err", err)
+		return nil, err
+	}
+
+	return &ChainSync{
+		Chain:    chainName,
+		Deposit:  deposit,
+		Zmq:      zmq,
+		Mempool:  mempool,
+		Withdraw: withdraw,
+		Tracker:  tracker,
+		Internal: internal,
+		FallBack: fallBack,
+		Notifier: notifier,
+	}, nil
+}
+
+func (cs *ChainSync) Start() error {
+	log.Info("start chain sync", "chain", cs.Chain)
+	if err := cs.Zmq.Start(); err != nil {
+		return err
+	}
+	if err := cs.Deposit.Start(); err != nil {
+		return err
+	}
+	if err := cs.Mempool.Start(); err != nil {
+		return err
+	}
+	if err := cs.Withdraw.Start(); err != nil {
+		return err
+	}
+	if err := cs.Tracker.Start(); err != nil {
+		return err
+	}
+	if err := cs.Internal.Start(); err != nil {
+		return err
+	}
+	if err := cs.FallBack.Start(); err != nil {
+		return err
+	}
+	return cs.Notifier.Start()
+}
+
+func (cs *ChainSync) Close() error {
+	var result error
+	if err := cs.Zmq.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close zmq listener: %w", err))
+	}
+	if err := cs.Deposit.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close deposit: %w", err))
+	}
+	if err := cs.Mempool.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close mempool watcher: %w", err))
+	}
+	if err := cs.Withdraw.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close withdraw: %w", err))
+	}
+	if err := cs.Tracker.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close withdraw tracker: %w", err))
+	}
+	if err := cs.Internal.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close internal: %w", err))
+	}
+	if err := cs.FallBack.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close fallback: %w", err))
+	}
+	if err := cs.Notifier.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close notifier: %w", err))
+	}
+	return result
+}
+
+func (mcs *MultiChainSync) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", mcs.metricsServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen metrics server: %w", err)
+	}
+	log.Info("start metrics server", "addr", listener.Addr())
+	go func() {
+		if err := mcs.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("metrics server stopped", "err", err)
+		}
+	}()
+	for _, chain := range mcs.Chains {
+		if err := chain.Start(); err != nil {
+			return fmt.Errorf("failed to start %s sync: %w", chain.Chain, err)
+		}
+	}
+	return nil
+}
+
+func (mcs *MultiChainSync) Stop(ctx context.Context) error {
+	var result error
+	if err := mcs.metricsServer.Shutdown(ctx); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close metrics server: %w", err))
+	}
+	for _, chain := range mcs.Chains {
+		if err := chain.Close(); err != nil {
+			result = errors.Join(result, fmt.Errorf("failed to close %s sync: %w", chain.Chain, err))
+		}
+	}
+	if err := mcs.db.Close(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to close database: %w", err))
+	}
+	mcs.stopped.Store(true)
+	log.Info("multichain sync stopped")
+	return result
+}
+
+func (mcs *MultiChainSync) Stopped() bool {
+	return mcs.stopped.Load()
+}
@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+var ErrBusinessNotifyRejected = errors.New("business rejected notify")
+
+type NotifyClient struct {
+	client    *http.Client
+	notifyUrl string
+}
+
+func NewNotifyClient(notifyUrl string) (*NotifyClient, error) {
+	if notifyUrl == "" {
+		return nil, errors.New("notify url is empty")
+	}
+	return &NotifyClient{
+		client:    &http.Client{Timeout: defaultRequestTimeout},
+		notifyUrl: notifyUrl,
+	}, nil
+}
+
+// BusinessNotify 以 json 格式 POST 交易状态到业务方的 notify url
+func (nc *NotifyClient) BusinessNotify(ctx context.Context, notifyData *NotifyRequest) error {
+	body, err := json.Marshal(notifyData)
+	if err != nil {
+		return fmt.Errorf("marshal notify request fail: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nc.notifyUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new notify request fail: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := nc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post notify fail: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read notify response fail: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notify response status %d: %s", resp.StatusCode, respBody)
+	}
+	var notifyResp NotifyResponse
+	if err := json.Unmarshal(respBody, &notifyResp); err != nil {
+		return fmt.Errorf("unmarshal notify response fail: %w", err)
+	}
+	if !notifyResp.Success {
+		return ErrBusinessNotifyRejected
+	}
+	return nil
+}
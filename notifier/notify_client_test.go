@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessNotify(t *testing.T) {
+	var received NotifyRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	client, err := NewNotifyClient(server.URL)
+	require.NoError(t, err)
+	err = client.BusinessNotify(context.Background(), &NotifyRequest{Txn: []Transaction{{Hash: "0xabc", Status: "unsafe"}}})
+	require.NoError(t, err)
+	require.Len(t, received.Txn, 1)
+	require.Equal(t, "0xabc", received.Txn[0].Hash)
+}
+
+func TestBusinessNotifyRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"success":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewNotifyClient(server.URL)
+	require.NoError(t, err)
+	err = client.BusinessNotify(context.Background(), &NotifyRequest{})
+	require.ErrorIs(t, err, ErrBusinessNotifyRejected)
+}
+
+func TestBusinessNotifyHttpError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, err := NewNotifyClient(server.URL)
+	require.NoError(t, err)
+	require.Error(t, client.BusinessNotify(context.Background(), &NotifyRequest{}))
+}
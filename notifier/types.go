@@ -0,0 +1,38 @@
+package notifier
+
+// NotifyRequest 推送给业务方的交易状态变更
+type NotifyRequest struct {
+	Txn []Transaction `json:"txn"`
+}
+
+// Transaction ReplaceTransactionId 为 RBF 替换交易对应的原始提现，
+// 自动加速生成的替换交易同时带上待签名数据，业务方签名后调用 buildSignedTransaction；
+// ConflictHash 为花费了 conflicted 充值相同输入的交易
+type Transaction struct {
+	TransactionId        string    `json:"transaction_id"`
+	ReplaceTransactionId string    `json:"replace_transaction_id,omitempty"`
+	ConflictHash         string    `json:"conflict_hash,omitempty"`
+	BlockHash            string    `json:"block_hash"`
+	BlockNumber          uint64    `json:"block_number"`
+	Hash                 string    `json:"hash"`
+	Fee                  string    `json:"fee"`
+	TxType               string    `json:"tx_type"`
+	Status               string    `json:"status"`
+	Confirms             uint16    `json:"confirms"`
+	Timestamp            uint64    `json:"timestamp"`
+	UnSignTx             string    `json:"un_sign_tx,omitempty"`
+	TxData               string    `json:"tx_data,omitempty"`
+	ChildTxs             []ChildTx `json:"child_txs"`
+}
+
+type ChildTx struct {
+	TxIndex     uint64 `json:"tx_index"`
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Amount      string `json:"amount"`
+}
+
+// NotifyResponse 业务方处理成功时返回 success 为 true
+type NotifyResponse struct {
+	Success bool `json:"success"`
+}
@@ -256,10 +256,15 @@ func (x *Token) GetColdAmount() string {
 }
 
 type BusinessRegisterRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
-	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-	NotifyUrl     string                 `protobuf:"bytes,3,opt,name=notify_url,json=notifyUrl,proto3" json:"notify_url,omitempty"`
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConsumerToken  string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
+	RequestId      string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	NotifyUrl      string                 `protobuf:"bytes,3,opt,name=notify_url,json=notifyUrl,proto3" json:"notify_url,omitempty"`
+	CoinSelection  string                 `protobuf:"bytes,4,opt,name=coin_selection,json=coinSelection,proto3" json:"coin_selection,omitempty"`
+	CpfpMaxFeeRate uint64                 `protobuf:"varint,5,opt,name=cpfp_max_fee_rate,json=cpfpMaxFeeRate,proto3" json:"cpfp_max_fee_rate,omitempty"`
+	StartHeight    uint64                 `protobuf:"varint,6,opt,name=start_height,json=startHeight,proto3" json:"start_height,omitempty"`
+	// 业务方接入的链，为空时使用服务配置的第一条链；其他请求的 chain 为空时使用业务方注册的链
+	Chain         string `protobuf:"bytes,7,opt,name=chain,proto3" json:"chain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -315,6 +320,34 @@ func (x *BusinessRegisterRequest) GetNotifyUrl() string {
 	return ""
 }
 
+func (x *BusinessRegisterRequest) GetCoinSelection() string {
+	if x != nil {
+		return x.CoinSelection
+	}
+	return ""
+}
+
+func (x *BusinessRegisterRequest) GetCpfpMaxFeeRate() uint64 {
+	if x != nil {
+		return x.CpfpMaxFeeRate
+	}
+	return 0
+}
+
+func (x *BusinessRegisterRequest) GetStartHeight() uint64 {
+	if x != nil {
+		return x.StartHeight
+	}
+	return 0
+}
+
+func (x *BusinessRegisterRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
 type BusinessRegisterResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          ReturnCode             `protobuf:"varint,1,opt,name=Code,proto3,enum=syncs.ReturnCode" json:"Code,omitempty"`
@@ -372,6 +405,7 @@ type ExportAddressesRequest struct {
 	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
 	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
 	PublicKeys    []*PublicKey           `protobuf:"bytes,3,rep,name=public_keys,json=publicKeys,proto3" json:"public_keys,omitempty"`
+	Chain         string                 `protobuf:"bytes,4,opt,name=chain,proto3" json:"chain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -427,6 +461,13 @@ func (x *ExportAddressesRequest) GetPublicKeys() []*PublicKey {
 	return nil
 }
 
+func (x *ExportAddressesRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
 type ExportAddressesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          ReturnCode             `protobuf:"varint,1,opt,name=Code,proto3,enum=syncs.ReturnCode" json:"Code,omitempty"`
@@ -568,6 +609,7 @@ type UnSignWithdrawTransactionRequest struct {
 	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
 	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
 	Txn           []*Transactions        `protobuf:"bytes,3,rep,name=txn,proto3" json:"txn,omitempty"`
+	Chain         string                 `protobuf:"bytes,4,opt,name=chain,proto3" json:"chain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -623,11 +665,21 @@ func (x *UnSignWithdrawTransactionRequest) GetTxn() []*Transactions {
 	return nil
 }
 
+func (x *UnSignWithdrawTransactionRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
 type ReturnTransactionHashes struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	TransactionUuid string                 `protobuf:"bytes,1,opt,name=transaction_uuid,json=transactionUuid,proto3" json:"transaction_uuid,omitempty"`
 	UnSignTx        string                 `protobuf:"bytes,2,opt,name=un_sign_tx,json=unSignTx,proto3" json:"un_sign_tx,omitempty"`
 	TxData          string                 `protobuf:"bytes,3,opt,name=tx_data,json=txData,proto3" json:"tx_data,omitempty"`
+	Fee             string                 `protobuf:"bytes,4,opt,name=fee,proto3" json:"fee,omitempty"`
+	Vsize           uint64                 `protobuf:"varint,5,opt,name=vsize,proto3" json:"vsize,omitempty"`
+	FeeRate         uint64                 `protobuf:"varint,6,opt,name=fee_rate,json=feeRate,proto3" json:"fee_rate,omitempty"`
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -683,6 +735,27 @@ func (x *ReturnTransactionHashes) GetTxData() string {
 	return ""
 }
 
+func (x *ReturnTransactionHashes) GetFee() string {
+	if x != nil {
+		return x.Fee
+	}
+	return ""
+}
+
+func (x *ReturnTransactionHashes) GetVsize() uint64 {
+	if x != nil {
+		return x.Vsize
+	}
+	return 0
+}
+
+func (x *ReturnTransactionHashes) GetFeeRate() uint64 {
+	if x != nil {
+		return x.FeeRate
+	}
+	return 0
+}
+
 type UnSignWithdrawTransactionResponse struct {
 	state          protoimpl.MessageState     `protogen:"open.v1"`
 	Code           ReturnCode                 `protobuf:"varint,1,opt,name=code,proto3,enum=syncs.ReturnCode" json:"code,omitempty"`
@@ -808,6 +881,7 @@ type SignedWithdrawTransactionRequest struct {
 	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
 	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
 	SignTxn       []*SignedTransactions  `protobuf:"bytes,3,rep,name=sign_txn,json=signTxn,proto3" json:"sign_txn,omitempty"`
+	Chain         string                 `protobuf:"bytes,4,opt,name=chain,proto3" json:"chain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -863,6 +937,13 @@ func (x *SignedWithdrawTransactionRequest) GetSignTxn() []*SignedTransactions {
 	return nil
 }
 
+func (x *SignedWithdrawTransactionRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
 type ReturnSignedTransactions struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	TransactionUuid string                 `protobuf:"bytes,1,opt,name=transaction_uuid,json=transactionUuid,proto3" json:"transaction_uuid,omitempty"`
@@ -1056,6 +1137,7 @@ type SubmitWithdrawRequest struct {
 	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
 	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
 	WithdrawList  []*Withdraw            `protobuf:"bytes,3,rep,name=withdraw_list,json=withdrawList,proto3" json:"withdraw_list,omitempty"`
+	Chain         string                 `protobuf:"bytes,4,opt,name=chain,proto3" json:"chain,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1111,6 +1193,13 @@ func (x *SubmitWithdrawRequest) GetWithdrawList() []*Withdraw {
 	return nil
 }
 
+func (x *SubmitWithdrawRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
 type SubmitWithdrawResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          ReturnCode             `protobuf:"varint,1,opt,name=code,proto3,enum=syncs.ReturnCode" json:"code,omitempty"`
@@ -1163,6 +1252,430 @@ func (x *SubmitWithdrawResponse) GetMsg() string {
 	return ""
 }
 
+type BumpWithdrawFeeRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ConsumerToken   string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
+	RequestId       string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	TransactionUuid string                 `protobuf:"bytes,3,opt,name=transaction_uuid,json=transactionUuid,proto3" json:"transaction_uuid,omitempty"`
+	FeeRate         uint64                 `protobuf:"varint,4,opt,name=fee_rate,json=feeRate,proto3" json:"fee_rate,omitempty"`
+	Chain           string                 `protobuf:"bytes,5,opt,name=chain,proto3" json:"chain,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *BumpWithdrawFeeRequest) Reset() {
+	*x = BumpWithdrawFeeRequest{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BumpWithdrawFeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BumpWithdrawFeeRequest) ProtoMessage() {}
+
+func (x *BumpWithdrawFeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BumpWithdrawFeeRequest.ProtoReflect.Descriptor instead.
+func (*BumpWithdrawFeeRequest) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BumpWithdrawFeeRequest) GetConsumerToken() string {
+	if x != nil {
+		return x.ConsumerToken
+	}
+	return ""
+}
+
+func (x *BumpWithdrawFeeRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *BumpWithdrawFeeRequest) GetTransactionUuid() string {
+	if x != nil {
+		return x.TransactionUuid
+	}
+	return ""
+}
+
+func (x *BumpWithdrawFeeRequest) GetFeeRate() uint64 {
+	if x != nil {
+		return x.FeeRate
+	}
+	return 0
+}
+
+func (x *BumpWithdrawFeeRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+type BumpWithdrawFeeResponse struct {
+	state                  protoimpl.MessageState     `protogen:"open.v1"`
+	Code                   ReturnCode                 `protobuf:"varint,1,opt,name=code,proto3,enum=syncs.ReturnCode" json:"code,omitempty"`
+	Msg                    string                     `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	ReplaceTransactionUuid string                     `protobuf:"bytes,3,opt,name=replace_transaction_uuid,json=replaceTransactionUuid,proto3" json:"replace_transaction_uuid,omitempty"`
+	ReturnTxHashes         []*ReturnTransactionHashes `protobuf:"bytes,4,rep,name=return_tx_hashes,json=returnTxHashes,proto3" json:"return_tx_hashes,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *BumpWithdrawFeeResponse) Reset() {
+	*x = BumpWithdrawFeeResponse{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BumpWithdrawFeeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BumpWithdrawFeeResponse) ProtoMessage() {}
+
+func (x *BumpWithdrawFeeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BumpWithdrawFeeResponse.ProtoReflect.Descriptor instead.
+func (*BumpWithdrawFeeResponse) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BumpWithdrawFeeResponse) GetCode() ReturnCode {
+	if x != nil {
+		return x.Code
+	}
+	return ReturnCode_ERROR
+}
+
+func (x *BumpWithdrawFeeResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *BumpWithdrawFeeResponse) GetReplaceTransactionUuid() string {
+	if x != nil {
+		return x.ReplaceTransactionUuid
+	}
+	return ""
+}
+
+func (x *BumpWithdrawFeeResponse) GetReturnTxHashes() []*ReturnTransactionHashes {
+	if x != nil {
+		return x.ReturnTxHashes
+	}
+	return nil
+}
+
+type CpfpTransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	TxHash        string                 `protobuf:"bytes,3,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	Index         uint32                 `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
+	FeeRate       uint64                 `protobuf:"varint,5,opt,name=fee_rate,json=feeRate,proto3" json:"fee_rate,omitempty"`
+	Chain         string                 `protobuf:"bytes,6,opt,name=chain,proto3" json:"chain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CpfpTransactionRequest) Reset() {
+	*x = CpfpTransactionRequest{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CpfpTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CpfpTransactionRequest) ProtoMessage() {}
+
+func (x *CpfpTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CpfpTransactionRequest.ProtoReflect.Descriptor instead.
+func (*CpfpTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *CpfpTransactionRequest) GetConsumerToken() string {
+	if x != nil {
+		return x.ConsumerToken
+	}
+	return ""
+}
+
+func (x *CpfpTransactionRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *CpfpTransactionRequest) GetTxHash() string {
+	if x != nil {
+		return x.TxHash
+	}
+	return ""
+}
+
+func (x *CpfpTransactionRequest) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *CpfpTransactionRequest) GetFeeRate() uint64 {
+	if x != nil {
+		return x.FeeRate
+	}
+	return 0
+}
+
+func (x *CpfpTransactionRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+type CpfpTransactionResponse struct {
+	state          protoimpl.MessageState     `protogen:"open.v1"`
+	Code           ReturnCode                 `protobuf:"varint,1,opt,name=code,proto3,enum=syncs.ReturnCode" json:"code,omitempty"`
+	Msg            string                     `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	PackageFeeRate uint64                     `protobuf:"varint,3,opt,name=package_fee_rate,json=packageFeeRate,proto3" json:"package_fee_rate,omitempty"`
+	ReturnTxHashes []*ReturnTransactionHashes `protobuf:"bytes,4,rep,name=return_tx_hashes,json=returnTxHashes,proto3" json:"return_tx_hashes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CpfpTransactionResponse) Reset() {
+	*x = CpfpTransactionResponse{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CpfpTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CpfpTransactionResponse) ProtoMessage() {}
+
+func (x *CpfpTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CpfpTransactionResponse.ProtoReflect.Descriptor instead.
+func (*CpfpTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *CpfpTransactionResponse) GetCode() ReturnCode {
+	if x != nil {
+		return x.Code
+	}
+	return ReturnCode_ERROR
+}
+
+func (x *CpfpTransactionResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *CpfpTransactionResponse) GetPackageFeeRate() uint64 {
+	if x != nil {
+		return x.PackageFeeRate
+	}
+	return 0
+}
+
+func (x *CpfpTransactionResponse) GetReturnTxHashes() []*ReturnTransactionHashes {
+	if x != nil {
+		return x.ReturnTxHashes
+	}
+	return nil
+}
+
+type RescanBlocksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConsumerToken string                 `protobuf:"bytes,1,opt,name=consumer_token,json=consumerToken,proto3" json:"consumer_token,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	FromHeight    uint64                 `protobuf:"varint,3,opt,name=from_height,json=fromHeight,proto3" json:"from_height,omitempty"`
+	ToHeight      uint64                 `protobuf:"varint,4,opt,name=to_height,json=toHeight,proto3" json:"to_height,omitempty"`
+	Chain         string                 `protobuf:"bytes,5,opt,name=chain,proto3" json:"chain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RescanBlocksRequest) Reset() {
+	*x = RescanBlocksRequest{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RescanBlocksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RescanBlocksRequest) ProtoMessage() {}
+
+func (x *RescanBlocksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RescanBlocksRequest.ProtoReflect.Descriptor instead.
+func (*RescanBlocksRequest) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RescanBlocksRequest) GetConsumerToken() string {
+	if x != nil {
+		return x.ConsumerToken
+	}
+	return ""
+}
+
+func (x *RescanBlocksRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *RescanBlocksRequest) GetFromHeight() uint64 {
+	if x != nil {
+		return x.FromHeight
+	}
+	return 0
+}
+
+func (x *RescanBlocksRequest) GetToHeight() uint64 {
+	if x != nil {
+		return x.ToHeight
+	}
+	return 0
+}
+
+func (x *RescanBlocksRequest) GetChain() string {
+	if x != nil {
+		return x.Chain
+	}
+	return ""
+}
+
+type RescanBlocksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          ReturnCode             `protobuf:"varint,1,opt,name=code,proto3,enum=syncs.ReturnCode" json:"code,omitempty"`
+	Msg           string                 `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RescanBlocksResponse) Reset() {
+	*x = RescanBlocksResponse{}
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RescanBlocksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RescanBlocksResponse) ProtoMessage() {}
+
+func (x *RescanBlocksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_dapplink_wallet_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RescanBlocksResponse.ProtoReflect.Descriptor instead.
+func (*RescanBlocksResponse) Descriptor() ([]byte, []int) {
+	return file_protobuf_dapplink_wallet_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RescanBlocksResponse) GetCode() ReturnCode {
+	if x != nil {
+		return x.Code
+	}
+	return ReturnCode_ERROR
+}
+
+func (x *RescanBlocksResponse) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
 var File_protobuf_dapplink_wallet_proto protoreflect.FileDescriptor
 
 const file_protobuf_dapplink_wallet_proto_rawDesc = "" +
@@ -1183,22 +1696,27 @@ const file_protobuf_dapplink_wallet_proto_rawDesc = "" +
 	"token_name\x18\x03 \x01(\tR\ttokenName\x12%\n" +
 	"\x0ecollect_amount\x18\x04 \x01(\tR\rcollectAmount\x12\x1f\n" +
 	"\vcold_amount\x18\x05 \x01(\tR\n" +
-	"coldAmount\"~\n" +
+	"coldAmount\"\x89\x02\n" +
 	"\x17BusinessRegisterRequest\x12%\n" +
 	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x02 \x01(\tR\trequestId\x12\x1d\n" +
 	"\n" +
-	"notify_url\x18\x03 \x01(\tR\tnotifyUrl\"S\n" +
+	"notify_url\x18\x03 \x01(\tR\tnotifyUrl\x12%\n" +
+	"\x0ecoin_selection\x18\x04 \x01(\tR\rcoinSelection\x12)\n" +
+	"\x11cpfp_max_fee_rate\x18\x05 \x01(\x04R\x0ecpfpMaxFeeRate\x12!\n" +
+	"\fstart_height\x18\x06 \x01(\x04R\vstartHeight\x12\x14\n" +
+	"\x05chain\x18\a \x01(\tR\x05chain\"S\n" +
 	"\x18BusinessRegisterResponse\x12%\n" +
 	"\x04Code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04Code\x12\x10\n" +
-	"\x03Msg\x18\x02 \x01(\tR\x03Msg\"\x91\x01\n" +
+	"\x03Msg\x18\x02 \x01(\tR\x03Msg\"\xa7\x01\n" +
 	"\x16ExportAddressesRequest\x12%\n" +
 	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x02 \x01(\tR\trequestId\x121\n" +
 	"\vpublic_keys\x18\x03 \x03(\v2\x10.syncs.PublicKeyR\n" +
-	"publicKeys\"\x80\x01\n" +
+	"publicKeys\x12\x14\n" +
+	"\x05chain\x18\x04 \x01(\tR\x05chain\"\x80\x01\n" +
 	"\x17ExportAddressesResponse\x12%\n" +
 	"\x04Code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04Code\x12\x10\n" +
 	"\x03msg\x18\x02 \x01(\tR\x03msg\x12,\n" +
@@ -1208,17 +1726,21 @@ const file_protobuf_dapplink_wallet_proto_rawDesc = "" +
 	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
 	"\x02to\x18\x03 \x01(\tR\x02to\x12\x14\n" +
 	"\x05value\x18\x04 \x01(\tR\x05value\x12\x17\n" +
-	"\atx_type\x18\x05 \x01(\tR\x06txType\"\x8f\x01\n" +
+	"\atx_type\x18\x05 \x01(\tR\x06txType\"\xa5\x01\n" +
 	" UnSignWithdrawTransactionRequest\x12%\n" +
 	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x02 \x01(\tR\trequestId\x12%\n" +
-	"\x03txn\x18\x03 \x03(\v2\x13.syncs.TransactionsR\x03txn\"{\n" +
+	"\x03txn\x18\x03 \x03(\v2\x13.syncs.TransactionsR\x03txn\x12\x14\n" +
+	"\x05chain\x18\x04 \x01(\tR\x05chain\"\xbe\x01\n" +
 	"\x17ReturnTransactionHashes\x12)\n" +
 	"\x10transaction_uuid\x18\x01 \x01(\tR\x0ftransactionUuid\x12\x1c\n" +
 	"\n" +
 	"un_sign_tx\x18\x02 \x01(\tR\bunSignTx\x12\x17\n" +
-	"\atx_data\x18\x03 \x01(\tR\x06txData\"\xa6\x01\n" +
+	"\atx_data\x18\x03 \x01(\tR\x06txData\x12\x10\n" +
+	"\x03fee\x18\x04 \x01(\tR\x03fee\x12\x14\n" +
+	"\x05vsize\x18\x05 \x01(\x04R\x05vsize\x12\x19\n" +
+	"\bfee_rate\x18\x06 \x01(\x04R\afeeRate\"\xa6\x01\n" +
 	"!UnSignWithdrawTransactionResponse\x12%\n" +
 	"\x04code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04code\x12\x10\n" +
 	"\x03msg\x18\x02 \x01(\tR\x03msg\x12H\n" +
@@ -1226,12 +1748,13 @@ const file_protobuf_dapplink_wallet_proto_rawDesc = "" +
 	"\x12SignedTransactions\x12)\n" +
 	"\x10transaction_uuid\x18\x01 \x01(\tR\x0ftransactionUuid\x12\x1c\n" +
 	"\tsignature\x18\x02 \x01(\tR\tsignature\x12\x17\n" +
-	"\atx_data\x18\x03 \x01(\tR\x06txData\"\x9e\x01\n" +
+	"\atx_data\x18\x03 \x01(\tR\x06txData\"\xb4\x01\n" +
 	" SignedWithdrawTransactionRequest\x12%\n" +
 	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x02 \x01(\tR\trequestId\x124\n" +
-	"\bsign_txn\x18\x03 \x03(\v2\x19.syncs.SignedTransactionsR\asignTxn\"w\n" +
+	"\bsign_txn\x18\x03 \x03(\v2\x19.syncs.SignedTransactionsR\asignTxn\x12\x14\n" +
+	"\x05chain\x18\x04 \x01(\tR\x05chain\"w\n" +
 	"\x18ReturnSignedTransactions\x12)\n" +
 	"\x10transaction_uuid\x18\x01 \x01(\tR\x0ftransactionUuid\x12\x1b\n" +
 	"\tsigned_tx\x18\x02 \x01(\tR\bsignedTx\x12\x13\n" +
@@ -1244,25 +1767,65 @@ const file_protobuf_dapplink_wallet_proto_rawDesc = "" +
 	"\bchain_id\x18\x01 \x01(\tR\achainId\x12\x18\n" +
 	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x14\n" +
 	"\x05value\x18\x03 \x01(\tR\x05value\x12\x10\n" +
-	"\x03fee\x18\x04 \x01(\tR\x03fee\"\x93\x01\n" +
+	"\x03fee\x18\x04 \x01(\tR\x03fee\"\xa9\x01\n" +
 	"\x15SubmitWithdrawRequest\x12%\n" +
 	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x02 \x01(\tR\trequestId\x124\n" +
-	"\rwithdraw_list\x18\x03 \x03(\v2\x0f.syncs.WithdrawR\fwithdrawList\"Q\n" +
+	"\rwithdraw_list\x18\x03 \x03(\v2\x0f.syncs.WithdrawR\fwithdrawList\x12\x14\n" +
+	"\x05chain\x18\x04 \x01(\tR\x05chain\"Q\n" +
 	"\x16SubmitWithdrawResponse\x12%\n" +
 	"\x04code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04code\x12\x10\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\"\xba\x01\n" +
+	"\x16BumpWithdrawFeeRequest\x12%\n" +
+	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12)\n" +
+	"\x10transaction_uuid\x18\x03 \x01(\tR\x0ftransactionUuid\x12\x19\n" +
+	"\bfee_rate\x18\x04 \x01(\x04R\afeeRate\x12\x14\n" +
+	"\x05chain\x18\x05 \x01(\tR\x05chain\"\xd6\x01\n" +
+	"\x17BumpWithdrawFeeResponse\x12%\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04code\x12\x10\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\x128\n" +
+	"\x18replace_transaction_uuid\x18\x03 \x01(\tR\x16replaceTransactionUuid\x12H\n" +
+	"\x10return_tx_hashes\x18\x04 \x03(\v2\x1e.syncs.ReturnTransactionHashesR\x0ereturnTxHashes\"\xbe\x01\n" +
+	"\x16CpfpTransactionRequest\x12%\n" +
+	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x17\n" +
+	"\atx_hash\x18\x03 \x01(\tR\x06txHash\x12\x14\n" +
+	"\x05index\x18\x04 \x01(\rR\x05index\x12\x19\n" +
+	"\bfee_rate\x18\x05 \x01(\x04R\afeeRate\x12\x14\n" +
+	"\x05chain\x18\x06 \x01(\tR\x05chain\"\xc6\x01\n" +
+	"\x17CpfpTransactionResponse\x12%\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04code\x12\x10\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\x12(\n" +
+	"\x10package_fee_rate\x18\x03 \x01(\x04R\x0epackageFeeRate\x12H\n" +
+	"\x10return_tx_hashes\x18\x04 \x03(\v2\x1e.syncs.ReturnTransactionHashesR\x0ereturnTxHashes\"\xaf\x01\n" +
+	"\x13RescanBlocksRequest\x12%\n" +
+	"\x0econsumer_token\x18\x01 \x01(\tR\rconsumerToken\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x1f\n" +
+	"\vfrom_height\x18\x03 \x01(\x04R\n" +
+	"fromHeight\x12\x1b\n" +
+	"\tto_height\x18\x04 \x01(\x04R\btoHeight\x12\x14\n" +
+	"\x05chain\x18\x05 \x01(\tR\x05chain\"O\n" +
+	"\x14RescanBlocksResponse\x12%\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x11.syncs.ReturnCodeR\x04code\x12\x10\n" +
 	"\x03msg\x18\x02 \x01(\tR\x03msg*$\n" +
 	"\n" +
 	"ReturnCode\x12\t\n" +
 	"\x05ERROR\x10\x00\x12\v\n" +
-	"\aSUCCESS\x10\x012\x82\x04\n" +
+	"\aSUCCESS\x10\x012\xfa\x05\n" +
 	"\x1aBusinessMiddleWireServices\x12U\n" +
 	"\x10businessRegister\x12\x1e.syncs.BusinessRegisterRequest\x1a\x1f.syncs.BusinessRegisterResponse\"\x00\x12^\n" +
 	"\x1bexportAddressesByPublicKeys\x12\x1d.syncs.ExportAddressesRequest\x1a\x1e.syncs.ExportAddressesResponse\"\x00\x12m\n" +
 	"\x16buildUnSignTransaction\x12'.syncs.UnSignWithdrawTransactionRequest\x1a(.syncs.UnSignWithdrawTransactionResponse\"\x00\x12m\n" +
 	"\x16buildSignedTransaction\x12'.syncs.SignedWithdrawTransactionRequest\x1a(.syncs.SignedWithdrawTransactionResponse\"\x00\x12O\n" +
-	"\x0esubmitWithdraw\x12\x1c.syncs.SubmitWithdrawRequest\x1a\x1d.syncs.SubmitWithdrawResponse\"\x00B\x1aZ\x18./protobuf/dal-wallet-gob\x06proto3"
+	"\x0esubmitWithdraw\x12\x1c.syncs.SubmitWithdrawRequest\x1a\x1d.syncs.SubmitWithdrawResponse\"\x00\x12R\n" +
+	"\x0fbumpWithdrawFee\x12\x1d.syncs.BumpWithdrawFeeRequest\x1a\x1e.syncs.BumpWithdrawFeeResponse\"\x00\x12W\n" +
+	"\x14buildCpfpTransaction\x12\x1d.syncs.CpfpTransactionRequest\x1a\x1e.syncs.CpfpTransactionResponse\"\x00\x12I\n" +
+	"\frescanBlocks\x12\x1a.syncs.RescanBlocksRequest\x1a\x1b.syncs.RescanBlocksResponse\"\x00B\x1aZ\x18./protobuf/dal-wallet-gob\x06proto3"
 
 var (
 	file_protobuf_dapplink_wallet_proto_rawDescOnce sync.Once
@@ -1277,7 +1840,7 @@ func file_protobuf_dapplink_wallet_proto_rawDescGZIP() []byte {
 }
 
 var file_protobuf_dapplink_wallet_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_protobuf_dapplink_wallet_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_protobuf_dapplink_wallet_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_protobuf_dapplink_wallet_proto_goTypes = []any{
 	(ReturnCode)(0),                           // 0: syncs.ReturnCode
 	(*PublicKey)(nil),                         // 1: syncs.PublicKey
@@ -1298,6 +1861,12 @@ var file_protobuf_dapplink_wallet_proto_goTypes = []any{
 	(*Withdraw)(nil),                          // 16: syncs.Withdraw
 	(*SubmitWithdrawRequest)(nil),             // 17: syncs.SubmitWithdrawRequest
 	(*SubmitWithdrawResponse)(nil),            // 18: syncs.SubmitWithdrawResponse
+	(*BumpWithdrawFeeRequest)(nil),            // 19: syncs.BumpWithdrawFeeRequest
+	(*BumpWithdrawFeeResponse)(nil),           // 20: syncs.BumpWithdrawFeeResponse
+	(*CpfpTransactionRequest)(nil),            // 21: syncs.CpfpTransactionRequest
+	(*CpfpTransactionResponse)(nil),           // 22: syncs.CpfpTransactionResponse
+	(*RescanBlocksRequest)(nil),               // 23: syncs.RescanBlocksRequest
+	(*RescanBlocksResponse)(nil),              // 24: syncs.RescanBlocksResponse
 }
 var file_protobuf_dapplink_wallet_proto_depIdxs = []int32{
 	0,  // 0: syncs.BusinessRegisterResponse.Code:type_name -> syncs.ReturnCode
@@ -1312,21 +1881,32 @@ var file_protobuf_dapplink_wallet_proto_depIdxs = []int32{
 	14, // 9: syncs.SignedWithdrawTransactionResponse.return_sign_txn:type_name -> syncs.ReturnSignedTransactions
 	16, // 10: syncs.SubmitWithdrawRequest.withdraw_list:type_name -> syncs.Withdraw
 	0,  // 11: syncs.SubmitWithdrawResponse.code:type_name -> syncs.ReturnCode
-	4,  // 12: syncs.BusinessMiddleWireServices.businessRegister:input_type -> syncs.BusinessRegisterRequest
-	6,  // 13: syncs.BusinessMiddleWireServices.exportAddressesByPublicKeys:input_type -> syncs.ExportAddressesRequest
-	9,  // 14: syncs.BusinessMiddleWireServices.buildUnSignTransaction:input_type -> syncs.UnSignWithdrawTransactionRequest
-	13, // 15: syncs.BusinessMiddleWireServices.buildSignedTransaction:input_type -> syncs.SignedWithdrawTransactionRequest
-	17, // 16: syncs.BusinessMiddleWireServices.submitWithdraw:input_type -> syncs.SubmitWithdrawRequest
-	5,  // 17: syncs.BusinessMiddleWireServices.businessRegister:output_type -> syncs.BusinessRegisterResponse
-	7,  // 18: syncs.BusinessMiddleWireServices.exportAddressesByPublicKeys:output_type -> syncs.ExportAddressesResponse
-	11, // 19: syncs.BusinessMiddleWireServices.buildUnSignTransaction:output_type -> syncs.UnSignWithdrawTransactionResponse
-	15, // 20: syncs.BusinessMiddleWireServices.buildSignedTransaction:output_type -> syncs.SignedWithdrawTransactionResponse
-	18, // 21: syncs.BusinessMiddleWireServices.submitWithdraw:output_type -> syncs.SubmitWithdrawResponse
-	17, // [17:22] is the sub-list for method output_type
-	12, // [12:17] is the sub-list for method input_type
-	12, // [12:12] is the sub-list for extension type_name
-	12, // [12:12] is the sub-list for extension extendee
-	0,  // [0:12] is the sub-list for field type_name
+	0,  // 12: syncs.BumpWithdrawFeeResponse.code:type_name -> syncs.ReturnCode
+	10, // 13: syncs.BumpWithdrawFeeResponse.return_tx_hashes:type_name -> syncs.ReturnTransactionHashes
+	0,  // 14: syncs.CpfpTransactionResponse.code:type_name -> syncs.ReturnCode
+	10, // 15: syncs.CpfpTransactionResponse.return_tx_hashes:type_name -> syncs.ReturnTransactionHashes
+	0,  // 16: syncs.RescanBlocksResponse.code:type_name -> syncs.ReturnCode
+	4,  // 17: syncs.BusinessMiddleWireServices.businessRegister:input_type -> syncs.BusinessRegisterRequest
+	6,  // 18: syncs.BusinessMiddleWireServices.exportAddressesByPublicKeys:input_type -> syncs.ExportAddressesRequest
+	9,  // 19: syncs.BusinessMiddleWireServices.buildUnSignTransaction:input_type -> syncs.UnSignWithdrawTransactionRequest
+	13, // 20: syncs.BusinessMiddleWireServices.buildSignedTransaction:input_type -> syncs.SignedWithdrawTransactionRequest
+	17, // 21: syncs.BusinessMiddleWireServices.submitWithdraw:input_type -> syncs.SubmitWithdrawRequest
+	19, // 22: syncs.BusinessMiddleWireServices.bumpWithdrawFee:input_type -> syncs.BumpWithdrawFeeRequest
+	21, // 23: syncs.BusinessMiddleWireServices.buildCpfpTransaction:input_type -> syncs.CpfpTransactionRequest
+	23, // 24: syncs.BusinessMiddleWireServices.rescanBlocks:input_type -> syncs.RescanBlocksRequest
+	5,  // 25: syncs.BusinessMiddleWireServices.businessRegister:output_type -> syncs.BusinessRegisterResponse
+	7,  // 26: syncs.BusinessMiddleWireServices.exportAddressesByPublicKeys:output_type -> syncs.ExportAddressesResponse
+	11, // 27: syncs.BusinessMiddleWireServices.buildUnSignTransaction:output_type -> syncs.UnSignWithdrawTransactionResponse
+	15, // 28: syncs.BusinessMiddleWireServices.buildSignedTransaction:output_type -> syncs.SignedWithdrawTransactionResponse
+	18, // 29: syncs.BusinessMiddleWireServices.submitWithdraw:output_type -> syncs.SubmitWithdrawResponse
+	20, // 30: syncs.BusinessMiddleWireServices.bumpWithdrawFee:output_type -> syncs.BumpWithdrawFeeResponse
+	22, // 31: syncs.BusinessMiddleWireServices.buildCpfpTransaction:output_type -> syncs.CpfpTransactionResponse
+	24, // 32: syncs.BusinessMiddleWireServices.rescanBlocks:output_type -> syncs.RescanBlocksResponse
+	25, // [25:33] is the sub-list for method output_type
+	17, // [17:25] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
 }
 
 func init() { file_protobuf_dapplink_wallet_proto_init() }
@@ -1340,7 +1920,7 @@ func file_protobuf_dapplink_wallet_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_protobuf_dapplink_wallet_proto_rawDesc), len(file_protobuf_dapplink_wallet_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   18,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -24,6 +24,9 @@ const (
 	BusinessMiddleWireServices_BuildUnSignTransaction_FullMethodName      = "/syncs.BusinessMiddleWireServices/buildUnSignTransaction"
 	BusinessMiddleWireServices_BuildSignedTransaction_FullMethodName      = "/syncs.BusinessMiddleWireServices/buildSignedTransaction"
 	BusinessMiddleWireServices_SubmitWithdraw_FullMethodName              = "/syncs.BusinessMiddleWireServices/submitWithdraw"
+	BusinessMiddleWireServices_BumpWithdrawFee_FullMethodName             = "/syncs.BusinessMiddleWireServices/bumpWithdrawFee"
+	BusinessMiddleWireServices_BuildCpfpTransaction_FullMethodName        = "/syncs.BusinessMiddleWireServices/buildCpfpTransaction"
+	BusinessMiddleWireServices_RescanBlocks_FullMethodName                = "/syncs.BusinessMiddleWireServices/rescanBlocks"
 )
 
 // BusinessMiddleWireServicesClient is the client API for BusinessMiddleWireServices service.
@@ -36,6 +39,12 @@ type BusinessMiddleWireServicesClient interface {
 	BuildSignedTransaction(ctx context.Context, in *SignedWithdrawTransactionRequest, opts ...grpc.CallOption) (*SignedWithdrawTransactionResponse, error)
 	// 提交提现交易
 	SubmitWithdraw(ctx context.Context, in *SubmitWithdrawRequest, opts ...grpc.CallOption) (*SubmitWithdrawResponse, error)
+	// 卡住的提现构建 RBF 替换交易
+	BumpWithdrawFee(ctx context.Context, in *BumpWithdrawFeeRequest, opts ...grpc.CallOption) (*BumpWithdrawFeeResponse, error)
+	// 低费率充值构建 CPFP 子交易
+	BuildCpfpTransaction(ctx context.Context, in *CpfpTransactionRequest, opts ...grpc.CallOption) (*CpfpTransactionResponse, error)
+	// 重新处理指定区间的区块，request_id 为空时处理所有业务方
+	RescanBlocks(ctx context.Context, in *RescanBlocksRequest, opts ...grpc.CallOption) (*RescanBlocksResponse, error)
 }
 
 type businessMiddleWireServicesClient struct {
@@ -96,6 +105,36 @@ func (c *businessMiddleWireServicesClient) SubmitWithdraw(ctx context.Context, i
 	return out, nil
 }
 
+func (c *businessMiddleWireServicesClient) BumpWithdrawFee(ctx context.Context, in *BumpWithdrawFeeRequest, opts ...grpc.CallOption) (*BumpWithdrawFeeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BumpWithdrawFeeResponse)
+	err := c.cc.Invoke(ctx, BusinessMiddleWireServices_BumpWithdrawFee_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessMiddleWireServicesClient) BuildCpfpTransaction(ctx context.Context, in *CpfpTransactionRequest, opts ...grpc.CallOption) (*CpfpTransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CpfpTransactionResponse)
+	err := c.cc.Invoke(ctx, BusinessMiddleWireServices_BuildCpfpTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *businessMiddleWireServicesClient) RescanBlocks(ctx context.Context, in *RescanBlocksRequest, opts ...grpc.CallOption) (*RescanBlocksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RescanBlocksResponse)
+	err := c.cc.Invoke(ctx, BusinessMiddleWireServices_RescanBlocks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BusinessMiddleWireServicesServer is the server API for BusinessMiddleWireServices service.
 // All implementations should embed UnimplementedBusinessMiddleWireServicesServer
 // for forward compatibility.
@@ -106,6 +145,12 @@ type BusinessMiddleWireServicesServer interface {
 	BuildSignedTransaction(context.Context, *SignedWithdrawTransactionRequest) (*SignedWithdrawTransactionResponse, error)
 	// 提交提现交易
 	SubmitWithdraw(context.Context, *SubmitWithdrawRequest) (*SubmitWithdrawResponse, error)
+	// 卡住的提现构建 RBF 替换交易
+	BumpWithdrawFee(context.Context, *BumpWithdrawFeeRequest) (*BumpWithdrawFeeResponse, error)
+	// 低费率充值构建 CPFP 子交易
+	BuildCpfpTransaction(context.Context, *CpfpTransactionRequest) (*CpfpTransactionResponse, error)
+	// 重新处理指定区间的区块，request_id 为空时处理所有业务方
+	RescanBlocks(context.Context, *RescanBlocksRequest) (*RescanBlocksResponse, error)
 }
 
 // UnimplementedBusinessMiddleWireServicesServer should be embedded to have
@@ -130,6 +175,15 @@ func (UnimplementedBusinessMiddleWireServicesServer) BuildSignedTransaction(cont
 func (UnimplementedBusinessMiddleWireServicesServer) SubmitWithdraw(context.Context, *SubmitWithdrawRequest) (*SubmitWithdrawResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SubmitWithdraw not implemented")
 }
+func (UnimplementedBusinessMiddleWireServicesServer) BumpWithdrawFee(context.Context, *BumpWithdrawFeeRequest) (*BumpWithdrawFeeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BumpWithdrawFee not implemented")
+}
+func (UnimplementedBusinessMiddleWireServicesServer) BuildCpfpTransaction(context.Context, *CpfpTransactionRequest) (*CpfpTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuildCpfpTransaction not implemented")
+}
+func (UnimplementedBusinessMiddleWireServicesServer) RescanBlocks(context.Context, *RescanBlocksRequest) (*RescanBlocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RescanBlocks not implemented")
+}
 func (UnimplementedBusinessMiddleWireServicesServer) testEmbeddedByValue() {}
 
 // UnsafeBusinessMiddleWireServicesServer may be embedded to opt out of forward compatibility for this service.
@@ -240,6 +294,60 @@ func _BusinessMiddleWireServices_SubmitWithdraw_Handler(srv interface{}, ctx con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BusinessMiddleWireServices_BumpWithdrawFee_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BumpWithdrawFeeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessMiddleWireServicesServer).BumpWithdrawFee(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BusinessMiddleWireServices_BumpWithdrawFee_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessMiddleWireServicesServer).BumpWithdrawFee(ctx, req.(*BumpWithdrawFeeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessMiddleWireServices_BuildCpfpTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CpfpTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessMiddleWireServicesServer).BuildCpfpTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BusinessMiddleWireServices_BuildCpfpTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessMiddleWireServicesServer).BuildCpfpTransaction(ctx, req.(*CpfpTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BusinessMiddleWireServices_RescanBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RescanBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BusinessMiddleWireServicesServer).RescanBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BusinessMiddleWireServices_RescanBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BusinessMiddleWireServicesServer).RescanBlocks(ctx, req.(*RescanBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BusinessMiddleWireServices_ServiceDesc is the grpc.ServiceDesc for BusinessMiddleWireServices service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,6 +375,18 @@ var BusinessMiddleWireServices_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "submitWithdraw",
 			Handler:    _BusinessMiddleWireServices_SubmitWithdraw_Handler,
 		},
+		{
+			MethodName: "bumpWithdrawFee",
+			Handler:    _BusinessMiddleWireServices_BumpWithdrawFee_Handler,
+		},
+		{
+			MethodName: "buildCpfpTransaction",
+			Handler:    _BusinessMiddleWireServices_BuildCpfpTransaction_Handler,
+		},
+		{
+			MethodName: "rescanBlocks",
+			Handler:    _BusinessMiddleWireServices_RescanBlocks_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protobuf/dapplink-wallet.proto",
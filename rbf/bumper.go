@@ -0,0 +1,254 @@
+package rbf
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
+)
+
+var (
+	ErrWithdrawNotFound   = errors.New("withdraw not found")
+	ErrWithdrawNotStuck   = errors.New("withdraw is not stuck")
+	ErrReplacementPending = errors.New("withdraw has a replacement waiting to be sent")
+	ErrNoLockedVins       = errors.New("withdraw has no locked vins")
+	ErrNoWithdrawVouts    = errors.New("withdraw outputs not found")
+	ErrFeeRateTooHigh     = errors.New("replacement fee rate exceeds limit")
+	ErrRbfNotSupported    = errors.New("chain does not support replace by fee")
+)
+
+// Result 替换交易的待签名数据，签名后走 buildSignedTransaction 流程
+type Result struct {
+	Withdraw *database.Withdraws
+	UnSignTx string
+	TxData   string
+	Estimate *txfee.Estimate
+}
+
+// TxBuilder 根据输入、输出构建待签名交易，由上游 WalletUtxoService 实现
+type TxBuilder interface {
+	CreateUnSignTransaction(vins []*utxo.Vin, vouts []*utxo.Vout, fee int64) (*utxo.UnSignTransactionResponse, error)
+}
+
+// Bumper 为卡住的提现构建花费相同输入、费率更高的 BIP125 替换交易
+type Bumper struct {
+	db          *database.DB
+	params      *chaincfg.Params
+	txBuilder   TxBuilder
+	chainSource syncclient.ChainSource
+	maxFeeRate  int64
+}
+
+// NewBumper txBuilder 构建替换交易，chainSource 用于查询当前网络费率，
+// maxFeeRate 为替换交易允许的最高费率（聪/虚拟字节），为 0 时不限制
+func NewBumper(db *database.DB, params *chaincfg.Params, txBuilder TxBuilder, chainSource syncclient.ChainSource, maxFeeRate int64) *Bumper {
+	return &Bumper{db: db, params: params, txBuilder: txBuilder, chainSource: chainSource, maxFeeRate: maxFeeRate}
+}
+
+// BumpFee 为 stuck 状态的提现构建替换交易并以 status 状态入库，替换交易通过 replace_guid 关联原始提现；
+// feeRate 单位为 聪/虚拟字节，为 0 时使用当前网络费率，不足 BIP125 要求时自动提高
+func (b *Bumper) BumpFee(businessId string, transactionId string, feeRate int64, status database.TxStatus) (*Result, error) {
+	if !b.params.ReplaceByFee {
+		return nil, ErrRbfNotSupported
+	}
+	withdraw, err := b.db.Withdraws.QueryWithdrawByGuid(businessId, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	if withdraw == nil {
+		return nil, ErrWithdrawNotFound
+	}
+	if withdraw.Status != database.TxStatusStuck {
+		return nil, ErrWithdrawNotStuck
+	}
+	originGuid := withdraw.ReplaceGuid
+	if originGuid == "" {
+		originGuid = withdraw.Guid.String()
+	}
+
+	// 替换交易需要比内存池中同组的所有版本付出更多手续费
+	replaceWithdraws, err := b.db.Withdraws.QueryReplaceWithdraws(businessId, originGuid)
+	if err != nil {
+		return nil, err
+	}
+	originalFee := new(big.Int)
+	for _, item := range replaceWithdraws {
+		if containsStatus(database.WithdrawPendingStatuses, item.Status) {
+			return nil, ErrReplacementPending
+		}
+		if containsStatus(database.WithdrawTrackStatuses, item.Status) && item.Fee != nil && item.Fee.Cmp(originalFee) > 0 {
+			originalFee = item.Fee
+		}
+	}
+
+	vins, err := b.db.Vins.QueryVinsByLockTxId(businessId, originGuid)
+	if err != nil {
+		return nil, err
+	}
+	if len(vins) == 0 {
+		return nil, ErrNoLockedVins
+	}
+	vouts, err := b.withdrawVouts(businessId, originGuid)
+	if err != nil {
+		return nil, err
+	}
+
+	changeAddress := vins[0].Address
+	inputType, err := b.params.ScriptType(changeAddress)
+	if err != nil {
+		return nil, err
+	}
+	params := txfee.ReplacementParams{
+		InputType:   inputType,
+		OriginalFee: originalFee.Int64(),
+		FeeRate:     feeRate,
+		DustLimit:   b.params.DustLimit,
+	}
+	var utxoVins []*utxo.Vin
+	for _, vin := range vins {
+		params.InputAmounts = append(params.InputAmounts, vin.Amount.Int64())
+		utxoVins = append(utxoVins, &utxo.Vin{
+			Hash:    vin.TxId,
+			Index:   vin.Vout,
+			Amount:  vin.Amount.Int64(),
+			Address: vin.Address,
+		})
+	}
+	for _, vout := range vouts {
+		outputType, err := b.params.ScriptType(vout.Address)
+		if err != nil {
+			return nil, err
+		}
+		params.OutputTypes = append(params.OutputTypes, outputType)
+		params.OutputAmounts = append(params.OutputAmounts, vout.Amount)
+	}
+	if params.FeeRate == 0 {
+		params.FeeRate, err = syncclient.EstimateFeeRate(b.chainSource, b.params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replacement, err := txfee.EstimateReplacement(params)
+	if err != nil {
+		return nil, err
+	}
+	if b.maxFeeRate > 0 && replacement.Estimate.FeeRate > b.maxFeeRate {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFeeRateTooHigh, replacement.Estimate.FeeRate, b.maxFeeRate)
+	}
+	utxoVouts := vouts
+	if replacement.Change > 0 {
+		utxoVouts = append(utxoVouts[:len(utxoVouts):len(utxoVouts)], &utxo.Vout{
+			Address: changeAddress,
+			Amount:  replacement.Change,
+			Index:   uint32(len(utxoVouts)),
+		})
+	}
+
+	unSignTx, err := b.txBuilder.CreateUnSignTransaction(utxoVins, utxoVouts, replacement.Estimate.Fee)
+	if err != nil {
+		return nil, err
+	}
+	var signHashStr string
+	for _, signHash := range unSignTx.SignHashes {
+		signHashStr += string(signHash) + "|"
+	}
+
+	now := uint64(time.Now().Unix())
+	replaceWithdraw := &database.Withdraws{
+		Guid:        uuid.New(),
+		BlockHash:   "0x00",
+		BlockNumber: big.NewInt(0),
+		Hash:        "0x00",
+		Fee:         big.NewInt(replacement.Estimate.Fee),
+		LockTime:    big.NewInt(0),
+		Version:     "0x00",
+		TxSignHex:   "0x00",
+		Status:      status,
+		ReplaceGuid: originGuid,
+		UnSignTx:    signHashStr,
+		TxData:      string(unSignTx.TxData),
+		Timestamp:   now,
+	}
+	var childTxs []database.ChildTxs
+	for _, vout := range vouts {
+		childTxs = append(childTxs, database.ChildTxs{
+			GUID:        uuid.New(),
+			Hash:        "0x00",
+			TxId:        replaceWithdraw.Guid.String(),
+			TxIndex:     big.NewInt(int64(vout.Index)),
+			TxType:      "withdraw_vout",
+			FromAddress: changeAddress,
+			ToAddress:   vout.Address,
+			Amount:      strconv.FormatInt(vout.Amount, 10),
+			Timestamp:   now,
+		})
+	}
+	if err := b.db.Transaction(func(tx *database.DB) error {
+		if err := tx.ChildTxs.StoreChildTxs(businessId, childTxs); err != nil {
+			return err
+		}
+		return tx.Withdraws.StoreWithdraws(businessId, replaceWithdraw)
+	}); err != nil {
+		return nil, err
+	}
+	log.Info("build replacement withdraw success", "businessId", businessId, "transactionId", transactionId,
+		"replaceTransactionId", replaceWithdraw.Guid, "fee", replacement.Estimate.Fee, "feeRate", replacement.Estimate.FeeRate)
+
+	return &Result{
+		Withdraw: replaceWithdraw,
+		UnSignTx: signHashStr,
+		TxData:   replaceWithdraw.TxData,
+		Estimate: replacement.Estimate,
+	}, nil
+}
+
+// withdrawVouts 取出构建提现时记录的输出，不含找零
+func (b *Bumper) withdrawVouts(businessId string, originGuid string) ([]*utxo.Vout, error) {
+	childTxs, err := b.db.ChildTxs.QueryChildTxnByTxId(businessId, originGuid)
+	if err != nil {
+		return nil, err
+	}
+	var vouts []*utxo.Vout
+	for _, childTx := range childTxs {
+		if childTx.TxType != "withdraw_vout" {
+			continue
+		}
+		amount, err := strconv.ParseInt(childTx.Amount, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid withdraw vout amount %s: %w", childTx.Amount, err)
+		}
+		vouts = append(vouts, &utxo.Vout{
+			Address: childTx.ToAddress,
+			Amount:  amount,
+			Index:   uint32(childTx.TxIndex.Uint64()),
+		})
+	}
+	if len(vouts) == 0 {
+		return nil, ErrNoWithdrawVouts
+	}
+	sort.Slice(vouts, func(i, j int) bool {
+		return vouts[i].Index < vouts[j].Index
+	})
+	return vouts, nil
+}
+
+func containsStatus(statuses []database.TxStatus, status database.TxStatus) bool {
+	for _, item := range statuses {
+		if item == status {
+			return true
+		}
+	}
+	return false
+}
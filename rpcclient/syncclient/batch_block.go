@@ -8,26 +8,31 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 
 	"github.com/0xshin-chan/multichain-sync-btc/common/bigint"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
 )
 
 var (
-	ErrBatchBlockAheadOfProvider = errors.New("the BatchBlock's internal state is ahead of the provider")
+	ErrBatchBlockAheadOfProvider            = errors.New("the BatchBlock's internal state is ahead of the provider")
+	ErrBatchBlockAndProviderMismatchedState = errors.New("the BatchBlock and provider have diverged in state")
 )
 
 type BatchBlock struct {
-	rpcClient *WalletBtcAccountClient
+	rpcClient ChainSource
 
 	latestHeader        *BlockHeader
 	lastTraversedHeader *BlockHeader
 
 	blockConfirmationDepth *big.Int
+	parallelism            int
 }
 
-func NewBatchBlock(rpcClient *WalletBtcAccountClient, fromHeader *BlockHeader, confDepth *big.Int) *BatchBlock {
+// NewBatchBlock parallelism 为并发拉取区块头和区块内容的最大请求数
+func NewBatchBlock(rpcClient ChainSource, fromHeader *BlockHeader, confDepth *big.Int, parallelism int) *BatchBlock {
 	return &BatchBlock{
 		rpcClient:              rpcClient,
 		lastTraversedHeader:    fromHeader,
 		blockConfirmationDepth: confDepth,
+		parallelism:            parallelism,
 	}
 }
 
@@ -39,6 +44,11 @@ func (f *BatchBlock) LastTraversedHeader() *BlockHeader {
 	return f.lastTraversedHeader
 }
 
+// Reset 将遍历位置回退到指定区块，链重组回滚完成后由同步器调用
+func (f *BatchBlock) Reset(header *BlockHeader) {
+	f.lastTraversedHeader = header
+}
+
 func (f *BatchBlock) NextHeaders(maxSize uint64) ([]BlockHeader, error) {
 	latestHeader, err := f.rpcClient.GetBlockHeader(nil)
 	if err != nil {
@@ -66,15 +76,17 @@ func (f *BatchBlock) NextHeaders(maxSize uint64) ([]BlockHeader, error) {
 	}
 	endHeight = bigint.Clamp(nextHeight, endHeight, maxSize)
 	count := new(big.Int).Sub(endHeight, nextHeight).Uint64() + 1
-	headers := make([]BlockHeader, count)
-	for i := uint64(0); i < count; i++ {
+	headers, err := fetchOrdered(count, f.parallelism, func(i uint64) (BlockHeader, error) {
 		height := new(big.Int).Add(nextHeight, new(big.Int).SetUint64(i))
 		blockHeader, err := f.rpcClient.GetBlockHeader(height)
 		if err != nil {
-			log.Error("get block info fail", "err", err)
-			return nil, err
+			log.Error("get block info fail", "height", height, "err", err)
+			return BlockHeader{}, err
 		}
-		headers[i] = *blockHeader
+		return *blockHeader, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	numHeaders := len(headers)
@@ -82,6 +94,41 @@ func (f *BatchBlock) NextHeaders(maxSize uint64) ([]BlockHeader, error) {
 		return nil, nil
 	}
 
+	// 新区块必须和上一个已遍历区块首尾相连，否则说明链发生了重组
+	if f.lastTraversedHeader != nil && headers[0].PrevHash != f.lastTraversedHeader.Hash {
+		log.Warn("block prev hash mismatch", "number", headers[0].Number, "prevHash", headers[0].PrevHash, "source", headers[0].Source,
+			"lastHash", f.lastTraversedHeader.Hash, "lastSource", f.lastTraversedHeader.Source)
+		return nil, ErrBatchBlockAndProviderMismatchedState
+	}
+	for i := 1; i < numHeaders; i++ {
+		if headers[i].PrevHash != headers[i-1].Hash {
+			log.Warn("block prev hash mismatch", "number", headers[i].Number, "prevHash", headers[i].PrevHash, "source", headers[i].Source,
+				"lastHash", headers[i-1].Hash, "lastSource", headers[i-1].Source)
+			return nil, ErrBatchBlockAndProviderMismatchedState
+		}
+	}
+
 	f.lastTraversedHeader = &headers[numHeaders-1]
 	return headers, nil
 }
+
+// HeadersByRange 并发拉取 [start, end] 之间的区块头，不改变遍历位置，用于补扫历史区块
+func (f *BatchBlock) HeadersByRange(start uint64, end uint64) ([]BlockHeader, error) {
+	if end < start {
+		return nil, nil
+	}
+	return fetchOrdered(end-start+1, f.parallelism, func(i uint64) (BlockHeader, error) {
+		blockHeader, err := f.rpcClient.GetBlockHeader(new(big.Int).SetUint64(start + i))
+		if err != nil {
+			return BlockHeader{}, err
+		}
+		return *blockHeader, nil
+	})
+}
+
+// Blocks 并发拉取区块内容，结果与 headers 的顺序一致
+func (f *BatchBlock) Blocks(headers []BlockHeader) ([][]*utxo.TransactionList, error) {
+	return fetchOrdered(uint64(len(headers)), f.parallelism, func(i uint64) ([]*utxo.TransactionList, error) {
+		return f.rpcClient.GetBlockByHash(headers[i].Hash)
+	})
+}
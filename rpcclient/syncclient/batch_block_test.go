@@ -0,0 +1,119 @@
+package syncclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// mockUtxoClient 只实现区块头查询，hashes[i] 为高度 i 的区块 hash
+type mockUtxoClient struct {
+	utxo.WalletUtxoServiceClient
+	hashes []string
+}
+
+func (m *mockUtxoClient) GetBlockHeaderByNumber(_ context.Context, in *utxo.BlockHeaderNumberRequest, _ ...grpc.CallOption) (*utxo.BlockHeaderResponse, error) {
+	height := in.Height
+	if height == 0 {
+		height = int64(len(m.hashes) - 1)
+	}
+	parentHash := ""
+	if height > 0 {
+		parentHash = m.hashes[height-1]
+	}
+	return &utxo.BlockHeaderResponse{
+		BlockHash:  m.hashes[height],
+		ParentHash: parentHash,
+		Number:     fmt.Sprintf("%d", height),
+	}, nil
+}
+
+func newMockChain(size int, prefix string) []string {
+	hashes := make([]string, size)
+	for i := range hashes {
+		hashes[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return hashes
+}
+
+func TestNextHeadersContinuous(t *testing.T) {
+	mock := &mockUtxoClient{hashes: newMockChain(10, "a")}
+	client, err := NewWalletBtcAccountClient(context.Background(), mock, "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	from, err := client.GetBlockHeader(big.NewInt(3))
+	require.NoError(t, err)
+	batch := NewBatchBlock(client, from, big.NewInt(0), 4)
+
+	headers, err := batch.NextHeaders(3)
+	require.NoError(t, err)
+	require.Len(t, headers, 3)
+	require.Equal(t, "a6", batch.LastTraversedHeader().Hash)
+}
+
+func TestNextHeadersReorg(t *testing.T) {
+	mock := &mockUtxoClient{hashes: newMockChain(10, "a")}
+	client, err := NewWalletBtcAccountClient(context.Background(), mock, "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	from, err := client.GetBlockHeader(big.NewInt(5))
+	require.NoError(t, err)
+	batch := NewBatchBlock(client, from, big.NewInt(0), 4)
+
+	// 高度 5 之后的区块被替换
+	mock.hashes = append(mock.hashes[:5], newMockChain(10, "b")[5:]...)
+	headers, err := batch.NextHeaders(3)
+	require.ErrorIs(t, err, ErrBatchBlockAndProviderMismatchedState)
+	require.Nil(t, headers)
+	require.Equal(t, "a5", batch.LastTraversedHeader().Hash)
+
+	ancestor, err := client.GetBlockHeader(big.NewInt(4))
+	require.NoError(t, err)
+	batch.Reset(ancestor)
+	headers, err = batch.NextHeaders(3)
+	require.NoError(t, err)
+	require.Equal(t, "b5", headers[0].Hash)
+}
+
+func TestFetchOrdered(t *testing.T) {
+	results, err := fetchOrdered(50, 8, func(i uint64) (uint64, error) {
+		return i * 2, nil
+	})
+	require.NoError(t, err)
+	for i, result := range results {
+		require.Equal(t, uint64(i*2), result)
+	}
+
+	fetchErr := fmt.Errorf("fetch fail")
+	_, err = fetchOrdered(50, 8, func(i uint64) (uint64, error) {
+		if i == 17 {
+			return 0, fetchErr
+		}
+		return i, nil
+	})
+	require.ErrorIs(t, err, fetchErr)
+}
+
+func TestNextHeadersParallel(t *testing.T) {
+	mock := &mockUtxoClient{hashes: newMockChain(600, "a")}
+	client, err := NewWalletBtcAccountClient(context.Background(), mock, "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	from, err := client.GetBlockHeader(big.NewInt(1))
+	require.NoError(t, err)
+	batch := NewBatchBlock(client, from, big.NewInt(0), 16)
+
+	headers, err := batch.NextHeaders(500)
+	require.NoError(t, err)
+	require.Len(t, headers, 500)
+	for i, header := range headers {
+		require.Equal(t, fmt.Sprintf("a%d", i+2), header.Hash)
+	}
+}
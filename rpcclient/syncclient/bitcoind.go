@@ -0,0 +1,563 @@
+package syncclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
+)
+
+const (
+	bitcoindTimeout = 60 * time.Second
+	// prevoutBatchSize 批量查询输入对应的上一笔交易时每次请求的交易数
+	prevoutBatchSize = 100
+	// feeConfTarget estimatesmartfee 的目标确认区块数
+	feeConfTarget = 6
+
+	// bitcoind 的错误码，见 src/rpc/protocol.h
+	rpcMethodNotFound       = -32601
+	rpcInvalidAddressOrKey  = -5
+	rpcDeserializationError = -22
+	rpcVerifyError          = -25
+	rpcVerifyRejected       = -26
+	rpcVerifyAlreadyInChain = -27
+)
+
+// RpcError bitcoind JSON-RPC 返回的错误
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("bitcoind rpc error %d: %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Id     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RpcError       `json:"error"`
+}
+
+type bitcoindHeader struct {
+	Hash              string `json:"hash"`
+	Height            int64  `json:"height"`
+	Time              uint64 `json:"time"`
+	PreviousBlockHash string `json:"previousblockhash"`
+}
+
+type bitcoindBlock struct {
+	Hash string       `json:"hash"`
+	Tx   []bitcoindTx `json:"tx"`
+}
+
+type bitcoindTx struct {
+	Txid          string         `json:"txid"`
+	Vin           []bitcoindVin  `json:"vin"`
+	Vout          []bitcoindVout `json:"vout"`
+	Fee           json.Number    `json:"fee"`
+	BlockHash     string         `json:"blockhash"`
+	Confirmations int64          `json:"confirmations"`
+}
+
+type bitcoindVin struct {
+	Txid     string        `json:"txid"`
+	Vout     uint32        `json:"vout"`
+	Coinbase string        `json:"coinbase"`
+	Prevout  *bitcoindVout `json:"prevout"`
+}
+
+type bitcoindVout struct {
+	Value        json.Number `json:"value"`
+	N            uint32      `json:"n"`
+	ScriptPubKey struct {
+		Address   string   `json:"address"`
+		Addresses []string `json:"addresses"`
+	} `json:"scriptPubKey"`
+}
+
+// address 新版本节点返回 address，旧版本返回 addresses，多个地址以 | 分隔，非标准脚本返回空
+func (v *bitcoindVout) address() string {
+	if v.ScriptPubKey.Address != "" {
+		return v.ScriptPubKey.Address
+	}
+	return strings.Join(v.ScriptPubKey.Addresses, "|")
+}
+
+// BitcoindClient 通过 JSON-RPC 直连 Bitcoin Core 实现 ChainSource；
+// 区块按 getblock verbosity 2 获取，输入的地址和金额通过 getrawtransaction 查询上一笔交易补全，节点需要开启 txindex
+type BitcoindClient struct {
+	url      string
+	user     string
+	password string
+	source   string
+	client   *http.Client
+	nextId   atomic.Uint64
+}
+
+func NewBitcoindClient(rpcUrl, user, password string) (*BitcoindClient, error) {
+	parsed, err := url.Parse(rpcUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitcoind rpc url: %w", err)
+	}
+	if parsed.User != nil && user == "" {
+		user = parsed.User.Username()
+		password, _ = parsed.User.Password()
+		parsed.User = nil
+	}
+	log.Info("New bitcoind rpc client", "host", parsed.Host)
+	return &BitcoindClient{
+		url:      parsed.String(),
+		user:     user,
+		password: password,
+		source:   parsed.Host,
+		client:   &http.Client{Timeout: bitcoindTimeout},
+	}, nil
+}
+
+func (c *BitcoindClient) newRequest(method string, params ...interface{}) rpcRequest {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return rpcRequest{JsonRpc: "1.0", Id: c.nextId.Add(1), Method: method, Params: params}
+}
+
+func (c *BitcoindClient) post(body interface{}, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// 调用出错时 bitcoind 返回 500 状态码，响应体仍然是 JSON-RPC 格式
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("bitcoind http status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+func (c *BitcoindClient) call(result interface{}, method string, params ...interface{}) error {
+	var resp rpcResponse
+	if err := c.post(c.newRequest(method, params...), &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// batch 一次请求发送多个调用，按请求顺序返回结果
+func (c *BitcoindClient) batch(requests []rpcRequest) ([]rpcResponse, error) {
+	var responses []rpcResponse
+	if err := c.post(requests, &responses); err != nil {
+		return nil, err
+	}
+	byId := make(map[uint64]rpcResponse, len(responses))
+	for _, resp := range responses {
+		byId[resp.Id] = resp
+	}
+	result := make([]rpcResponse, len(requests))
+	for i, req := range requests {
+		resp, ok := byId[req.Id]
+		if !ok {
+			return nil, fmt.Errorf("bitcoind batch response missing id %d", req.Id)
+		}
+		result[i] = resp
+	}
+	return result, nil
+}
+
+func (c *BitcoindClient) GetBlockHeader(number *big.Int) (*BlockHeader, error) {
+	var hash string
+	var err error
+	if number == nil {
+		err = c.call(&hash, "getbestblockhash")
+	} else {
+		err = c.call(&hash, "getblockhash", number.Int64())
+	}
+	if err != nil {
+		return nil, err
+	}
+	header, err := c.blockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockHeader{
+		Hash:      header.Hash,
+		PrevHash:  header.PreviousBlockHash,
+		Number:    big.NewInt(header.Height),
+		Timestamp: header.Time,
+		Source:    c.source,
+	}, nil
+}
+
+func (c *BitcoindClient) blockHeader(hash string) (*bitcoindHeader, error) {
+	var header bitcoindHeader
+	if err := c.call(&header, "getblockheader", hash, true); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+func (c *BitcoindClient) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	var block bitcoindBlock
+	if err := c.call(&block, "getblock", hash, 2); err != nil {
+		log.Error("get block by hash fail", "hash", hash, "err", err)
+		return nil, err
+	}
+	if err := c.resolvePrevouts(block.Tx); err != nil {
+		return nil, err
+	}
+	txList := make([]*utxo.TransactionList, 0, len(block.Tx))
+	for i := range block.Tx {
+		tx, err := toTransactionList(&block.Tx[i])
+		if err != nil {
+			return nil, fmt.Errorf("convert tx %s: %w", block.Tx[i].Txid, err)
+		}
+		txList = append(txList, tx)
+	}
+	log.Debug("get block by hash", "hash", hash, "txn", len(txList), "source", c.source)
+	return txList, nil
+}
+
+// resolvePrevouts 补全输入花费的上一笔输出，同一区块内的交易直接引用，其余批量查询
+func (c *BitcoindClient) resolvePrevouts(txs []bitcoindTx) error {
+	known := make(map[string]*bitcoindTx, len(txs))
+	for i := range txs {
+		known[txs[i].Txid] = &txs[i]
+	}
+	var missing []string
+	seen := make(map[string]bool)
+	for _, tx := range txs {
+		for _, vin := range tx.Vin {
+			if vin.Coinbase != "" || vin.Prevout != nil || known[vin.Txid] != nil || seen[vin.Txid] {
+				continue
+			}
+			seen[vin.Txid] = true
+			missing = append(missing, vin.Txid)
+		}
+	}
+	for start := 0; start < len(missing); start += prevoutBatchSize {
+		end := start + prevoutBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		requests := make([]rpcRequest, 0, end-start)
+		for _, txid := range missing[start:end] {
+			requests = append(requests, c.newRequest("getrawtransaction", txid, true))
+		}
+		responses, err := c.batch(requests)
+		if err != nil {
+			return err
+		}
+		for i, resp := range responses {
+			if resp.Error != nil {
+				return fmt.Errorf("get prev tx %s (txindex required): %w", missing[start+i], resp.Error)
+			}
+			var prevTx bitcoindTx
+			if err := json.Unmarshal(resp.Result, &prevTx); err != nil {
+				return err
+			}
+			known[prevTx.Txid] = &prevTx
+		}
+	}
+	for i := range txs {
+		for j := range txs[i].Vin {
+			vin := &txs[i].Vin[j]
+			if vin.Coinbase != "" || vin.Prevout != nil {
+				continue
+			}
+			prevTx := known[vin.Txid]
+			if prevTx == nil || int(vin.Vout) >= len(prevTx.Vout) {
+				return fmt.Errorf("prev output %s:%d not found", vin.Txid, vin.Vout)
+			}
+			vin.Prevout = &prevTx.Vout[vin.Vout]
+		}
+	}
+	return nil
+}
+
+func toTransactionList(tx *bitcoindTx) (*utxo.TransactionList, error) {
+	result := &utxo.TransactionList{Hash: tx.Txid}
+	var inputTotal, outputTotal int64
+	coinbase := false
+	for _, vin := range tx.Vin {
+		if vin.Coinbase != "" {
+			coinbase = true
+			result.Vin = append(result.Vin, &utxo.Vin{})
+			continue
+		}
+		amount, err := btcToSatoshi(vin.Prevout.Value)
+		if err != nil {
+			return nil, err
+		}
+		inputTotal += amount
+		result.Vin = append(result.Vin, &utxo.Vin{
+			Hash:    vin.Txid,
+			Index:   vin.Vout,
+			Amount:  amount,
+			Address: vin.Prevout.address(),
+		})
+	}
+	for _, vout := range tx.Vout {
+		amount, err := btcToSatoshi(vout.Value)
+		if err != nil {
+			return nil, err
+		}
+		outputTotal += amount
+		result.Vout = append(result.Vout, &utxo.Vout{
+			Address: vout.address(),
+			Amount:  amount,
+			Index:   vout.N,
+		})
+	}
+	var fee int64
+	switch {
+	case tx.Fee != "":
+		var err error
+		if fee, err = btcToSatoshi(tx.Fee); err != nil {
+			return nil, err
+		}
+	case !coinbase:
+		fee = inputTotal - outputTotal
+	}
+	result.Fee = strconv.FormatInt(fee, 10)
+	return result, nil
+}
+
+func (c *BitcoindClient) GetTransactionByHash(hash string) (*utxo.TxMessage, error) {
+	var tx bitcoindTx
+	if err := c.call(&tx, "getrawtransaction", hash, true); err != nil {
+		var rpcErr *RpcError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidAddressOrKey {
+			return nil, fmt.Errorf("%w: %s %s", ErrTxNotFound, hash, rpcErr.Message)
+		}
+		log.Error("get tx by hash fail", "hash", hash, "err", err)
+		return nil, err
+	}
+	txs := []bitcoindTx{tx}
+	if err := c.resolvePrevouts(txs); err != nil {
+		return nil, err
+	}
+	txList, err := toTransactionList(&txs[0])
+	if err != nil {
+		return nil, err
+	}
+	message := &utxo.TxMessage{
+		Hash:   tx.Txid,
+		Fee:    txList.Fee,
+		Status: utxo.TxStatus_Pending,
+		Height: "0",
+	}
+	for _, vin := range txList.Vin {
+		message.Froms = append(message.Froms, &utxo.Address{Address: vin.Address})
+	}
+	for _, vout := range txList.Vout {
+		message.Tos = append(message.Tos, &utxo.Address{Address: vout.Address})
+		message.Values = append(message.Values, &utxo.Value{Value: strconv.FormatInt(vout.Amount, 10)})
+	}
+	if tx.BlockHash != "" && tx.Confirmations > 0 {
+		header, err := c.blockHeader(tx.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		message.Status = utxo.TxStatus_Success
+		message.Height = strconv.FormatInt(header.Height, 10)
+	}
+	return message, nil
+}
+
+func (c *BitcoindClient) GetMempoolTxIds() ([]string, error) {
+	var txIds []string
+	if err := c.call(&txIds, "getrawmempool"); err != nil {
+		log.Error("get raw mempool fail", "err", err)
+		return nil, err
+	}
+	return txIds, nil
+}
+
+func (c *BitcoindClient) GetMempoolTransactions(hashes []string) ([]*utxo.TransactionList, error) {
+	var txList []*utxo.TransactionList
+	for start := 0; start < len(hashes); start += prevoutBatchSize {
+		end := start + prevoutBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		requests := make([]rpcRequest, 0, end-start)
+		for _, hash := range hashes[start:end] {
+			requests = append(requests, c.newRequest("getrawtransaction", hash, true))
+		}
+		responses, err := c.batch(requests)
+		if err != nil {
+			return nil, err
+		}
+		for i, resp := range responses {
+			if resp.Error != nil {
+				if resp.Error.Code == rpcInvalidAddressOrKey {
+					continue
+				}
+				return nil, fmt.Errorf("get mempool tx %s: %w", hashes[start+i], resp.Error)
+			}
+			var tx bitcoindTx
+			if err := json.Unmarshal(resp.Result, &tx); err != nil {
+				return nil, err
+			}
+			mempoolTx, err := toMempoolTransaction(&tx)
+			if err != nil {
+				return nil, fmt.Errorf("convert tx %s: %w", tx.Txid, err)
+			}
+			txList = append(txList, mempoolTx)
+		}
+	}
+	return txList, nil
+}
+
+// GetOutpointSpender gettxspendingprevout 只查询内存池，Bitcoin Core 24 之前的节点没有该接口，按没有找到处理
+func (c *BitcoindClient) GetOutpointSpender(txId string, vout uint32) (string, error) {
+	type prevout struct {
+		Txid         string `json:"txid"`
+		Vout         uint32 `json:"vout"`
+		SpendingTxid string `json:"spendingtxid"`
+	}
+	var spends []prevout
+	if err := c.call(&spends, "gettxspendingprevout", []prevout{{Txid: txId, Vout: vout}}); err != nil {
+		var rpcErr *RpcError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpcMethodNotFound {
+			log.Warn("bitcoind does not support gettxspendingprevout", "err", err)
+			return "", nil
+		}
+		log.Error("get tx spending prevout fail", "txId", txId, "vout", vout, "err", err)
+		return "", err
+	}
+	for _, spend := range spends {
+		if spend.SpendingTxid != "" {
+			return spend.SpendingTxid, nil
+		}
+	}
+	return "", nil
+}
+
+// toMempoolTransaction 与 toTransactionList 相同，但不需要上一笔输出，手续费记为 0
+func toMempoolTransaction(tx *bitcoindTx) (*utxo.TransactionList, error) {
+	result := &utxo.TransactionList{Hash: tx.Txid, Fee: "0"}
+	for _, vin := range tx.Vin {
+		result.Vin = append(result.Vin, &utxo.Vin{Hash: vin.Txid, Index: vin.Vout})
+	}
+	for _, vout := range tx.Vout {
+		amount, err := btcToSatoshi(vout.Value)
+		if err != nil {
+			return nil, err
+		}
+		result.Vout = append(result.Vout, &utxo.Vout{
+			Address: vout.address(),
+			Amount:  amount,
+			Index:   vout.N,
+		})
+	}
+	return result, nil
+}
+
+// SendTx 广播签名后的交易，错误分类与 WalletBtcAccountClient.SendTx 相同
+func (c *BitcoindClient) SendTx(rawTx string) (string, error) {
+	var txHash string
+	err := c.call(&txHash, "sendrawtransaction", rawTx)
+	if err == nil {
+		return txHash, nil
+	}
+	var rpcErr *RpcError
+	if !errors.As(err, &rpcErr) {
+		log.Error("send tx fail", "err", err)
+		return "", fmt.Errorf("%w: %v", ErrTxRetryable, err)
+	}
+	sendErr := classifyRpcSendTxError(rpcErr)
+	if errors.Is(sendErr, ErrTxAlreadyInChain) {
+		return alreadyInChainTxId(rawTx, sendErr)
+	}
+	return "", sendErr
+}
+
+// classifyRpcSendTxError 优先按错误码区分，同一错误码下再按错误信息细分：
+// -27 交易已经在链上；-26 被节点策略或共识规则拒绝，其中内存池冲突、最低费率等随后可能变化的仍然重试；
+// -25 输入缺失等验证错误，输入可能还没有到达节点，默认重试；-22 交易无法解析
+func classifyRpcSendTxError(rpcErr *RpcError) error {
+	switch rpcErr.Code {
+	case rpcVerifyAlreadyInChain:
+		return fmt.Errorf("%w: %s", ErrTxAlreadyInChain, rpcErr.Message)
+	case rpcVerifyRejected:
+		return classifySendTxErrorDefault(rpcErr.Message, ErrTxRejected)
+	case rpcVerifyError:
+		return classifySendTxErrorDefault(rpcErr.Message, ErrTxRetryable)
+	case rpcDeserializationError:
+		return fmt.Errorf("%w: %s", ErrTxRejected, rpcErr.Message)
+	}
+	return classifySendTxError(rpcErr.Message)
+}
+
+// GetFeeRate estimatesmartfee 返回的费率单位为 BTC/kvB
+func (c *BitcoindClient) GetFeeRate() (int64, error) {
+	var estimate struct {
+		FeeRate json.Number `json:"feerate"`
+		Errors  []string    `json:"errors"`
+	}
+	if err := c.call(&estimate, "estimatesmartfee", feeConfTarget); err != nil {
+		log.Error("get fee fail", "err", err)
+		return 0, err
+	}
+	if estimate.FeeRate == "" {
+		return 0, fmt.Errorf("estimate smart fee fail: %s", strings.Join(estimate.Errors, "; "))
+	}
+	feeRate, err := estimate.FeeRate.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return txfee.SatPerVByte(float32(feeRate)), nil
+}
+
+// btcToSatoshi 把 bitcoind 返回的 BTC 金额精确换算为聪
+func btcToSatoshi(value json.Number) (int64, error) {
+	amount, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return 0, fmt.Errorf("invalid btc amount %q", value)
+	}
+	amount.Mul(amount, big.NewRat(1e8, 1))
+	if !amount.IsInt() {
+		return 0, fmt.Errorf("btc amount %q has more than 8 decimals", value)
+	}
+	return amount.Num().Int64(), nil
+}
@@ -0,0 +1,290 @@
+package syncclient
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+type stubMethod func(params []json.RawMessage) (interface{}, *RpcError)
+
+// newBitcoindStub 模拟 bitcoind 的 JSON-RPC 接口，支持批量请求，出错时与 bitcoind 一样返回 500
+func newBitcoindStub(t *testing.T, methods map[string]stubMethod) *BitcoindClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		type request struct {
+			Id     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		handle := func(req request) map[string]interface{} {
+			method, ok := methods[req.Method]
+			if !ok {
+				return map[string]interface{}{"id": req.Id, "result": nil, "error": &RpcError{Code: -32601, Message: "Method not found"}}
+			}
+			result, rpcErr := method(req.Params)
+			if rpcErr != nil {
+				return map[string]interface{}{"id": req.Id, "result": nil, "error": rpcErr}
+			}
+			return map[string]interface{}{"id": req.Id, "result": result, "error": nil}
+		}
+		if body[0] == '[' {
+			var requests []request
+			require.NoError(t, json.Unmarshal(body, &requests))
+			var responses []map[string]interface{}
+			for _, req := range requests {
+				responses = append(responses, handle(req))
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(responses))
+			return
+		}
+		var req request
+		require.NoError(t, json.Unmarshal(body, &req))
+		resp := handle(req)
+		if resp["error"] != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewBitcoindClient(server.URL, "user", "pass")
+	require.NoError(t, err)
+	return client
+}
+
+func rawJson(t *testing.T, data string) json.RawMessage {
+	require.True(t, json.Valid([]byte(data)), data)
+	return json.RawMessage(data)
+}
+
+func TestBitcoindBlock(t *testing.T) {
+	prevTx := rawJson(t, `{"txid":"p1","vin":[{"txid":"p0","vout":0}],"vout":[
+		{"value":0.5,"n":0,"scriptPubKey":{"address":"addrA"}},
+		{"value":1.23456789,"n":1,"scriptPubKey":{"addresses":["addrB"]}}]}`)
+	block := rawJson(t, `{"hash":"h100","tx":[
+		{"txid":"cb","vin":[{"coinbase":"03abcd"}],"vout":[{"value":3.125,"n":0,"scriptPubKey":{"address":"miner"}}]},
+		{"txid":"t1","vin":[{"txid":"p1","vout":1}],"vout":[
+			{"value":1.2,"n":0,"scriptPubKey":{"address":"addrC"}},
+			{"value":0.03456,"n":1,"scriptPubKey":{"address":"addrD"}}]},
+		{"txid":"t2","vin":[{"txid":"t1","vout":0}],"vout":[{"value":1.19990000,"n":0,"scriptPubKey":{"address":"addrE"}}],"fee":0.0001}]}`)
+	client := newBitcoindStub(t, map[string]stubMethod{
+		"getblockhash": func(params []json.RawMessage) (interface{}, *RpcError) {
+			require.Equal(t, "100", string(params[0]))
+			return "h100", nil
+		},
+		"getbestblockhash": func(params []json.RawMessage) (interface{}, *RpcError) {
+			return "h100", nil
+		},
+		"getblockheader": func(params []json.RawMessage) (interface{}, *RpcError) {
+			return rawJson(t, `{"hash":"h100","height":100,"time":1700000000,"previousblockhash":"h99"}`), nil
+		},
+		"getblock": func(params []json.RawMessage) (interface{}, *RpcError) {
+			require.Equal(t, `"h100"`, string(params[0]))
+			require.Equal(t, "2", string(params[1]))
+			return block, nil
+		},
+		"getrawtransaction": func(params []json.RawMessage) (interface{}, *RpcError) {
+			require.Equal(t, `"p1"`, string(params[0]))
+			return prevTx, nil
+		},
+	})
+
+	header, err := client.GetBlockHeader(nil)
+	require.NoError(t, err)
+	require.Equal(t, "h100", header.Hash)
+	require.Equal(t, "h99", header.PrevHash)
+	require.Equal(t, int64(100), header.Number.Int64())
+	require.NotEmpty(t, header.Source)
+
+	txList, err := client.GetBlockByHash("h100")
+	require.NoError(t, err)
+	require.Len(t, txList, 3)
+
+	require.Equal(t, "0", txList[0].Fee)
+	require.Equal(t, "", txList[0].Vin[0].Address)
+	require.Equal(t, int64(312500000), txList[0].Vout[0].Amount)
+
+	// 区块外的输入通过 getrawtransaction 补全，手续费为输入减输出
+	require.Equal(t, &utxo.Vin{Hash: "p1", Index: 1, Amount: 123456789, Address: "addrB"}, txList[1].Vin[0])
+	require.Equal(t, "addrD", txList[1].Vout[1].Address)
+	require.Equal(t, int64(3456000), txList[1].Vout[1].Amount)
+	require.Equal(t, "789", txList[1].Fee)
+
+	// 同一区块内的输入直接引用，节点返回的手续费优先
+	require.Equal(t, "addrC", txList[2].Vin[0].Address)
+	require.Equal(t, int64(120000000), txList[2].Vin[0].Amount)
+	require.Equal(t, "10000", txList[2].Fee)
+}
+
+func TestBitcoindTransaction(t *testing.T) {
+	client := newBitcoindStub(t, map[string]stubMethod{
+		"getrawtransaction": func(params []json.RawMessage) (interface{}, *RpcError) {
+			switch string(params[0]) {
+			case `"t1"`:
+				return rawJson(t, `{"txid":"t1","vin":[{"txid":"p1","vout":0}],"vout":[{"value":0.4,"n":0,"scriptPubKey":{"address":"addrC"}}],
+					"blockhash":"h100","confirmations":3}`), nil
+			case `"p1"`:
+				return rawJson(t, `{"txid":"p1","vin":[],"vout":[{"value":0.5,"n":0,"scriptPubKey":{"address":"addrA"}}]}`), nil
+			}
+			return nil, &RpcError{Code: -5, Message: "No such mempool or blockchain transaction"}
+		},
+		"getblockheader": func(params []json.RawMessage) (interface{}, *RpcError) {
+			return rawJson(t, `{"hash":"h100","height":100,"time":1700000000,"previousblockhash":"h99"}`), nil
+		},
+	})
+
+	tx, err := client.GetTransactionByHash("t1")
+	require.NoError(t, err)
+	require.Equal(t, utxo.TxStatus_Success, tx.Status)
+	require.Equal(t, "100", tx.Height)
+	require.Equal(t, "addrA", tx.Froms[0].Address)
+	require.Equal(t, "addrC", tx.Tos[0].Address)
+	require.Equal(t, "40000000", tx.Values[0].Value)
+	require.Equal(t, "10000000", tx.Fee)
+
+	_, err = client.GetTransactionByHash("missing")
+	require.ErrorIs(t, err, ErrTxNotFound)
+}
+
+func TestBitcoindSendTxAndFee(t *testing.T) {
+	client := newBitcoindStub(t, map[string]stubMethod{
+		"sendrawtransaction": func(params []json.RawMessage) (interface{}, *RpcError) {
+			switch string(params[0]) {
+			case `"` + genesisRawTx + `"`:
+				return nil, &RpcError{Code: -27, Message: "Transaction already in block chain"}
+			case `"00"`:
+				return nil, &RpcError{Code: -26, Message: "bad-txns-vout-negative"}
+			case `"02"`:
+				return nil, &RpcError{Code: -27, Message: "Transaction outputs already in utxo set"}
+			case `"03"`:
+				return nil, &RpcError{Code: -26, Message: "txn-mempool-conflict"}
+			case `"04"`:
+				return nil, &RpcError{Code: -26, Message: "some new policy reason"}
+			case `"05"`:
+				return nil, &RpcError{Code: -25, Message: "bad-txns-inputs-missingorspent"}
+			case `"06"`:
+				return nil, &RpcError{Code: -22, Message: "TX decode failed"}
+			}
+			return "txid", nil
+		},
+		"estimatesmartfee": func(params []json.RawMessage) (interface{}, *RpcError) {
+			return rawJson(t, `{"feerate":0.00012345,"blocks":6}`), nil
+		},
+	})
+
+	txHash, err := client.SendTx("01")
+	require.NoError(t, err)
+	require.Equal(t, "txid", txHash)
+
+	txHash, err = client.SendTx(genesisRawTx)
+	require.ErrorIs(t, err, ErrTxAlreadyInChain)
+	require.Equal(t, genesisTxId, txHash)
+
+	_, err = client.SendTx("00")
+	require.ErrorIs(t, err, ErrTxRejected)
+
+	// 按错误码区分，错误码内再按错误信息细分
+	require.ErrorIs(t, classifyRpcSendTxError(&RpcError{Code: -27, Message: "Transaction outputs already in utxo set"}), ErrTxAlreadyInChain)
+	// 已经在链上但无法计算 txid 时不能返回空 hash，下一轮重新广播
+	txHash, err = client.SendTx("02")
+	require.ErrorIs(t, err, ErrTxRetryable)
+	require.NotErrorIs(t, err, ErrTxAlreadyInChain)
+	require.Empty(t, txHash)
+	_, err = client.SendTx("03")
+	require.ErrorIs(t, err, ErrTxRetryable)
+	_, err = client.SendTx("04")
+	require.ErrorIs(t, err, ErrTxRejected)
+	_, err = client.SendTx("05")
+	require.ErrorIs(t, err, ErrTxRetryable)
+	_, err = client.SendTx("06")
+	require.ErrorIs(t, err, ErrTxRejected)
+
+	feeRate, err := client.GetFeeRate()
+	require.NoError(t, err)
+	require.Equal(t, int64(13), feeRate)
+}
+
+func TestBitcoindUnauthorized(t *testing.T) {
+	client := newBitcoindStub(t, nil)
+	client.password = "wrong"
+	_, err := client.GetBlockHeader(nil)
+	require.Error(t, err)
+}
+
+func TestBtcToSatoshi(t *testing.T) {
+	amount, err := btcToSatoshi("20999999.97690000")
+	require.NoError(t, err)
+	require.Equal(t, int64(2099999997690000), amount)
+
+	amount, err = btcToSatoshi("1e-05")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), amount)
+
+	_, err = btcToSatoshi("0.000000001")
+	require.Error(t, err)
+}
+
+func TestBitcoindMempool(t *testing.T) {
+	client := newBitcoindStub(t, map[string]stubMethod{
+		"getrawmempool": func(params []json.RawMessage) (interface{}, *RpcError) {
+			return []string{"m1", "gone"}, nil
+		},
+		"getrawtransaction": func(params []json.RawMessage) (interface{}, *RpcError) {
+			if string(params[0]) == `"gone"` {
+				return nil, &RpcError{Code: -5, Message: "No such mempool or blockchain transaction"}
+			}
+			return rawJson(t, `{"txid":"m1","vin":[{"txid":"p1","vout":3}],"vout":[
+				{"value":0.001,"n":0,"scriptPubKey":{"address":"addrA"}}]}`), nil
+		},
+	})
+
+	txIds, err := client.GetMempoolTxIds()
+	require.NoError(t, err)
+	require.Equal(t, []string{"m1", "gone"}, txIds)
+
+	txList, err := client.GetMempoolTransactions(txIds)
+	require.NoError(t, err)
+	require.Len(t, txList, 1)
+	require.Equal(t, &utxo.Vin{Hash: "p1", Index: 3}, txList[0].Vin[0])
+	require.Equal(t, &utxo.Vout{Address: "addrA", Amount: 100000, Index: 0}, txList[0].Vout[0])
+
+	// 旧版本节点没有 gettxspendingprevout，按没有找到处理
+	spender, err := client.GetOutpointSpender("p1", 3)
+	require.NoError(t, err)
+	require.Empty(t, spender)
+}
+
+func TestBitcoindOutpointSpender(t *testing.T) {
+	client := newBitcoindStub(t, map[string]stubMethod{
+		"gettxspendingprevout": func(params []json.RawMessage) (interface{}, *RpcError) {
+			var outpoints []struct {
+				Txid string `json:"txid"`
+				Vout uint32 `json:"vout"`
+			}
+			require.NoError(t, json.Unmarshal(params[0], &outpoints))
+			if outpoints[0].Vout == 3 {
+				return rawJson(t, `[{"txid":"p1","vout":3,"spendingtxid":"m1"}]`), nil
+			}
+			return rawJson(t, `[{"txid":"p1","vout":0}]`), nil
+		},
+	})
+
+	spender, err := client.GetOutpointSpender("p1", 3)
+	require.NoError(t, err)
+	require.Equal(t, "m1", spender)
+	spender, err = client.GetOutpointSpender("p1", 0)
+	require.NoError(t, err)
+	require.Empty(t, spender)
+}
@@ -0,0 +1,73 @@
+package syncclient
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+const (
+	ChainSourceWallet   = "wallet"
+	ChainSourceBitcoind = "bitcoind"
+	ChainSourceEsplora  = "esplora"
+	// MempoolSourceNone 关闭内存池充值检测
+	MempoolSourceNone = "none"
+)
+
+// ChainSource 扫块、查询交易、广播交易和查询费率依赖的链上数据源，
+// 由 WalletBtcAccountClient 通过上游 WalletUtxoService 实现，或者由 BitcoindClient 直连 Bitcoin Core、
+// EsploraClient 通过 Esplora/Electrs REST 接口实现
+type ChainSource interface {
+	// GetBlockHeader number 为 nil 时查询最新区块
+	GetBlockHeader(number *big.Int) (*BlockHeader, error)
+	// GetBlockByHash 按区块头的 hash 获取区块内容，保证区块内容与校验过的区块头是同一个区块
+	GetBlockByHash(hash string) ([]*utxo.TransactionList, error)
+	// GetTransactionByHash 交易不存在时返回 ErrTxNotFound
+	GetTransactionByHash(hash string) (*utxo.TxMessage, error)
+	// SendTx 返回的错误可以用 errors.Is 区分 ErrTxAlreadyInChain、ErrTxRejected 和 ErrTxRetryable
+	SendTx(rawTx string) (string, error)
+	// GetFeeRate 当前网络费率，单位 聪/虚拟字节；需要按网络兜底的调用方使用 EstimateFeeRate
+	GetFeeRate() (int64, error)
+}
+
+// EstimateFeeRate 通过数据源查询当前网络费率，单位 聪/虚拟字节；查询失败时使用网络的 FallbackFeeRate，
+// 不低于网络的 MinFeeRate
+func EstimateFeeRate(source ChainSource, params *chaincfg.Params) (int64, error) {
+	feeRate, err := source.GetFeeRate()
+	if err != nil {
+		// 测试网络经常估算不出费率，使用网络的默认费率
+		if params.FallbackFeeRate > 0 {
+			log.Warn("get fee fail, use fallback fee rate", "network", params.Network, "feeRate", params.FallbackFeeRate, "err", err)
+			return params.FallbackFeeRate, nil
+		}
+		log.Error("get fee fail", "err", err)
+		return 0, err
+	}
+	// 狗狗币等链的最低转发费率远高于比特币，低于节点最低费率的交易不会被转发
+	return max(feeRate, params.MinFeeRate), nil
+}
+
+// MempoolSource 能列出内存池交易的数据源，内存池充值检测每轮只扫描一次内存池，
+// 上游 WalletUtxoService 没有内存池接口，只有 BitcoindClient 和 EsploraClient 实现；
+// 扫块数据源为 WalletBtcAccountClient 时需要单独配置 bitcoind 或 esplora 作为内存池数据源
+type MempoolSource interface {
+	GetMempoolTxIds() ([]string, error)
+	// GetMempoolTransactions 输入只包含花费的输出（Hash、Index），不补全地址和金额；已经离开内存池的交易跳过
+	GetMempoolTransactions(hashes []string) ([]*utxo.TransactionList, error)
+	// GetOutpointSpender 返回内存池中花费 txId:vout 的交易，没有时返回空；EsploraClient 同时返回已上链的花费交易
+	GetOutpointSpender(txId string, vout uint32) (string, error)
+}
+
+var (
+	_ MempoolSource = (*BitcoindClient)(nil)
+	_ MempoolSource = (*EsploraClient)(nil)
+)
+
+var (
+	_ ChainSource = (*WalletBtcAccountClient)(nil)
+	_ ChainSource = (*BitcoindClient)(nil)
+	_ ChainSource = (*EsploraClient)(nil)
+)
@@ -2,27 +2,69 @@ package syncclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/common"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
 )
 
 type WalletBtcAccountClient struct {
 	Ctx          context.Context
 	ChainName    string
+	Params       *chaincfg.Params
 	BtcRpcClient utxo.WalletUtxoServiceClient
+	upstream     *FailoverConn
 }
 
-func NewWalletBtcAccountClient(ctx context.Context, rpc utxo.WalletUtxoServiceClient, chainName string) (*WalletBtcAccountClient, error) {
-	log.Info("New account chain rpc client", "chainName", chainName)
-	return &WalletBtcAccountClient{Ctx: ctx, BtcRpcClient: rpc, ChainName: chainName}, nil
+func NewWalletBtcAccountClient(ctx context.Context, rpc utxo.WalletUtxoServiceClient, chainName string, params *chaincfg.Params) (*WalletBtcAccountClient, error) {
+	log.Info("New account chain rpc client", "chainName", chainName, "network", params.Network)
+	return &WalletBtcAccountClient{Ctx: ctx, BtcRpcClient: rpc, ChainName: chainName, Params: params}, nil
+}
+
+// DialWalletBtcAccountClient 连接多个上游节点，请求失败时自动切换到其他节点
+func DialWalletBtcAccountClient(ctx context.Context, addrs []string, cfg FailoverConfig, chainName string, params *chaincfg.Params) (*WalletBtcAccountClient, error) {
+	cfg.Network = params.Network
+	conn, err := DialFailover(addrs, cfg, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	conn.StartHealthCheck()
+	client, err := NewWalletBtcAccountClient(ctx, utxo.NewWalletUtxoServiceClient(conn), chainName, params)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	client.upstream = conn
+	return client, nil
+}
+
+// Endpoints 上游节点的状态，没有使用多节点连接时返回 nil
+func (wac *WalletBtcAccountClient) Endpoints() []EndpointStatus {
+	if wac.upstream == nil {
+		return nil
+	}
+	return wac.upstream.Status()
+}
+
+func (wac *WalletBtcAccountClient) Close() error {
+	if wac.upstream == nil {
+		return nil
+	}
+	return wac.upstream.Close()
 }
 
 func (wac *WalletBtcAccountClient) ExportAddressByPubKey(format, publicKey string) string {
 	req := &utxo.ConvertAddressRequest{
+		Chain:     wac.ChainName,
+		Network:   wac.Params.Network,
 		Format:    format,
 		PublicKey: publicKey,
 	}
@@ -34,47 +76,181 @@ func (wac *WalletBtcAccountClient) ExportAddressByPubKey(format, publicKey strin
 	return address.Address
 }
 
+// GetBlockHeader 开启多节点校验时只接受多数节点一致的区块头
 func (wac *WalletBtcAccountClient) GetBlockHeader(number *big.Int) (*BlockHeader, error) {
+	if wac.upstream != nil && wac.upstream.cfg.Quorum > 1 {
+		return wac.upstream.QuorumBlockHeader(number, wac.upstream.cfg.Quorum)
+	}
 	request := &utxo.BlockHeaderNumberRequest{
-		Network: "mainnet",
-		Height:  number.Int64(),
+		Network: wac.Params.Network,
+	}
+	// number 为 nil 时查询最新区块
+	if number != nil {
+		request.Height = number.Int64()
 	}
-	blockHeader, err := wac.BtcRpcClient.GetBlockHeaderByNumber(context.Background(), request)
+	var source string
+	blockHeader, err := wac.BtcRpcClient.GetBlockHeaderByNumber(context.Background(), request, ServedBy(&source))
 	if err != nil {
 		return nil, err
 	}
+	if blockHeader.Code == common.ReturnCode_ERROR {
+		return nil, fmt.Errorf("get block header fail: %s", blockHeader.Msg)
+	}
 	blockNumber, _ := new(big.Int).SetString(blockHeader.Number, 10)
 
 	return &BlockHeader{
 		Hash:     blockHeader.BlockHash,
 		PrevHash: blockHeader.ParentHash,
 		Number:   blockNumber,
+		Source:   source,
 	}, nil
 }
 
-func (wac *WalletBtcAccountClient) GetBlockByNumber(blockNumber *big.Int) ([]*utxo.TransactionList, error) {
-	blockReq := &utxo.BlockNumberRequest{
-		Height: blockNumber.Int64(),
+// GetBlockByHash 开启多节点校验时从投票给该区块头的节点获取区块内容
+func (wac *WalletBtcAccountClient) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	blockReq := &utxo.BlockHashRequest{
+		Chain: wac.ChainName,
+		Hash:  hash,
+	}
+	if wac.upstream != nil && wac.upstream.cfg.Quorum > 1 {
+		blockInfo, err := wac.upstream.QuorumBlockByHash(blockReq)
+		if err != nil {
+			log.Error("get block by hash fail", "hash", hash, "err", err)
+			return nil, err
+		}
+		return blockInfo.TxList, nil
 	}
-	blockInfo, err := wac.BtcRpcClient.GetBlockByNumber(context.Background(), blockReq)
+	var source string
+	blockInfo, err := wac.BtcRpcClient.GetBlockByHash(context.Background(), blockReq, ServedBy(&source))
 	if err != nil {
-		log.Error("get block by number fail", "err", err)
+		log.Error("get block by hash fail", "hash", hash, "err", err)
 		return nil, err
 	}
 	if blockInfo.Code == common.ReturnCode_ERROR {
-		log.Error("Return code is error", "err", err)
+		return nil, fmt.Errorf("get block by hash %s fail: %s", hash, blockInfo.Msg)
 	}
+	log.Debug("get block by hash", "hash", hash, "txn", len(blockInfo.TxList), "source", source)
 	return blockInfo.TxList, nil
 }
 
 func (wac *WalletBtcAccountClient) GetTransactionByHash(hash string) (*utxo.TxMessage, error) {
-	return nil, nil
+	request := &utxo.TxHashRequest{
+		Chain:   wac.ChainName,
+		Network: wac.Params.Network,
+		Hash:    hash,
+	}
+	txResp, err := wac.BtcRpcClient.GetTxByHash(wac.Ctx, request)
+	if err != nil {
+		log.Error("get tx by hash fail", "hash", hash, "err", err)
+		return nil, err
+	}
+	if txResp.Code == common.ReturnCode_ERROR || txResp.Tx == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrTxNotFound, hash, txResp.Msg)
+	}
+	return txResp.Tx, nil
+}
+
+// GetUnspentOutputs 查询地址下未花费的输出，包括内存池中还没有确认的输出
+func (wac *WalletBtcAccountClient) GetUnspentOutputs(address string) ([]*utxo.UnspentOutput, error) {
+	request := &utxo.UnspentOutputsRequest{
+		Chain:   wac.ChainName,
+		Network: wac.Params.Network,
+		Address: address,
+	}
+	unspentResp, err := wac.BtcRpcClient.GetUnspentOutputs(wac.Ctx, request)
+	if err != nil {
+		log.Error("get unspent outputs fail", "address", address, "err", err)
+		return nil, err
+	}
+	if unspentResp.Code == common.ReturnCode_ERROR {
+		return nil, fmt.Errorf("get unspent outputs fail: %s", unspentResp.Msg)
+	}
+	return unspentResp.UnspentOutputs, nil
+}
+
+// GetTxByAddress 查询地址相关的交易，包括内存池中还没有确认的交易
+func (wac *WalletBtcAccountClient) GetTxByAddress(address string) ([]*utxo.TxMessage, error) {
+	request := &utxo.TxAddressRequest{
+		Chain:    wac.ChainName,
+		Network:  wac.Params.Network,
+		Address:  address,
+		Page:     1,
+		Pagesize: 50,
+	}
+	txResp, err := wac.BtcRpcClient.GetTxByAddress(wac.Ctx, request)
+	if err != nil {
+		log.Error("get tx by address fail", "address", address, "err", err)
+		return nil, err
+	}
+	if txResp.Code == common.ReturnCode_ERROR {
+		return nil, fmt.Errorf("get tx by address fail: %s", txResp.Msg)
+	}
+	return txResp.Tx, nil
 }
 
 func (wac *WalletBtcAccountClient) GetAccount(address string) (int, error) {
 	return 0, nil
 }
 
+// SendTx 广播签名后的交易并返回 txid；
+// 返回的错误可以用 errors.Is 区分 ErrTxAlreadyInChain、ErrTxRejected 和 ErrTxRetryable，
+// 交易已经在链上时同时返回根据原始交易计算出的 txid
 func (wac *WalletBtcAccountClient) SendTx(rawTx string) (string, error) {
-	return "", nil
+	request := &utxo.SendTxRequest{
+		Chain:   wac.ChainName,
+		Network: wac.Params.Network,
+		RawTx:   rawTx,
+	}
+	txResp, err := wac.BtcRpcClient.SendTx(wac.Ctx, request)
+	if err != nil {
+		log.Error("send tx fail", "err", err)
+		return "", fmt.Errorf("%w: %v", ErrTxRetryable, err)
+	}
+	if txResp.Code == common.ReturnCode_ERROR {
+		sendErr := classifySendTxError(txResp.Msg)
+		if errors.Is(sendErr, ErrTxAlreadyInChain) {
+			return alreadyInChainTxId(rawTx, sendErr)
+		}
+		return "", sendErr
+	}
+	if txResp.TxHash == "" {
+		return TxIdFromRawTx(rawTx)
+	}
+	return txResp.TxHash, nil
+}
+
+// GetFeeRate 查询当前网络费率，单位 聪/虚拟字节；最低费率和回退费率由 EstimateFeeRate 处理
+func (wac *WalletBtcAccountClient) GetFeeRate() (int64, error) {
+	request := &utxo.FeeRequest{
+		Chain:   wac.ChainName,
+		Network: wac.Params.Network,
+	}
+	feeResp, err := wac.BtcRpcClient.GetFee(wac.Ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	if feeResp.Code == common.ReturnCode_ERROR {
+		return 0, fmt.Errorf("get fee fail: %s", feeResp.Msg)
+	}
+	return txfee.SatPerVByte(feeResp.FeeRate), nil
+}
+
+// CreateUnSignTransaction 根据输入、输出构建待签名交易，返回待签名的 hash 和交易数据
+func (wac *WalletBtcAccountClient) CreateUnSignTransaction(vins []*utxo.Vin, vouts []*utxo.Vout, fee int64) (*utxo.UnSignTransactionResponse, error) {
+	request := &utxo.UnSignTransactionRequest{
+		Chain:   wac.ChainName,
+		Network: wac.Params.Network,
+		Fee:     big.NewInt(fee).String(),
+		Vin:     vins,
+		Vout:    vouts,
+	}
+	unSignResp, err := wac.BtcRpcClient.CreateUnSignTransaction(wac.Ctx, request)
+	if err != nil {
+		log.Error("create unsign transaction fail", "err", err)
+		return nil, err
+	}
+	if unSignResp.Code == common.ReturnCode_ERROR {
+		return nil, fmt.Errorf("create unsign transaction fail: %s", unSignResp.Msg)
+	}
+	return unSignResp, nil
 }
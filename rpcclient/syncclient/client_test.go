@@ -0,0 +1,117 @@
+package syncclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/common"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// 比特币创世区块的 coinbase 交易
+const (
+	genesisRawTx = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+	genesisTxId  = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+)
+
+type mockSendTxClient struct {
+	utxo.WalletUtxoServiceClient
+	resp *utxo.SendTxResponse
+	err  error
+}
+
+func (m *mockSendTxClient) SendTx(_ context.Context, _ *utxo.SendTxRequest, _ ...grpc.CallOption) (*utxo.SendTxResponse, error) {
+	return m.resp, m.err
+}
+
+func TestTxIdFromRawTx(t *testing.T) {
+	txId, err := TxIdFromRawTx(genesisRawTx)
+	require.NoError(t, err)
+	require.Equal(t, genesisTxId, txId)
+
+	// 加上 marker、flag 和一项见证数据后 txid 不变
+	segwitRawTx := genesisRawTx[:8] + "0001" + genesisRawTx[8:len(genesisRawTx)-8] + "0102abcd" + genesisRawTx[len(genesisRawTx)-8:]
+	txId, err = TxIdFromRawTx(segwitRawTx)
+	require.NoError(t, err)
+	require.Equal(t, genesisTxId, txId)
+
+	_, err = TxIdFromRawTx("0100")
+	require.Error(t, err)
+}
+
+func TestSendTx(t *testing.T) {
+	cases := []struct {
+		name   string
+		mock   *mockSendTxClient
+		txHash string
+		err    error
+	}{
+		{"success", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_SUCCESS, TxHash: genesisTxId}}, genesisTxId, nil},
+		{"already in chain", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "Transaction already in block chain"}}, genesisTxId, ErrTxAlreadyInChain},
+		{"already in utxo set", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "Transaction outputs already in utxo set"}}, genesisTxId, ErrTxAlreadyInChain},
+		{"rejected", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "bad-txns-vout-negative"}}, "", ErrTxRejected},
+		{"inputs missing", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "bad-txns-inputs-missingorspent"}}, "", ErrTxRetryable},
+		{"mempool conflict", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "txn-mempool-conflict"}}, "", ErrTxRetryable},
+		{"mempool full", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "mempool min fee not met, 100 < 200"}}, "", ErrTxRetryable},
+		{"upstream error", &mockSendTxClient{resp: &utxo.SendTxResponse{Code: common.ReturnCode_ERROR, Msg: "connection refused"}}, "", ErrTxRetryable},
+		{"transport error", &mockSendTxClient{err: errors.New("unavailable")}, "", ErrTxRetryable},
+	}
+	for _, c := range cases {
+		client, err := NewWalletBtcAccountClient(context.Background(), c.mock, "Bitcoin", &chaincfg.MainNetParams)
+		require.NoError(t, err)
+		txHash, err := client.SendTx(genesisRawTx)
+		if c.err == nil {
+			require.NoError(t, err, c.name)
+		} else {
+			require.ErrorIs(t, err, c.err, c.name)
+		}
+		require.Equal(t, c.txHash, txHash, c.name)
+	}
+}
+
+type mockFeeClient struct {
+	utxo.WalletUtxoServiceClient
+	network string
+	resp    *utxo.FeeResponse
+}
+
+func (m *mockFeeClient) GetFee(_ context.Context, in *utxo.FeeRequest, _ ...grpc.CallOption) (*utxo.FeeResponse, error) {
+	m.network = in.Network
+	return m.resp, nil
+}
+
+func TestGetFeeRate(t *testing.T) {
+	mock := &mockFeeClient{resp: &utxo.FeeResponse{Code: common.ReturnCode_SUCCESS, FeeRate: 0.0001}}
+	client, err := NewWalletBtcAccountClient(context.Background(), mock, "Bitcoin", &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	feeRate, err := EstimateFeeRate(client, client.Params)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), feeRate)
+	require.Equal(t, chaincfg.SigNet, mock.network)
+
+	// 测试网络估算不出费率时使用默认费率，主网直接返回错误
+	mock.resp = &utxo.FeeResponse{Code: common.ReturnCode_ERROR, Msg: "Insufficient data or no feerate found"}
+	_, err = client.GetFeeRate()
+	require.Error(t, err)
+	feeRate, err = EstimateFeeRate(client, client.Params)
+	require.NoError(t, err)
+	require.Equal(t, chaincfg.SigNetParams.FallbackFeeRate, feeRate)
+
+	_, err = EstimateFeeRate(client, &chaincfg.MainNetParams)
+	require.Error(t, err)
+
+	// 数据源估算的费率低于链的最低转发费率时使用最低费率
+	mock.resp = &utxo.FeeResponse{Code: common.ReturnCode_SUCCESS, FeeRate: 0.0001}
+	client.Params = &chaincfg.DogecoinMainNetParams
+	feeRate, err = client.GetFeeRate()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), feeRate)
+	feeRate, err = EstimateFeeRate(client, client.Params)
+	require.NoError(t, err)
+	require.Equal(t, chaincfg.DogecoinMainNetParams.MinFeeRate, feeRate)
+}
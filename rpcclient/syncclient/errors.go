@@ -0,0 +1,83 @@
+package syncclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrTxAlreadyInChain 交易已经在链上或者内存池中，无需再次广播
+	ErrTxAlreadyInChain = errors.New("transaction already in chain")
+	// ErrTxRejected 交易被节点永久拒绝，重新广播同一笔交易不会成功
+	ErrTxRejected = errors.New("transaction rejected")
+	// ErrTxRetryable 网络或节点临时错误，可以稍后重新广播
+	ErrTxRetryable = errors.New("transaction broadcast retryable error")
+	ErrTxNotFound  = errors.New("transaction not found")
+)
+
+// bitcoind 拒绝交易时返回的原因
+var (
+	alreadyInChainReasons = []string{
+		"already in chain",
+		"already in block chain",
+		"txn-already-known",
+		"txn-already-in-mempool",
+		"transaction already exists",
+		"transaction outputs already in utxo set",
+	}
+	// 输入还没有到达节点或已被自己的其他交易花费、内存池冲突、内存池满时的最低费率都可能随后变化，稍后重新广播
+	retryableReasons = []string{
+		"missing-inputs",
+		"missingorspent",
+		"txn-mempool-conflict",
+		"mempool min fee not met",
+	}
+	rejectedReasons = []string{
+		"bad-txns",
+		"mandatory-script-verify-flag",
+		"non-mandatory-script-verify-flag",
+		"insufficient fee",
+		"min relay fee not met",
+		"dust",
+		"tx-size",
+		"non-final",
+		"scriptsig-not-pushonly",
+		"decode failed",
+	}
+)
+
+// classifySendTxError 根据上游返回的错误信息区分已上链、永久拒绝和可重试的错误
+func classifySendTxError(msg string) error {
+	return classifySendTxErrorDefault(msg, ErrTxRetryable)
+}
+
+// classifySendTxErrorDefault 错误信息不属于已知原因时按 fallback 处理
+func classifySendTxErrorDefault(msg string, fallback error) error {
+	lower := strings.ToLower(msg)
+	for _, reason := range alreadyInChainReasons {
+		if strings.Contains(lower, reason) {
+			return fmt.Errorf("%w: %s", ErrTxAlreadyInChain, msg)
+		}
+	}
+	for _, reason := range retryableReasons {
+		if strings.Contains(lower, reason) {
+			return fmt.Errorf("%w: %s", ErrTxRetryable, msg)
+		}
+	}
+	for _, reason := range rejectedReasons {
+		if strings.Contains(lower, reason) {
+			return fmt.Errorf("%w: %s", ErrTxRejected, msg)
+		}
+	}
+	return fmt.Errorf("%w: %s", fallback, msg)
+}
+
+// alreadyInChainTxId 交易已经在链上时根据原始交易计算 txid，计算失败时返回可重试的错误，不能把空 hash 当作已经广播
+func alreadyInChainTxId(rawTx string, sendErr error) (string, error) {
+	txHash, err := TxIdFromRawTx(rawTx)
+	if err != nil {
+		return "", fmt.Errorf("%w: compute txid of %v fail: %v", ErrTxRetryable, sendErr, err)
+	}
+	return txHash, sendErr
+}
@@ -0,0 +1,332 @@
+package syncclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+const (
+	esploraTimeout = 60 * time.Second
+	// esploraTxsPageSize /block/:hash/txs 每页返回的交易数
+	esploraTxsPageSize = 25
+	// esploraMempoolParallelism 逐笔查询内存池交易时的最大并发请求数
+	esploraMempoolParallelism = 8
+)
+
+// esploraError Esplora 返回非 2xx 状态码时的错误，响应体为纯文本
+type esploraError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *esploraError) Error() string {
+	return fmt.Sprintf("esplora http status %d: %s", e.StatusCode, e.Message)
+}
+
+type esploraBlock struct {
+	Id                string `json:"id"`
+	Height            int64  `json:"height"`
+	Timestamp         uint64 `json:"timestamp"`
+	TxCount           int    `json:"tx_count"`
+	PreviousBlockHash string `json:"previousblockhash"`
+}
+
+type esploraTx struct {
+	Txid   string        `json:"txid"`
+	Vin    []esploraVin  `json:"vin"`
+	Vout   []esploraVout `json:"vout"`
+	Fee    int64         `json:"fee"`
+	Status struct {
+		Confirmed   bool   `json:"confirmed"`
+		BlockHeight int64  `json:"block_height"`
+		BlockHash   string `json:"block_hash"`
+	} `json:"status"`
+}
+
+type esploraVin struct {
+	Txid       string       `json:"txid"`
+	Vout       uint32       `json:"vout"`
+	Prevout    *esploraVout `json:"prevout"`
+	IsCoinbase bool         `json:"is_coinbase"`
+}
+
+type esploraVout struct {
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+// EsploraClient 通过 Esplora/Electrs 的 REST 接口实现 ChainSource，输入自带上一笔输出，金额单位为聪
+type EsploraClient struct {
+	baseUrl string
+	source  string
+	client  *http.Client
+}
+
+func NewEsploraClient(baseUrl string) (*EsploraClient, error) {
+	parsed, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid esplora url: %w", err)
+	}
+	log.Info("New esplora client", "host", parsed.Host)
+	return &EsploraClient{
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+		source:  parsed.Host,
+		client:  &http.Client{Timeout: esploraTimeout},
+	}, nil
+}
+
+func (c *EsploraClient) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.baseUrl+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &esploraError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
+
+func (c *EsploraClient) getText(path string) (string, error) {
+	data, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *EsploraClient) getJson(path string, result interface{}) error {
+	data, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (c *EsploraClient) blockHash(number *big.Int) (string, error) {
+	if number == nil {
+		return c.getText("/blocks/tip/hash")
+	}
+	return c.getText("/block-height/" + number.String())
+}
+
+func (c *EsploraClient) GetBlockHeader(number *big.Int) (*BlockHeader, error) {
+	hash, err := c.blockHash(number)
+	if err != nil {
+		return nil, err
+	}
+	var block esploraBlock
+	if err := c.getJson("/block/"+hash, &block); err != nil {
+		return nil, err
+	}
+	return &BlockHeader{
+		Hash:      block.Id,
+		PrevHash:  block.PreviousBlockHash,
+		Number:    big.NewInt(block.Height),
+		Timestamp: block.Timestamp,
+		Source:    c.source,
+	}, nil
+}
+
+func (c *EsploraClient) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	var block esploraBlock
+	if err := c.getJson("/block/"+hash, &block); err != nil {
+		return nil, err
+	}
+	txList := make([]*utxo.TransactionList, 0, block.TxCount)
+	for start := 0; start < block.TxCount; start += esploraTxsPageSize {
+		var txs []esploraTx
+		if err := c.getJson(fmt.Sprintf("/block/%s/txs/%d", hash, start), &txs); err != nil {
+			log.Error("get block txs fail", "hash", hash, "start", start, "err", err)
+			return nil, err
+		}
+		for i := range txs {
+			txList = append(txList, txs[i].toTransactionList())
+		}
+	}
+	if len(txList) != block.TxCount {
+		return nil, fmt.Errorf("block %s has %d txs, got %d", hash, block.TxCount, len(txList))
+	}
+	log.Debug("get block by hash", "hash", hash, "txn", len(txList), "source", c.source)
+	return txList, nil
+}
+
+func (tx *esploraTx) toTransactionList() *utxo.TransactionList {
+	result := &utxo.TransactionList{Hash: tx.Txid, Fee: strconv.FormatInt(tx.Fee, 10)}
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase {
+			result.Vin = append(result.Vin, &utxo.Vin{})
+			continue
+		}
+		if vin.Prevout == nil {
+			result.Vin = append(result.Vin, &utxo.Vin{Hash: vin.Txid, Index: vin.Vout})
+			continue
+		}
+		result.Vin = append(result.Vin, &utxo.Vin{
+			Hash:    vin.Txid,
+			Index:   vin.Vout,
+			Amount:  vin.Prevout.Value,
+			Address: vin.Prevout.ScriptPubKeyAddress,
+		})
+	}
+	for index, vout := range tx.Vout {
+		result.Vout = append(result.Vout, &utxo.Vout{
+			Address: vout.ScriptPubKeyAddress,
+			Amount:  vout.Value,
+			Index:   uint32(index),
+		})
+	}
+	return result
+}
+
+func (c *EsploraClient) GetTransactionByHash(hash string) (*utxo.TxMessage, error) {
+	var tx esploraTx
+	if err := c.getJson("/tx/"+hash, &tx); err != nil {
+		var esploraErr *esploraError
+		if errors.As(err, &esploraErr) && esploraErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s %s", ErrTxNotFound, hash, esploraErr.Message)
+		}
+		log.Error("get tx by hash fail", "hash", hash, "err", err)
+		return nil, err
+	}
+	txList := tx.toTransactionList()
+	message := &utxo.TxMessage{
+		Hash:   tx.Txid,
+		Fee:    txList.Fee,
+		Status: utxo.TxStatus_Pending,
+		Height: "0",
+	}
+	for _, vin := range txList.Vin {
+		message.Froms = append(message.Froms, &utxo.Address{Address: vin.Address})
+	}
+	for _, vout := range txList.Vout {
+		message.Tos = append(message.Tos, &utxo.Address{Address: vout.Address})
+		message.Values = append(message.Values, &utxo.Value{Value: strconv.FormatInt(vout.Amount, 10)})
+	}
+	if tx.Status.Confirmed {
+		message.Status = utxo.TxStatus_Success
+		message.Height = strconv.FormatInt(tx.Status.BlockHeight, 10)
+	}
+	return message, nil
+}
+
+func (c *EsploraClient) GetMempoolTxIds() ([]string, error) {
+	var txIds []string
+	if err := c.getJson("/mempool/txids", &txIds); err != nil {
+		log.Error("get mempool txids fail", "err", err)
+		return nil, err
+	}
+	return txIds, nil
+}
+
+// GetMempoolTransactions Esplora 没有批量接口，最多 esploraMempoolParallelism 个请求并发逐笔查询；返回的输入自带上一笔输出
+func (c *EsploraClient) GetMempoolTransactions(hashes []string) ([]*utxo.TransactionList, error) {
+	results, err := fetchOrdered(uint64(len(hashes)), esploraMempoolParallelism, func(i uint64) (*utxo.TransactionList, error) {
+		var tx esploraTx
+		if err := c.getJson("/tx/"+hashes[i], &tx); err != nil {
+			var esploraErr *esploraError
+			if errors.As(err, &esploraErr) && esploraErr.StatusCode == http.StatusNotFound {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return tx.toTransactionList(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var txList []*utxo.TransactionList
+	for _, tx := range results {
+		if tx != nil {
+			txList = append(txList, tx)
+		}
+	}
+	return txList, nil
+}
+
+// GetOutpointSpender /tx/:txid/outspend/:vout 同时包含内存池和已上链的花费交易
+func (c *EsploraClient) GetOutpointSpender(txId string, vout uint32) (string, error) {
+	var outspend struct {
+		Spent bool   `json:"spent"`
+		Txid  string `json:"txid"`
+	}
+	if err := c.getJson(fmt.Sprintf("/tx/%s/outspend/%d", txId, vout), &outspend); err != nil {
+		log.Error("get outspend fail", "txId", txId, "vout", vout, "err", err)
+		return "", err
+	}
+	if !outspend.Spent {
+		return "", nil
+	}
+	return outspend.Txid, nil
+}
+
+// SendTx Esplora 把 bitcoind 的拒绝原因放在 400 响应体中，错误分类与 WalletBtcAccountClient.SendTx 相同
+func (c *EsploraClient) SendTx(rawTx string) (string, error) {
+	data, err := c.do(http.MethodPost, "/tx", strings.NewReader(rawTx))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	var esploraErr *esploraError
+	if !errors.As(err, &esploraErr) || esploraErr.StatusCode >= http.StatusInternalServerError {
+		log.Error("send tx fail", "err", err)
+		return "", fmt.Errorf("%w: %v", ErrTxRetryable, err)
+	}
+	sendErr := classifySendTxError(esploraErr.Message)
+	if errors.Is(sendErr, ErrTxAlreadyInChain) {
+		return alreadyInChainTxId(rawTx, sendErr)
+	}
+	return "", sendErr
+}
+
+// GetFeeRate /fee-estimates 返回确认区块数到费率（聪/虚拟字节）的映射，取 feeConfTarget 个区块内确认的费率
+func (c *EsploraClient) GetFeeRate() (int64, error) {
+	var estimates map[string]float64
+	if err := c.getJson("/fee-estimates", &estimates); err != nil {
+		log.Error("get fee fail", "err", err)
+		return 0, err
+	}
+	feeRate, ok := estimates[strconv.Itoa(feeConfTarget)]
+	if !ok {
+		// 没有对应目标时取不超过目标的最近一档
+		best := 0
+		for target, rate := range estimates {
+			blocks, err := strconv.Atoi(target)
+			if err != nil || blocks > feeConfTarget || blocks < best {
+				continue
+			}
+			best, feeRate, ok = blocks, rate, true
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("fee estimate for %d blocks not found", feeConfTarget)
+	}
+	satPerVByte := int64(math.Ceil(feeRate))
+	if satPerVByte < 1 {
+		return 1, nil
+	}
+	return satPerVByte, nil
+}
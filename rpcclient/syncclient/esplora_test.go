@@ -0,0 +1,161 @@
+package syncclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+const esploraBlockHash = "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054"
+
+// newEsploraFixture 用 testdata/esplora 下录制的响应模拟 Esplora，文件名为请求路径把 / 替换成 _，
+// 没有对应文件时与 Esplora 一样返回 404；POST /tx 按交易内容返回 sendResponses 中的状态码和响应体
+func newEsploraFixture(t *testing.T, sendResponses map[string]struct {
+	status int
+	body   string
+}) *EsploraClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/tx" {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			resp, ok := sendResponses[string(body)]
+			require.True(t, ok, string(body))
+			w.WriteHeader(resp.status)
+			_, _ = w.Write([]byte(resp.body))
+			return
+		}
+		name := strings.ReplaceAll(strings.Trim(r.URL.Path, "/"), "/", "_")
+		data, err := os.ReadFile(filepath.Join("testdata", "esplora", name))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("Transaction not found"))
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewEsploraClient(server.URL + "/")
+	require.NoError(t, err)
+	return client
+}
+
+func TestEsploraBlock(t *testing.T) {
+	client := newEsploraFixture(t, nil)
+
+	header, err := client.GetBlockHeader(nil)
+	require.NoError(t, err)
+	require.Equal(t, esploraBlockHash, header.Hash)
+	require.Equal(t, "0000000000000000000590fc0f3eba193a278534220b2b37e9849e1a770ca959", header.PrevHash)
+	require.Equal(t, int64(800000), header.Number.Int64())
+	require.Equal(t, uint64(1690168629), header.Timestamp)
+	require.NotEmpty(t, header.Source)
+
+	// 26 笔交易分两页返回
+	txList, err := client.GetBlockByHash(esploraBlockHash)
+	require.NoError(t, err)
+	require.Len(t, txList, 26)
+
+	require.Equal(t, "0", txList[0].Fee)
+	require.Equal(t, "", txList[0].Vin[0].Address)
+	require.Equal(t, int64(638687680), txList[0].Vout[0].Amount)
+
+	last := txList[25]
+	require.Equal(t, "000000000000000000000000000000000000000000000000000000000000a019", last.Hash)
+	require.Equal(t, "10025", last.Fee)
+	require.Equal(t, &utxo.Vin{
+		Hash:    "000000000000000000000000000000000000000000000000000000000000b019",
+		Index:   1,
+		Amount:  100025,
+		Address: "bc1qfrom25",
+	}, last.Vin[0])
+	require.Equal(t, &utxo.Vout{Address: "bc1qto25", Amount: 90000, Index: 0}, last.Vout[0])
+	require.Equal(t, "", last.Vout[1].Address)
+
+	_, err = client.GetBlockByHash("missing")
+	require.Error(t, err)
+}
+
+func TestEsploraTransaction(t *testing.T) {
+	client := newEsploraFixture(t, nil)
+
+	tx, err := client.GetTransactionByHash("000000000000000000000000000000000000000000000000000000000000a003")
+	require.NoError(t, err)
+	require.Equal(t, utxo.TxStatus_Success, tx.Status)
+	require.Equal(t, "800000", tx.Height)
+	require.Equal(t, "bc1qfrom3", tx.Froms[0].Address)
+	require.Equal(t, "bc1qto3", tx.Tos[0].Address)
+	require.Equal(t, "90000", tx.Values[0].Value)
+	require.Equal(t, "10003", tx.Fee)
+
+	tx, err = client.GetTransactionByHash("000000000000000000000000000000000000000000000000000000000000a004")
+	require.NoError(t, err)
+	require.Equal(t, utxo.TxStatus_Pending, tx.Status)
+	require.Equal(t, "0", tx.Height)
+
+	_, err = client.GetTransactionByHash("missing")
+	require.ErrorIs(t, err, ErrTxNotFound)
+}
+
+func TestEsploraSendTxAndFee(t *testing.T) {
+	client := newEsploraFixture(t, map[string]struct {
+		status int
+		body   string
+	}{
+		"01":         {http.StatusOK, "txid\n"},
+		genesisRawTx: {http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-27,"message":"Transaction already in block chain"}`},
+		"00":         {http.StatusBadRequest, `sendrawtransaction RPC error: {"code":-26,"message":"bad-txns-vout-negative"}`},
+		"02":         {http.StatusBadGateway, "upstream unavailable"},
+	})
+
+	txHash, err := client.SendTx("01")
+	require.NoError(t, err)
+	require.Equal(t, "txid", txHash)
+
+	txHash, err = client.SendTx(genesisRawTx)
+	require.ErrorIs(t, err, ErrTxAlreadyInChain)
+	require.Equal(t, genesisTxId, txHash)
+
+	_, err = client.SendTx("00")
+	require.ErrorIs(t, err, ErrTxRejected)
+
+	_, err = client.SendTx("02")
+	require.ErrorIs(t, err, ErrTxRetryable)
+
+	feeRate, err := client.GetFeeRate()
+	require.NoError(t, err)
+	require.Equal(t, int64(9), feeRate)
+	// 最低转发费率同样作用于直连的数据源
+	feeRate, err = EstimateFeeRate(client, &chaincfg.DogecoinMainNetParams)
+	require.NoError(t, err)
+	require.Equal(t, chaincfg.DogecoinMainNetParams.MinFeeRate, feeRate)
+}
+
+func TestEsploraMempool(t *testing.T) {
+	client := newEsploraFixture(t, nil)
+
+	txIds, err := client.GetMempoolTxIds()
+	require.NoError(t, err)
+	require.Len(t, txIds, 2)
+
+	// 已经离开内存池的交易跳过
+	txList, err := client.GetMempoolTransactions(txIds)
+	require.NoError(t, err)
+	require.Len(t, txList, 1)
+	require.Equal(t, txIds[0], txList[0].Hash)
+
+	spender, err := client.GetOutpointSpender(txList[0].Vin[0].Hash, txList[0].Vin[0].Index)
+	require.NoError(t, err)
+	require.Equal(t, txIds[0], spender)
+	spender, err = client.GetOutpointSpender(txList[0].Vin[0].Hash, 0)
+	require.NoError(t, err)
+	require.Empty(t, spender)
+}
@@ -0,0 +1,303 @@
+package syncclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+const (
+	defaultCallTimeout = 30 * time.Second
+	// latencyWeight 延迟按指数加权平均计算，新样本占的比例
+	latencyWeight = 0.2
+)
+
+var ErrNoEndpoint = errors.New("no upstream endpoint configured")
+
+// FailoverConfig 上游节点的熔断和健康检查参数
+type FailoverConfig struct {
+	// BreakerThreshold 连续失败多少次后熔断，熔断期间不再优先选择该节点
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+	HealthInterval   time.Duration
+	// CallTimeout 调用方没有设置超时时单次请求的超时时间
+	CallTimeout time.Duration
+	// Quorum 大于 1 时每个区块头向 Quorum 个节点查询，超过半数一致才接受
+	Quorum int
+	// Network 健康检查和多节点校验请求的网络
+	Network string
+}
+
+// EndpointStatus 上游节点当前的状态
+type EndpointStatus struct {
+	Addr      string
+	Healthy   bool
+	Latency   time.Duration
+	Failures  int
+	OpenUntil time.Time
+	Height    int64
+}
+
+type endpoint struct {
+	addr string
+	conn grpc.ClientConnInterface
+
+	mu        sync.Mutex
+	healthy   bool
+	latency   time.Duration
+	failures  int
+	openUntil time.Time
+	height    int64
+}
+
+func (e *endpoint) status() EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return EndpointStatus{
+		Addr:      e.addr,
+		Healthy:   e.healthy,
+		Latency:   e.latency,
+		Failures:  e.failures,
+		OpenUntil: e.openUntil,
+		Height:    e.height,
+	}
+}
+
+// FailoverConn 实现 grpc.ClientConnInterface，按健康状态和延迟选择上游节点，
+// 请求出现网络类错误时切换到下一个节点
+type FailoverConn struct {
+	endpoints []*endpoint
+	cfg       FailoverConfig
+
+	closers []func() error
+	stop    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	// voters 多节点校验时每个区块 hash 的投票节点，voterHashes 按记录顺序淘汰
+	votersMu    sync.Mutex
+	voters      map[string][]string
+	voterHashes []string
+}
+
+// DialFailover 连接所有上游节点，连接是惰性建立的，某个节点暂时不可用不影响启动
+func DialFailover(addrs []string, cfg FailoverConfig, opts ...grpc.DialOption) (*FailoverConn, error) {
+	var (
+		conns   = make(map[string]grpc.ClientConnInterface)
+		closers []func() error
+	)
+	for _, addr := range addrs {
+		conn, err := grpc.NewClient(addr, opts...)
+		if err != nil {
+			for _, closer := range closers {
+				_ = closer()
+			}
+			return nil, fmt.Errorf("dial upstream %s: %w", addr, err)
+		}
+		conns[addr] = conn
+		closers = append(closers, conn.Close)
+	}
+	f, err := newFailoverConn(addrs, conns, cfg)
+	if err != nil {
+		return nil, err
+	}
+	f.closers = closers
+	return f, nil
+}
+
+func newFailoverConn(addrs []string, conns map[string]grpc.ClientConnInterface, cfg FailoverConfig) (*FailoverConn, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if cfg.CallTimeout <= 0 {
+		cfg.CallTimeout = defaultCallTimeout
+	}
+	f := &FailoverConn{cfg: cfg, stop: make(chan struct{})}
+	for _, addr := range addrs {
+		f.endpoints = append(f.endpoints, &endpoint{addr: addr, conn: conns[addr], healthy: true})
+	}
+	return f, nil
+}
+
+// servedByOption 记录实际处理请求的上游节点
+type servedByOption struct {
+	grpc.EmptyCallOption
+	addr *string
+}
+
+// ServedBy 请求成功后把处理请求的上游节点地址写入 addr
+func ServedBy(addr *string) grpc.CallOption {
+	return servedByOption{addr: addr}
+}
+
+func (f *FailoverConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	var errs []error
+	for _, ep := range f.candidates() {
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if _, ok := ctx.Deadline(); !ok {
+			callCtx, cancel = context.WithTimeout(ctx, f.cfg.CallTimeout)
+		}
+		start := time.Now()
+		err := ep.conn.Invoke(callCtx, method, args, reply, opts...)
+		cancel()
+		if err == nil {
+			f.recordSuccess(ep, time.Now().Sub(start))
+			for _, opt := range opts {
+				if served, ok := opt.(servedByOption); ok && served.addr != nil {
+					*served.addr = ep.addr
+				}
+			}
+			return nil
+		}
+		if ctx.Err() != nil || !failoverCode(status.Code(err)) {
+			return err
+		}
+		f.recordFailure(ep)
+		log.Warn("upstream call fail, try next endpoint", "endpoint", ep.addr, "method", method, "err", err)
+		errs = append(errs, fmt.Errorf("%s: %w", ep.addr, err))
+	}
+	return errors.Join(errs...)
+}
+
+// NewStream 上游接口没有流式调用，直接使用当前首选节点
+func (f *FailoverConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return f.candidates()[0].conn.NewStream(ctx, desc, method, opts...)
+}
+
+// candidates 没有熔断的节点优先，其中健康的在前、延迟低的在前；熔断的节点按恢复时间排在最后，
+// 所有节点都熔断时仍然会逐个尝试
+func (f *FailoverConn) candidates() []*endpoint {
+	now := time.Now()
+	statuses := make(map[*endpoint]EndpointStatus, len(f.endpoints))
+	result := make([]*endpoint, len(f.endpoints))
+	copy(result, f.endpoints)
+	for _, ep := range result {
+		statuses[ep] = ep.status()
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := statuses[result[i]], statuses[result[j]]
+		aOpen, bOpen := now.Before(a.OpenUntil), now.Before(b.OpenUntil)
+		if aOpen != bOpen {
+			return !aOpen
+		}
+		if aOpen {
+			return a.OpenUntil.Before(b.OpenUntil)
+		}
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		return a.Latency < b.Latency
+	})
+	return result
+}
+
+func (f *FailoverConn) recordSuccess(ep *endpoint, latency time.Duration) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.latency == 0 {
+		ep.latency = latency
+	} else {
+		ep.latency = time.Duration(float64(ep.latency)*(1-latencyWeight) + float64(latency)*latencyWeight)
+	}
+	if !ep.healthy || ep.failures > 0 {
+		log.Info("upstream endpoint recovered", "endpoint", ep.addr)
+	}
+	ep.healthy = true
+	ep.failures = 0
+	ep.openUntil = time.Time{}
+}
+
+func (f *FailoverConn) recordFailure(ep *endpoint) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.healthy = false
+	ep.failures++
+	if f.cfg.BreakerThreshold > 0 && ep.failures >= f.cfg.BreakerThreshold {
+		ep.openUntil = time.Now().Add(f.cfg.BreakerTimeout)
+		log.Warn("upstream endpoint circuit open", "endpoint", ep.addr, "failures", ep.failures, "until", ep.openUntil)
+	}
+}
+
+// failoverCode 网络、超时和上游内部错误换节点重试，参数类错误换节点也不会成功
+func failoverCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// Status 返回所有上游节点的状态
+func (f *FailoverConn) Status() []EndpointStatus {
+	var statuses []EndpointStatus
+	for _, ep := range f.endpoints {
+		statuses = append(statuses, ep.status())
+	}
+	return statuses
+}
+
+// StartHealthCheck 定时查询每个节点的最新区块头，更新健康状态和延迟，熔断的节点检查成功后恢复
+func (f *FailoverConn) StartHealthCheck() {
+	if f.cfg.HealthInterval <= 0 {
+		return
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.cfg.HealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.checkHealth()
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (f *FailoverConn) checkHealth() {
+	for _, ep := range f.endpoints {
+		ctx, cancel := context.WithTimeout(context.Background(), f.cfg.CallTimeout)
+		start := time.Now()
+		header, err := utxo.NewWalletUtxoServiceClient(ep.conn).GetBlockHeaderByNumber(ctx, &utxo.BlockHeaderNumberRequest{Network: f.cfg.Network})
+		cancel()
+		if err != nil {
+			log.Warn("upstream health check fail", "endpoint", ep.addr, "err", err)
+			f.recordFailure(ep)
+			continue
+		}
+		f.recordSuccess(ep, time.Now().Sub(start))
+		height, _ := strconv.ParseInt(header.Number, 10, 64)
+		ep.mu.Lock()
+		ep.height = height
+		ep.mu.Unlock()
+	}
+	log.Debug("upstream endpoints status", "status", f.Status())
+}
+
+func (f *FailoverConn) Close() error {
+	var result error
+	f.once.Do(func() {
+		close(f.stop)
+		f.wg.Wait()
+		for _, closer := range f.closers {
+			if err := closer(); err != nil {
+				result = errors.Join(result, err)
+			}
+		}
+	})
+	return result
+}
@@ -0,0 +1,128 @@
+package syncclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/common"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// fakeConn 按顺序返回预设的错误，没有错误时返回以 hash 为区块 hash 的区块头
+type fakeConn struct {
+	grpc.ClientConnInterface
+	hash   string
+	number string
+	code   common.ReturnCode
+	errs   []error
+	calls  int
+}
+
+func (f *fakeConn) Invoke(_ context.Context, _ string, _ interface{}, reply interface{}, _ ...grpc.CallOption) error {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	// 区块内容以返回的节点 hash 作为唯一交易，用于区分由哪个节点提供
+	if block, ok := reply.(*utxo.BlockResponse); ok {
+		block.Code = f.code
+		block.TxList = []*utxo.TransactionList{{Hash: f.hash}}
+		return nil
+	}
+	header := reply.(*utxo.BlockHeaderResponse)
+	header.Code = f.code
+	header.BlockHash = f.hash
+	header.Number = f.number
+	if header.Number == "" {
+		header.Number = "1"
+	}
+	return nil
+}
+
+func newTestFailover(t *testing.T, conns map[string]*fakeConn, addrs []string) (*FailoverConn, *WalletBtcAccountClient) {
+	clientConns := make(map[string]grpc.ClientConnInterface)
+	for addr, conn := range conns {
+		clientConns[addr] = conn
+	}
+	f, err := newFailoverConn(addrs, clientConns, FailoverConfig{BreakerThreshold: 2, BreakerTimeout: time.Minute})
+	require.NoError(t, err)
+	client, err := NewWalletBtcAccountClient(context.Background(), utxo.NewWalletUtxoServiceClient(f), "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	return f, client
+}
+
+func TestFailoverOnUnavailable(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	conns := map[string]*fakeConn{
+		"a": {hash: "a", errs: []error{unavailable, unavailable, unavailable}},
+		"b": {hash: "b"},
+	}
+	f, client := newTestFailover(t, conns, []string{"a", "b"})
+
+	header, err := client.GetBlockHeader(big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, "b", header.Hash)
+	require.Equal(t, "b", header.Source)
+
+	// a 不健康后优先请求 b
+	_, err = client.GetBlockHeader(big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, 1, conns["a"].calls)
+	require.Equal(t, 2, conns["b"].calls)
+
+	// 健康检查再次失败达到阈值后熔断
+	f.checkHealth()
+	statuses := f.Status()
+	require.False(t, statuses[0].Healthy)
+	require.True(t, statuses[0].OpenUntil.After(time.Now()))
+	require.True(t, statuses[1].Healthy)
+
+	// 节点恢复后健康检查成功即关闭熔断
+	conns["a"].errs = nil
+	f.checkHealth()
+	statuses = f.Status()
+	require.True(t, statuses[0].Healthy)
+	require.True(t, statuses[0].OpenUntil.IsZero())
+	require.Equal(t, int64(1), statuses[0].Height)
+}
+
+func TestFailoverKeepsNonRetryableError(t *testing.T) {
+	conns := map[string]*fakeConn{
+		"a": {hash: "a", errs: []error{status.Error(codes.InvalidArgument, "bad height")}},
+		"b": {hash: "b"},
+	}
+	_, client := newTestFailover(t, conns, []string{"a", "b"})
+
+	_, err := client.GetBlockHeader(big.NewInt(1))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, 0, conns["b"].calls)
+}
+
+func TestFailoverAllEndpointsDown(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	conns := map[string]*fakeConn{
+		"a": {hash: "a", errs: []error{unavailable}},
+		"b": {hash: "b", errs: []error{unavailable}},
+	}
+	_, client := newTestFailover(t, conns, []string{"a", "b"})
+
+	_, err := client.GetBlockHeader(big.NewInt(1))
+	require.Error(t, err)
+
+	// 所有节点都不健康时仍然逐个尝试
+	header, err := client.GetBlockHeader(big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, "a", header.Hash)
+}
@@ -0,0 +1,35 @@
+package syncclient
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// fetchOrdered 最多 parallelism 个请求并发执行 fetch(i)，结果按 i 的顺序返回；
+// 任一请求失败时不再发起新的请求，返回第一个错误
+func fetchOrdered[T any](count uint64, parallelism int, fetch func(i uint64) (T, error)) ([]T, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	results := make([]T, count)
+	group, ctx := errgroup.WithContext(context.Background())
+	group.SetLimit(parallelism)
+	for i := uint64(0); i < count; i++ {
+		group.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			result, err := fetch(i)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,215 @@
+package syncclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/common"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// maxQuorumVoters 最多记录多少个区块 hash 的投票节点，超过后淘汰最早记录的
+const maxQuorumVoters = 4096
+
+var ErrNoQuorum = errors.New("upstream endpoints did not reach quorum on block header")
+
+var (
+	quorumDisagreementCounter = metrics.NewRegisteredCounter("upstream/quorum/disagreements", nil)
+	quorumFailureCounter      = metrics.NewRegisteredCounter("upstream/quorum/failures", nil)
+)
+
+type headerVote struct {
+	addr   string
+	header *utxo.BlockHeaderResponse
+	err    error
+}
+
+// queryHeaders 同时向 size 个首选节点查询区块头
+func (f *FailoverConn) queryHeaders(request *utxo.BlockHeaderNumberRequest, size int) []headerVote {
+	endpoints := f.candidates()
+	if size < len(endpoints) {
+		endpoints = endpoints[:size]
+	}
+	votes := make([]headerVote, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), f.cfg.CallTimeout)
+			defer cancel()
+			start := time.Now()
+			header, err := utxo.NewWalletUtxoServiceClient(ep.conn).GetBlockHeaderByNumber(ctx, request)
+			// 返回错误码的节点没有给出区块头，不参与投票
+			if err == nil && header.Code == common.ReturnCode_ERROR {
+				err = fmt.Errorf("upstream error: %s", header.Msg)
+			}
+			if err != nil {
+				f.recordFailure(ep)
+				log.Warn("quorum query block header fail", "endpoint", ep.addr, "height", request.Height, "err", err)
+			} else {
+				f.recordSuccess(ep, time.Since(start))
+			}
+			votes[i] = headerVote{addr: ep.addr, header: header, err: err}
+		}(i, ep)
+	}
+	wg.Wait()
+	return votes
+}
+
+// QuorumBlockHeader 向 size 个节点查询同一高度的区块头，超过半数返回相同 hash 时才接受；
+// 查询最新区块时先取多数节点都已经达到的高度，再按该高度投票
+func (f *FailoverConn) QuorumBlockHeader(number *big.Int, size int) (*BlockHeader, error) {
+	if size > len(f.endpoints) {
+		size = len(f.endpoints)
+	}
+	if number == nil {
+		height, err := f.quorumHeight(size)
+		if err != nil {
+			return nil, err
+		}
+		number = big.NewInt(height)
+	}
+	votes := f.queryHeaders(&utxo.BlockHeaderNumberRequest{Network: f.cfg.Network, Height: number.Int64()}, size)
+
+	hashVotes := make(map[string][]string)
+	var winner string
+	for _, vote := range votes {
+		if vote.err != nil {
+			continue
+		}
+		hashVotes[vote.header.BlockHash] = append(hashVotes[vote.header.BlockHash], vote.addr)
+		if len(hashVotes[vote.header.BlockHash]) > len(hashVotes[winner]) {
+			winner = vote.header.BlockHash
+		}
+	}
+	if len(hashVotes) > 1 {
+		quorumDisagreementCounter.Inc(1)
+		log.Warn("upstream block hash disagreement", "height", number, "votes", formatVotes(hashVotes))
+	}
+	if len(hashVotes[winner])*2 <= size {
+		quorumFailureCounter.Inc(1)
+		return nil, fmt.Errorf("%w: height %s, votes %s", ErrNoQuorum, number, formatVotes(hashVotes))
+	}
+	f.recordVoters(winner, hashVotes[winner])
+	for _, vote := range votes {
+		if vote.err == nil && vote.header.BlockHash == winner {
+			blockNumber, _ := new(big.Int).SetString(vote.header.Number, 10)
+			return &BlockHeader{
+				Hash:     vote.header.BlockHash,
+				PrevHash: vote.header.ParentHash,
+				Number:   blockNumber,
+				Source:   strings.Join(hashVotes[winner], ","),
+			}, nil
+		}
+	}
+	return nil, ErrNoQuorum
+}
+
+// recordVoters 记录投票给 hash 的节点，之后只从这些节点获取区块内容
+func (f *FailoverConn) recordVoters(hash string, addrs []string) {
+	f.votersMu.Lock()
+	defer f.votersMu.Unlock()
+	if f.voters == nil {
+		f.voters = make(map[string][]string)
+	}
+	if _, ok := f.voters[hash]; !ok {
+		f.voterHashes = append(f.voterHashes, hash)
+	}
+	f.voters[hash] = addrs
+	for len(f.voterHashes) > maxQuorumVoters {
+		delete(f.voters, f.voterHashes[0])
+		f.voterHashes = f.voterHashes[1:]
+	}
+}
+
+// votedEndpoints 按当前优先级返回投票给 hash 的节点，没有记录时返回 nil
+func (f *FailoverConn) votedEndpoints(hash string) []*endpoint {
+	f.votersMu.Lock()
+	addrs := f.voters[hash]
+	f.votersMu.Unlock()
+	voted := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		voted[addr] = true
+	}
+	var endpoints []*endpoint
+	for _, ep := range f.candidates() {
+		if voted[ep.addr] {
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return endpoints
+}
+
+// QuorumBlockByHash 从投票给该区块头的节点获取区块内容，其他节点可能处在另一条分叉上；
+// 没有投票记录时（例如进程重启后）依次尝试所有节点，仍然按 hash 获取
+func (f *FailoverConn) QuorumBlockByHash(request *utxo.BlockHashRequest) (*utxo.BlockResponse, error) {
+	endpoints := f.votedEndpoints(request.Hash)
+	if len(endpoints) == 0 {
+		endpoints = f.candidates()
+	}
+	var errs []error
+	for _, ep := range endpoints {
+		ctx, cancel := context.WithTimeout(context.Background(), f.cfg.CallTimeout)
+		start := time.Now()
+		block, err := utxo.NewWalletUtxoServiceClient(ep.conn).GetBlockByHash(ctx, request)
+		cancel()
+		if err == nil && block.Code == common.ReturnCode_ERROR {
+			err = fmt.Errorf("upstream error: %s", block.Msg)
+		}
+		if err == nil && block.Hash != "" && block.Hash != request.Hash {
+			err = fmt.Errorf("upstream returned block %s", block.Hash)
+		}
+		if err != nil {
+			f.recordFailure(ep)
+			log.Warn("quorum get block by hash fail", "endpoint", ep.addr, "hash", request.Hash, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", ep.addr, err))
+			continue
+		}
+		f.recordSuccess(ep, time.Since(start))
+		log.Debug("get block by hash", "hash", request.Hash, "txn", len(block.TxList), "source", ep.addr)
+		return block, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// quorumHeight 多数节点都已经同步到的最高高度，防止单个节点报告虚高的最新区块
+func (f *FailoverConn) quorumHeight(size int) (int64, error) {
+	var heights []int64
+	for _, vote := range f.queryHeaders(&utxo.BlockHeaderNumberRequest{Network: f.cfg.Network}, size) {
+		if vote.err != nil {
+			continue
+		}
+		height, err := strconv.ParseInt(vote.header.Number, 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	majority := size/2 + 1
+	if len(heights) < majority {
+		quorumFailureCounter.Inc(1)
+		return 0, fmt.Errorf("%w: only %d of %d endpoints reported latest height", ErrNoQuorum, len(heights), size)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] > heights[j] })
+	return heights[majority-1], nil
+}
+
+func formatVotes(hashVotes map[string][]string) string {
+	var parts []string
+	for hash, addrs := range hashVotes {
+		parts = append(parts, hash+"="+strings.Join(addrs, ","))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,123 @@
+package syncclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/common"
+)
+
+func newTestQuorum(t *testing.T, conns map[string]*fakeConn, addrs []string) *FailoverConn {
+	clientConns := make(map[string]grpc.ClientConnInterface)
+	for addr, conn := range conns {
+		clientConns[addr] = conn
+	}
+	f, err := newFailoverConn(addrs, clientConns, FailoverConfig{BreakerThreshold: 2, BreakerTimeout: time.Minute, Quorum: len(addrs)})
+	require.NoError(t, err)
+	return f
+}
+
+func TestQuorumBlockHeader(t *testing.T) {
+	conns := map[string]*fakeConn{
+		"a": {hash: "x"},
+		"b": {hash: "y"},
+		"c": {hash: "x"},
+	}
+	f := newTestQuorum(t, conns, []string{"a", "b", "c"})
+
+	disagreements := quorumDisagreementCounter.Snapshot().Count()
+	header, err := f.QuorumBlockHeader(big.NewInt(1), 3)
+	require.NoError(t, err)
+	require.Equal(t, "x", header.Hash)
+	require.Equal(t, "a,c", header.Source)
+	require.Equal(t, disagreements+1, quorumDisagreementCounter.Snapshot().Count())
+}
+
+func TestQuorumBlockHeaderNoMajority(t *testing.T) {
+	conns := map[string]*fakeConn{
+		"a": {hash: "x"},
+		"b": {hash: "y"},
+		"c": {hash: "x", errs: []error{status.Error(codes.Unavailable, "connection refused")}},
+	}
+	f := newTestQuorum(t, conns, []string{"a", "b", "c"})
+
+	failures := quorumFailureCounter.Snapshot().Count()
+	_, err := f.QuorumBlockHeader(big.NewInt(1), 3)
+	require.ErrorIs(t, err, ErrNoQuorum)
+	require.Equal(t, failures+1, quorumFailureCounter.Snapshot().Count())
+}
+
+func TestQuorumBlockHeaderSkipsErrorCode(t *testing.T) {
+	// b、c 返回错误码，没有给出区块头，不能算作对空 hash 的投票
+	conns := map[string]*fakeConn{
+		"a": {hash: "x"},
+		"b": {code: common.ReturnCode_ERROR},
+		"c": {code: common.ReturnCode_ERROR},
+	}
+	f := newTestQuorum(t, conns, []string{"a", "b", "c"})
+
+	_, err := f.QuorumBlockHeader(big.NewInt(1), 3)
+	require.ErrorIs(t, err, ErrNoQuorum)
+
+	_, err = f.quorumHeight(3)
+	require.ErrorIs(t, err, ErrNoQuorum)
+}
+
+func TestQuorumBlockByHash(t *testing.T) {
+	conns := map[string]*fakeConn{
+		"a": {hash: "y"},
+		"b": {hash: "x"},
+		"c": {hash: "x"},
+	}
+	f := newTestQuorum(t, conns, []string{"a", "b", "c"})
+	client, err := NewWalletBtcAccountClient(context.Background(), nil, "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	client.upstream = f
+
+	header, err := client.GetBlockHeader(big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, "x", header.Hash)
+
+	// 只从投票给 x 的节点获取区块内容，a 处在另一条分叉上
+	calls := conns["a"].calls
+	txList, err := client.GetBlockByHash(header.Hash)
+	require.NoError(t, err)
+	require.Equal(t, "x", txList[0].Hash)
+	require.Equal(t, calls, conns["a"].calls)
+
+	// 投票节点返回错误码时换下一个投票节点
+	conns["b"].code = common.ReturnCode_ERROR
+	conns["c"].code = common.ReturnCode_ERROR
+	_, err = client.GetBlockByHash(header.Hash)
+	require.Error(t, err)
+	require.Equal(t, calls, conns["a"].calls)
+}
+
+func TestQuorumLatestHeight(t *testing.T) {
+	// c 报告虚高的最新高度，取多数节点都已经达到的高度
+	conns := map[string]*fakeConn{
+		"a": {hash: "x", number: "100"},
+		"b": {hash: "x", number: "99"},
+		"c": {hash: "y", number: "200"},
+	}
+	f := newTestQuorum(t, conns, []string{"a", "b", "c"})
+
+	height, err := f.quorumHeight(3)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), height)
+
+	client, err := NewWalletBtcAccountClient(context.Background(), nil, "Bitcoin", &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	client.upstream = f
+	header, err := client.GetBlockHeader(nil)
+	require.NoError(t, err)
+	require.Equal(t, "x", header.Hash)
+}
@@ -0,0 +1,150 @@
+package syncclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var errTxDecode = errors.New("decode raw transaction fail")
+
+// TxIdFromRawTx 根据签名后的原始交易计算 txid，segwit 交易计算时去掉见证数据
+func TxIdFromRawTx(rawTx string) (string, error) {
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errTxDecode, err)
+	}
+	stripped, err := stripWitness(raw)
+	if err != nil {
+		return "", err
+	}
+	first := sha256.Sum256(stripped)
+	second := sha256.Sum256(first[:])
+	// txid 按小端字节序展示
+	for i, j := 0, len(second)-1; i < j; i, j = i+1, j-1 {
+		second[i], second[j] = second[j], second[i]
+	}
+	return hex.EncodeToString(second[:]), nil
+}
+
+type txReader struct {
+	raw []byte
+	pos int
+}
+
+func (r *txReader) read(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.raw) {
+		return nil, errTxDecode
+	}
+	data := r.raw[r.pos : r.pos+n]
+	r.pos += n
+	return data, nil
+}
+
+func (r *txReader) readVarInt() (uint64, error) {
+	prefix, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	switch prefix[0] {
+	case 0xfd:
+		data, err := r.read(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case 0xfe:
+		data, err := r.read(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case 0xff:
+		data, err := r.read(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint64(data), nil
+	default:
+		return uint64(prefix[0]), nil
+	}
+}
+
+// skipVarBytes 跳过一个带长度前缀的字段
+func (r *txReader) skipVarBytes() error {
+	length, err := r.readVarInt()
+	if err != nil {
+		return err
+	}
+	_, err = r.read(int(length))
+	return err
+}
+
+// stripWitness 返回不含 marker、flag 和见证数据的交易序列化
+func stripWitness(raw []byte) ([]byte, error) {
+	if len(raw) < 10 {
+		return nil, errTxDecode
+	}
+	if raw[4] != 0x00 || raw[5] != 0x01 {
+		return raw, nil
+	}
+
+	r := &txReader{raw: raw, pos: 6}
+	inputCount, err := r.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < inputCount; i++ {
+		// 前序输出 32 字节 + 序号 4 字节，解锁脚本，sequence 4 字节
+		if _, err := r.read(36); err != nil {
+			return nil, err
+		}
+		if err := r.skipVarBytes(); err != nil {
+			return nil, err
+		}
+		if _, err := r.read(4); err != nil {
+			return nil, err
+		}
+	}
+	outputCount, err := r.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < outputCount; i++ {
+		if _, err := r.read(8); err != nil {
+			return nil, err
+		}
+		if err := r.skipVarBytes(); err != nil {
+			return nil, err
+		}
+	}
+	body := raw[6:r.pos]
+
+	for i := uint64(0); i < inputCount; i++ {
+		items, err := r.readVarInt()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < items; j++ {
+			if err := r.skipVarBytes(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	lockTime, err := r.read(4)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(raw) {
+		return nil, errTxDecode
+	}
+
+	var stripped bytes.Buffer
+	stripped.Write(raw[:4])
+	stripped.Write(body)
+	stripped.Write(lockTime)
+	return stripped.Bytes(), nil
+}
@@ -7,4 +7,8 @@ type BlockHeader struct {
 	PrevHash  string
 	Number    *big.Int
 	Timestamp uint64
+	// Source 返回该区块头的上游节点，用于排查节点之间数据不一致
+	Source string
+	// Chain 区块所属的链，只在从数据库读出时填充
+	Chain string
 }
@@ -0,0 +1,203 @@
+package zmqclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	TopicHashBlock = "hashblock"
+	TopicRawTx     = "rawtx"
+
+	dialTimeout = 10 * time.Second
+	// maxFrameSize 单帧上限，rawtx 不会超过区块大小
+	maxFrameSize = 8 << 20
+
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// Message bitcoind 推送的一条消息，由 topic、body 和 4 字节小端序号三帧组成
+type Message struct {
+	Topic    string
+	Body     []byte
+	Sequence uint32
+}
+
+// Subscriber 最小实现的 ZMTP 3.0 SUB 端，只支持 NULL 认证，用于订阅 bitcoind 的 zmqpubhashblock / zmqpubrawtx
+type Subscriber struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	stop   func() bool
+}
+
+// Dial addr 格式与 bitcoind 配置一致，如 tcp://127.0.0.1:28332；ctx 取消时关闭连接
+func Dial(ctx context.Context, addr string, topics ...string) (*Subscriber, error) {
+	host, ok := strings.CutPrefix(addr, "tcp://")
+	if !ok {
+		return nil, fmt.Errorf("unsupported zmq address %q, only tcp is supported", addr)
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscriber{conn: conn, reader: bufio.NewReader(conn)}
+	s.stop = context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := s.handshake(); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("zmq handshake with %s fail: %w", addr, err)
+	}
+	for _, topic := range topics {
+		// ZMTP 3.0 的订阅是首字节为 1 的普通消息
+		if err := s.writeFrame(0, append([]byte{1}, topic...)); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+func (s *Subscriber) handshake() error {
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+	if _, err := s.conn.Write(greeting); err != nil {
+		return err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(s.reader, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return errors.New("invalid zmtp signature")
+	}
+	if peer[10] < 3 {
+		return fmt.Errorf("unsupported zmtp version %d.%d", peer[10], peer[11])
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("unsupported zmtp mechanism %s", mechanism)
+	}
+
+	if err := s.writeFrame(flagCommand, readyCommand("SUB")); err != nil {
+		return err
+	}
+	flags, body, err := s.readFrame()
+	if err != nil {
+		return err
+	}
+	if flags&flagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return errors.New("expected zmtp READY command")
+	}
+	return nil
+}
+
+func readyCommand(socketType string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(5)
+	buf.WriteString("READY")
+	buf.WriteByte(byte(len("Socket-Type")))
+	buf.WriteString("Socket-Type")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(socketType)))
+	buf.WriteString(socketType)
+	return buf.Bytes()
+}
+
+func (s *Subscriber) writeFrame(flags byte, body []byte) error {
+	return writeFrame(s.conn, flags, body)
+}
+
+func writeFrame(w io.Writer, flags byte, body []byte) error {
+	var header []byte
+	if len(body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	} else {
+		header = []byte{flags, byte(len(body))}
+	}
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+func (s *Subscriber) readFrame() (byte, []byte, error) {
+	flags, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("zmq frame too large: %d", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// Recv 阻塞读取下一条消息，timeout 内没有收到任何数据时返回超时错误，timeout 为 0 时不超时
+func (s *Subscriber) Recv(timeout time.Duration) (*Message, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	_ = s.conn.SetReadDeadline(deadline)
+	var parts [][]byte
+	for {
+		flags, body, err := s.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		// 忽略 ZMTP 3.1 的 PING 等命令帧
+		if flags&flagCommand != 0 {
+			continue
+		}
+		parts = append(parts, body)
+		if flags&flagMore != 0 {
+			continue
+		}
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("unexpected zmq message with %d parts", len(parts))
+		}
+		message := &Message{Topic: string(parts[0]), Body: parts[1]}
+		if len(parts) > 2 && len(parts[2]) == 4 {
+			message.Sequence = binary.LittleEndian.Uint32(parts[2])
+		}
+		return message, nil
+	}
+}
+
+func (s *Subscriber) Close() error {
+	s.stop()
+	return s.conn.Close()
+}
@@ -0,0 +1,132 @@
+package zmqclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// publisher 本地 ZMTP PUB 端，握手后记录订阅的 topic，再按顺序推送 frames
+type publisher struct {
+	t        *testing.T
+	listener net.Listener
+	topics   chan string
+	frames   chan []byte
+}
+
+func newPublisher(t *testing.T) *publisher {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	p := &publisher{t: t, listener: listener, topics: make(chan string, 10), frames: make(chan []byte, 10)}
+	go p.serve()
+	return p
+}
+
+func (p *publisher) addr() string {
+	return "tcp://" + p.listener.Addr().String()
+}
+
+func (p *publisher) serve() {
+	conn, err := p.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	greeting := make([]byte, 64)
+	if _, err := io.ReadFull(reader, greeting); err != nil {
+		return
+	}
+	greeting[11] = 1
+	if _, err := conn.Write(greeting); err != nil {
+		return
+	}
+	s := &Subscriber{conn: conn, reader: reader}
+	if _, _, err := s.readFrame(); err != nil {
+		return
+	}
+	if err := s.writeFrame(flagCommand, readyCommand("PUB")); err != nil {
+		return
+	}
+	go func() {
+		for frame := range p.frames {
+			if _, err := conn.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		_, body, err := s.readFrame()
+		if err != nil {
+			return
+		}
+		if len(body) > 0 && body[0] == 1 {
+			p.topics <- string(body[1:])
+		}
+	}
+}
+
+func (p *publisher) publish(topic string, body []byte, sequence uint32) {
+	var buf bytes.Buffer
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, sequence)
+	require.NoError(p.t, writeFrame(&buf, flagMore, []byte(topic)))
+	require.NoError(p.t, writeFrame(&buf, flagMore, body))
+	require.NoError(p.t, writeFrame(&buf, 0, seq))
+	p.frames <- buf.Bytes()
+}
+
+func TestSubscriber(t *testing.T) {
+	p := newPublisher(t)
+	sub, err := Dial(context.Background(), p.addr(), TopicHashBlock, TopicRawTx)
+	require.NoError(t, err)
+	defer sub.Close()
+	require.Equal(t, TopicHashBlock, <-p.topics)
+	require.Equal(t, TopicRawTx, <-p.topics)
+
+	hash := bytes.Repeat([]byte{0xab}, 32)
+	p.publish(TopicHashBlock, hash, 7)
+	// 命令帧会被跳过，超过 255 字节的消息使用长帧
+	p.frames <- []byte{flagCommand, 5, 4, 'P', 'I', 'N', 'G'}
+	rawTx := bytes.Repeat([]byte{0x01}, 300)
+	p.publish(TopicRawTx, rawTx, 8)
+
+	message, err := sub.Recv(time.Second)
+	require.NoError(t, err)
+	require.Equal(t, &Message{Topic: TopicHashBlock, Body: hash, Sequence: 7}, message)
+
+	message, err = sub.Recv(time.Second)
+	require.NoError(t, err)
+	require.Equal(t, &Message{Topic: TopicRawTx, Body: rawTx, Sequence: 8}, message)
+
+	// 没有消息时超时返回
+	_, err = sub.Recv(50 * time.Millisecond)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+}
+
+func TestSubscriberContextCancel(t *testing.T) {
+	p := newPublisher(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := Dial(ctx, p.addr(), TopicHashBlock)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	cancel()
+	_, err = sub.Recv(0)
+	require.Error(t, err)
+}
+
+func TestDialUnsupportedAddress(t *testing.T) {
+	_, err := Dial(context.Background(), "ipc:///tmp/bitcoind.sock", TopicHashBlock)
+	require.Error(t, err)
+}
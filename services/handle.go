@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"strconv"
@@ -10,10 +11,17 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/google/uuid"
 
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/coinselect"
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
 	"github.com/0xshin-chan/multichain-sync-btc/database"
 	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
 	dal_wallet_go "github.com/0xshin-chan/multichain-sync-btc/protobuf/dal-wallet-go"
+	"github.com/0xshin-chan/multichain-sync-btc/rbf"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+	"github.com/0xshin-chan/multichain-sync-btc/txfee"
+	"github.com/0xshin-chan/multichain-sync-btc/worker"
 )
 
 const (
@@ -27,13 +35,50 @@ func (s *BusinessMiddleWareService) BusinessRegister(ctx context.Context, reques
 			Msg:  "invalid params",
 		}, nil
 	}
-	business := &database.Business{
-		GUID:        uuid.New(),
-		BusinessUid: request.RequestId,
-		NotifyUrl:   request.NotifyUrl,
-		Timestamp:   uint64(time.Now().Unix()),
+	if _, err := coinselect.NewSelector(request.CoinSelection); err != nil {
+		return &dal_wallet_go.BusinessRegisterResponse{
+			Code: dal_wallet_go.ReturnCode_ERROR,
+			Msg:  "invalid coin selection strategy",
+		}, nil
+	}
+	cs, err := s.chainFor(request.Chain, "")
+	if err != nil {
+		return &dal_wallet_go.BusinessRegisterResponse{
+			Code: dal_wallet_go.ReturnCode_ERROR,
+			Msg:  err.Error(),
+		}, nil
 	}
-	err := s.db.Business.StoreBusiness(business)
+	// 从注册时该链已扫描的区块开始跟随实时扫块；指定了起始高度时从起始高度开始扫描，
+	// 早于已扫描区块时先补扫历史区块，数据库中还没有区块时同样按起始高度补扫
+	var syncHeight uint64
+	latestBlock, err := cs.db.Blocks.LatestBlocks()
+	if err != nil {
+		log.Error("query latest block fail", "err", err)
+		return &dal_wallet_go.BusinessRegisterResponse{
+			Code: dal_wallet_go.ReturnCode_ERROR,
+			Msg:  "query latest block fail",
+		}, nil
+	}
+	if latestBlock != nil {
+		syncHeight = latestBlock.Number.Uint64()
+	}
+	if request.StartHeight > 0 {
+		syncHeight = request.StartHeight - 1
+	}
+	business := &database.Business{
+		GUID:           uuid.New(),
+		BusinessUid:    request.RequestId,
+		Chain:          cs.syncClient.ChainName,
+		NotifyUrl:      request.NotifyUrl,
+		CoinSelection:  request.CoinSelection,
+		CpfpMaxFeeRate: request.CpfpMaxFeeRate,
+		StartHeight:    request.StartHeight,
+		SyncHeight:     syncHeight,
+		Timestamp:      uint64(time.Now().Unix()),
+	}
+	// 先建表再记录业务方，避免扫块在建表完成前处理该业务方
+	dynamic.CreateTableFromTemplate(request.RequestId, s.db)
+	err = s.db.Business.StoreBusiness(business)
 	if err != nil {
 		log.Error("store business fail", "err", err)
 		return &dal_wallet_go.BusinessRegisterResponse{
@@ -41,7 +86,6 @@ func (s *BusinessMiddleWareService) BusinessRegister(ctx context.Context, reques
 			Msg:  "store db fail",
 		}, nil
 	}
-	dynamic.CreateTableFromTemplate(request.RequestId, s.db)
 	return &dal_wallet_go.BusinessRegisterResponse{
 		Code: dal_wallet_go.ReturnCode_SUCCESS,
 		Msg:  "config business success",
@@ -54,8 +98,23 @@ func (s *BusinessMiddleWareService) ExportAddressesByPublicKeys(ctx context.Cont
 		dbAddresses  []database.Addresses
 		balances     []database.Balances
 	)
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		return &dal_wallet_go.ExportAddressesResponse{
+			Code: dal_wallet_go.ReturnCode_ERROR,
+			Msg:  err.Error(),
+		}, nil
+	}
 	for _, value := range request.PublicKeys {
-		address := s.syncClient.ExportAddressByPubKey(value.Format, value.PublicKey)
+		address := cs.syncClient.ExportAddressByPubKey(value.Format, value.PublicKey)
+		// 上游配置的网络与本服务不一致时会生成其他网络的地址
+		if err := cs.syncClient.Params.ValidateAddress(address); err != nil {
+			log.Error("exported address is invalid", "chain", cs.syncClient.ChainName, "network", cs.syncClient.Params.Network, "err", err)
+			return &dal_wallet_go.ExportAddressesResponse{
+				Code: dal_wallet_go.ReturnCode_ERROR,
+				Msg:  "export address fail",
+			}, nil
+		}
 		item := &dal_wallet_go.Address{
 			Type:    value.Type,
 			Address: address,
@@ -81,7 +140,7 @@ func (s *BusinessMiddleWareService) ExportAddressesByPublicKeys(ctx context.Cont
 
 		retAddresses = append(retAddresses, item)
 	}
-	err := s.db.Addresses.StoreAddresses(request.RequestId, dbAddresses)
+	err = s.db.Addresses.StoreAddresses(request.RequestId, dbAddresses)
 	if err != nil {
 		return &dal_wallet_go.ExportAddressesResponse{
 			Code: dal_wallet_go.ReturnCode_ERROR,
@@ -95,6 +154,10 @@ func (s *BusinessMiddleWareService) ExportAddressesByPublicKeys(ctx context.Cont
 			Msg:  "store balance to db fail",
 		}, nil
 	}
+	// 和扫块运行在同一进程时直接更新地址索引，否则由扫块在下一批区块前增量加载
+	if addressIndex := cache.GetAddressIndex(); addressIndex != nil {
+		addressIndex.Add(request.RequestId, dbAddresses)
+	}
 	return &dal_wallet_go.ExportAddressesResponse{
 		Code:      dal_wallet_go.ReturnCode_SUCCESS,
 		Msg:       "generate address success",
@@ -111,107 +174,207 @@ func (s *BusinessMiddleWareService) BuildUnSignTransaction(ctx context.Context,
 		resp.Msg = "consumer token is error"
 		return resp, nil
 	}
-
-	feeReq := &utxo.FeeRequest{
-		ConsumerToken: request.ConsumerToken,
-		Chain:         s.ChainName,
-		Network:       s.NetWork,
-		Coin:          s.CoinName,
-		RawTx:         "",
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
 	}
-	utxoFee, err := s.syncClient.BtcRpcClient.GetFee(ctx, feeReq)
+
+	feeRate, err := syncclient.EstimateFeeRate(cs.chainSource, cs.syncClient.Params)
 	if err != nil {
 		resp.Msg = "get fee fail"
 		return resp, nil
 	}
-	// 假设的费用
-	btcSt := utxoFee.FeeRate * 10e8 * 380
-	btcStStr := fmt.Sprintf("%f", btcSt)
-
-	// todo：预测模型实现
-	/*
-		根据是 taproot， 隔离见证或者legacy 的预估单个 input 和 output 字节数， 再根据 input 和 output 的数量做出总字节数，
-		再乘以单个字节数需要消耗聪的手续费，得到的就是这笔交易的手续费
-
-		如果铭文和符石，直接先进行一次预签名进行，铭文和符石，一个 witness， 一个 op-return， 不管是在哪个结构里面都是需要消耗手续费
-	*/
-
-	btcStBigIntFee, _ := new(big.Int).SetString(btcStStr, 10)
 
 	hotWalletInfo, err := s.db.Addresses.QueryHotWalletInfo(request.RequestId)
 	if err != nil {
 		log.Error("query hotWalletInfo fail", "err", err)
 		return nil, err
 	}
+	if hotWalletInfo == nil {
+		resp.Msg = "hot wallet not found"
+		return resp, nil
+	}
+	business, err := s.db.Business.QueryBusinessByUuid(request.RequestId)
+	if err != nil {
+		log.Error("query business fail", "err", err)
+		return nil, err
+	}
+	selector, err := coinselect.NewSelector(business.CoinSelection)
+	if err != nil {
+		log.Error("new coin selector fail", "err", err)
+		return nil, err
+	}
 
-	// todo:需要找到和提现交易匹配的热钱包地址的 vin
-	// -暴力的形式： 将所有 utxo 输入进去， 然后找零
-	// -将 utxo 进行排序， 选择和提现相近的交易放到 vin （可能导致 utxo 臃肿，需要通过合并 utxo 解决）
+	hotScriptType, err := cs.syncClient.Params.ScriptType(hotWalletInfo.Address)
+	if err != nil {
+		log.Error("unsupported hot wallet address", "err", err)
+		return nil, err
+	}
 
-	vinsList, err := s.db.Vins.QueryVinsByAddress(request.RequestId, hotWalletInfo.Address)
+	var (
+		utxoVouts   []*utxo.Vout
+		outputTypes []txfee.ScriptType
+		target      int64
+	)
+	for i, tx := range request.Txn {
+		amount, err := strconv.ParseInt(tx.Value, 10, 64)
+		if err != nil || amount <= 0 {
+			resp.Msg = "invalid withdraw value"
+			return resp, nil
+		}
+		outputType, err := cs.syncClient.Params.ScriptType(tx.To)
+		if err != nil {
+			resp.Msg = "invalid withdraw address"
+			return resp, nil
+		}
+		outputTypes = append(outputTypes, outputType)
+		voutItem := &utxo.Vout{
+			Address: tx.To,
+			Amount:  amount,
+			Index:   uint32(i),
+		}
+		utxoVouts = append(utxoVouts, voutItem)
+		target += amount
+	}
+
+	baseWeight, err := txfee.BaseWeight(hotScriptType, outputTypes)
+	if err != nil {
+		return nil, err
+	}
+	inputWeight, err := txfee.InputWeight(hotScriptType)
+	if err != nil {
+		return nil, err
+	}
+	changeWeight, err := txfee.OutputWeight(hotScriptType)
 	if err != nil {
-		log.Error("query vins fail", "err", err)
 		return nil, err
 	}
 
+	// 选币、锁定 utxo 和创建提现记录在同一个事务中完成，并发的提现不会选到同一批 utxo
+	txUuid := uuid.New()
+	var (
+		selection *coinselect.Selection
+		estimate  *txfee.Estimate
+	)
+	if err := s.db.Transaction(func(tx *database.DB) error {
+		vinsList, err := tx.Vins.QueryAvailableVins(request.RequestId, hotWalletInfo.Address)
+		if err != nil {
+			return err
+		}
+		vinGuids := make(map[string]uuid.UUID)
+		var utxos []coinselect.Utxo
+		for _, vin := range vinsList {
+			vinGuids[fmt.Sprintf("%s:%d", vin.TxId, vin.Vout)] = vin.GUID
+			utxos = append(utxos, coinselect.Utxo{
+				TxId:   vin.TxId,
+				Vout:   vin.Vout,
+				Amount: vin.Amount.Int64(),
+			})
+		}
+
+		selection, err = selector.Select(utxos, coinselect.Params{
+			Target:     target,
+			FeeRate:    feeRate,
+			BaseSize:   txfee.VSize(baseWeight),
+			InputSize:  txfee.VSize(inputWeight),
+			ChangeSize: txfee.VSize(changeWeight),
+			DustLimit:  cs.syncClient.Params.DustLimit,
+		})
+		if err != nil {
+			return err
+		}
+		// 选币时各部分分别向上取整，按实际输入、输出重新计算虚拟大小，多出的手续费退回找零
+		estimate, err = estimateSelectionFee(selection, hotScriptType, outputTypes, feeRate)
+		if err != nil {
+			return err
+		}
+
+		var lockGuids []uuid.UUID
+		for _, input := range selection.Inputs {
+			lockGuids = append(lockGuids, vinGuids[fmt.Sprintf("%s:%d", input.TxId, input.Vout)])
+		}
+		if err := tx.Vins.LockVins(request.RequestId, txUuid.String(), lockGuids); err != nil {
+			return err
+		}
+		// 记录提现输出，RBF 替换交易按相同的输出重新构建
+		var childTxs []database.ChildTxs
+		for _, vout := range utxoVouts {
+			childTxs = append(childTxs, database.ChildTxs{
+				GUID:        uuid.New(),
+				Hash:        "0x00",
+				TxId:        txUuid.String(),
+				TxIndex:     big.NewInt(int64(vout.Index)),
+				TxType:      "withdraw_vout",
+				FromAddress: hotWalletInfo.Address,
+				ToAddress:   vout.Address,
+				Amount:      strconv.FormatInt(vout.Amount, 10),
+				Timestamp:   uint64(time.Now().Unix()),
+			})
+		}
+		if err := tx.ChildTxs.StoreChildTxs(request.RequestId, childTxs); err != nil {
+			return err
+		}
+		withdraw := &database.Withdraws{
+			Guid:        txUuid,
+			BlockHash:   "0x00",
+			BlockNumber: big.NewInt(0),
+			Hash:        "0x00",
+			Fee:         big.NewInt(selection.Fee),
+			LockTime:    big.NewInt(0),
+			Version:     "0x00",
+			TxSignHex:   "0x00",
+			Status:      database.TxStatusWaitSign,
+			Timestamp:   uint64(time.Now().Unix()),
+		}
+		return tx.Withdraws.StoreWithdraws(request.RequestId, withdraw)
+	}); err != nil {
+		if errors.Is(err, coinselect.ErrInsufficientFunds) {
+			resp.Msg = "hot wallet balance not enough"
+			return resp, nil
+		}
+		log.Error("select and lock vins fail", "err", err)
+		return nil, err
+	}
+	log.Info("select coins success", "strategy", business.CoinSelection, "transactionId", txUuid, "inputs", len(selection.Inputs), "fee", selection.Fee, "change", selection.Change)
+
 	var utxoVins []*utxo.Vin
-	for _, vin := range vinsList {
+	for _, input := range selection.Inputs {
 		vinItem := &utxo.Vin{
-			Hash:    vin.TxId,
-			Index:   uint32(vin.Vout),
-			Amount:  vin.Amount.Int64(),
+			Hash:    input.TxId,
+			Index:   input.Vout,
+			Amount:  input.Amount,
 			Address: hotWalletInfo.Address,
 		}
 		utxoVins = append(utxoVins, vinItem)
 	}
-
-	var utxoVouts []*utxo.Vout
-	for _, tx := range request.Txn {
-		amount, _ := strconv.Atoi(tx.Value)
-		voutItem := &utxo.Vout{
-			Address: tx.To,
-			Amount:  int64(amount),
-			Index:   0,
-		}
-		utxoVouts = append(utxoVouts, voutItem)
+	// 找零回热钱包
+	if selection.Change > 0 {
+		utxoVouts = append(utxoVouts, &utxo.Vout{
+			Address: hotWalletInfo.Address,
+			Amount:  selection.Change,
+			Index:   uint32(len(utxoVouts)),
+		})
 	}
 
 	utr := &utxo.UnSignTransactionRequest{
 		ConsumerToken: request.ConsumerToken,
-		Chain:         s.ChainName,
-		Network:       s.NetWork,
-		Fee:           btcStStr,
+		Chain:         cs.syncClient.ChainName,
+		Network:       cs.syncClient.Params.Network,
+		Fee:           big.NewInt(selection.Fee).String(),
 		Vin:           utxoVins,
 		Vout:          utxoVouts,
 	}
 
 	// 构建 32 hash
-	txMessageHash, err := s.syncClient.BtcRpcClient.CreateUnSignTransaction(ctx, utr)
+	txMessageHash, err := cs.syncClient.BtcRpcClient.CreateUnSignTransaction(ctx, utr)
 	if err != nil {
 		log.Error("create unsign transaction fail", "err", err)
+		s.failWithdraw(request.RequestId, txUuid)
 		return nil, err
 	}
 	log.Info("create unsign transaction success", "txHash", txMessageHash)
 
-	// 构建 withdraw 表
-	txUuid := uuid.New()
-	withdraw := &database.Withdraws{
-		Guid:        txUuid,
-		BlockHash:   "0x00",
-		BlockNumber: big.NewInt(0),
-		Hash:        "0x00",
-		Fee:         btcStBigIntFee,
-		LockTime:    big.NewInt(0),
-		Version:     "0x00",
-		TxSignHex:   "0x00",
-		Status:      database.TxStatusWaitSign,
-		Timestamp:   uint64(time.Now().Unix()),
-	}
-
-	if err := s.db.Withdraws.StoreWithdraws(request.RequestId, withdraw); err != nil {
-		log.Error("store withdraws fail", "err", err)
-		return nil, err
-	}
 	resp.Code = dal_wallet_go.ReturnCode_SUCCESS
 	resp.Msg = "create unsign transaction success"
 
@@ -232,6 +395,9 @@ func (s *BusinessMiddleWareService) BuildUnSignTransaction(ctx context.Context,
 		TransactionUuid: txUuid.String(),
 		UnSignTx:        signHashStr,
 		TxData:          string(txMessageHash.TxData),
+		Fee:             big.NewInt(selection.Fee).String(),
+		Vsize:           uint64(estimate.VSize),
+		FeeRate:         uint64(estimate.FeeRate),
 	}
 	ReturnTxHashes = append(ReturnTxHashes, retTxHash)
 	resp.ReturnTxHashes = ReturnTxHashes
@@ -247,6 +413,11 @@ func (s *BusinessMiddleWareService) BuildSignedTransaction(ctx context.Context,
 		resp.Msg = "consumer token is error"
 		return resp, nil
 	}
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
 
 	var resultSignature [][]byte
 	var txData []byte
@@ -263,23 +434,33 @@ func (s *BusinessMiddleWareService) BuildSignedTransaction(ctx context.Context,
 		transactionId = SignTx.TransactionUuid
 	}
 
-	hotWalletInfo, err := s.db.Addresses.QueryHotWalletInfo(request.RequestId)
+	// CPFP 子交易记录在内部交易表中，花费的是用户地址上的充值输出
+	cpfpInternal, err := s.db.Internals.QueryInternalByGuid(request.RequestId, transactionId)
 	if err != nil {
-		log.Error("query hotWalletInfo fail", "err", err)
+		log.Error("query internal fail", "err", err)
+		return nil, err
+	}
+	isCpfp := cpfpInternal != nil && cpfpInternal.TxType == database.InternalTxTypeCpfp
+	signer, err := s.db.Addresses.QueryHotWalletInfo(request.RequestId)
+	if isCpfp {
+		signer, err = s.cpfpSigner(request.RequestId, transactionId)
+	}
+	if err != nil {
+		log.Error("query signer address fail", "err", err)
 		return nil, err
 	}
 	var publicKeys [][]byte
-	publicKeys = append(publicKeys, []byte(hotWalletInfo.PublicKey))
+	publicKeys = append(publicKeys, []byte(signer.PublicKey))
 
 	signedReq := &utxo.SignedTransactionRequest{
 		ConsumerToken: ConsumerToken,
-		Chain:         s.ChainName,
-		Network:       s.NetWork,
+		Chain:         cs.syncClient.ChainName,
+		Network:       cs.syncClient.Params.Network,
 		TxData:        txData,
 		Signatures:    resultSignature,
 		PublicKeys:    publicKeys,
 	}
-	completeTx, err := s.syncClient.BtcRpcClient.BuildSignedTransaction(ctx, signedReq)
+	completeTx, err := cs.syncClient.BtcRpcClient.BuildSignedTransaction(ctx, signedReq)
 	if err != nil {
 		log.Error("build signed transaction fail", "err", err)
 		return nil, err
@@ -293,9 +474,17 @@ func (s *BusinessMiddleWareService) BuildSignedTransaction(ctx context.Context,
 	}
 	retSignedTxn = append(retSignedTxn, retSign)
 
-	err = s.db.Withdraws.UpdateWithdrawByGuid(request.RequestId, transactionId, string(completeTx.SignedTxData))
+	if isCpfp {
+		err = s.db.Internals.UpdateInternalTx(request.RequestId, transactionId, string(completeTx.SignedTxData), database.TxStatusSigned)
+	} else {
+		err = s.db.Withdraws.UpdateWithdrawByGuid(request.RequestId, transactionId, string(completeTx.SignedTxData))
+	}
+	if errors.Is(err, database.ErrWithdrawNotSignable) {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
 	if err != nil {
-		log.Error("update withdraws fail", "err", err)
+		log.Error("update signed tx fail", "err", err)
 		return nil, err
 	}
 
@@ -314,6 +503,10 @@ func (s *BusinessMiddleWareService) SubmitWithdraw(ctx context.Context, request
 		resp.Msg = "consumer token is error"
 		return resp, nil
 	}
+	if _, err := s.chainFor(request.Chain, request.RequestId); err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
 
 	var childTxList []database.ChildTxs
 	var txId = uuid.New()
@@ -367,3 +560,336 @@ func (s *BusinessMiddleWareService) SubmitWithdraw(ctx context.Context, request
 	resp.Msg = "submit withdraw success"
 	return resp, nil
 }
+
+// BumpWithdrawFee 为卡住的提现构建花费相同输入、费率更高的替换交易，
+// 返回的待签名数据签名后同样通过 buildSignedTransaction 提交
+func (s *BusinessMiddleWareService) BumpWithdrawFee(ctx context.Context, request *dal_wallet_go.BumpWithdrawFeeRequest) (*dal_wallet_go.BumpWithdrawFeeResponse, error) {
+	resp := &dal_wallet_go.BumpWithdrawFeeResponse{
+		Code: dal_wallet_go.ReturnCode_ERROR,
+		Msg:  "bump withdraw fee fail",
+	}
+	if request.ConsumerToken != ConsumerToken {
+		resp.Msg = "consumer token is error"
+		return resp, nil
+	}
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
+
+	result, err := cs.bumper.BumpFee(request.RequestId, request.TransactionUuid, int64(request.FeeRate), database.TxStatusWaitSign)
+	if err != nil {
+		switch {
+		case errors.Is(err, rbf.ErrWithdrawNotFound), errors.Is(err, rbf.ErrWithdrawNotStuck),
+			errors.Is(err, rbf.ErrReplacementPending), errors.Is(err, rbf.ErrNoLockedVins),
+			errors.Is(err, rbf.ErrNoWithdrawVouts), errors.Is(err, rbf.ErrRbfNotSupported),
+			errors.Is(err, txfee.ErrReplacementFeeNotCovered):
+			resp.Msg = err.Error()
+			return resp, nil
+		}
+		log.Error("bump withdraw fee fail", "transactionId", request.TransactionUuid, "err", err)
+		return nil, err
+	}
+
+	resp.Code = dal_wallet_go.ReturnCode_SUCCESS
+	resp.Msg = "build replacement transaction success"
+	resp.ReplaceTransactionUuid = result.Withdraw.Guid.String()
+	resp.ReturnTxHashes = []*dal_wallet_go.ReturnTransactionHashes{
+		{
+			TransactionUuid: result.Withdraw.Guid.String(),
+			UnSignTx:        result.UnSignTx,
+			TxData:          result.TxData,
+			Fee:             big.NewInt(result.Estimate.Fee).String(),
+			Vsize:           uint64(result.Estimate.VSize),
+			FeeRate:         uint64(result.Estimate.FeeRate),
+		},
+	}
+	return resp, nil
+}
+
+// BuildCpfpTransaction 充值交易费率过低迟迟不确认时，构建花费该充值输出、转入热钱包的高费率子交易，
+// 带动父交易一起打包；子交易记录为 cpfp 类型的内部交易，签名后同样通过 buildSignedTransaction 提交
+func (s *BusinessMiddleWareService) BuildCpfpTransaction(ctx context.Context, request *dal_wallet_go.CpfpTransactionRequest) (*dal_wallet_go.CpfpTransactionResponse, error) {
+	resp := &dal_wallet_go.CpfpTransactionResponse{
+		Code: dal_wallet_go.ReturnCode_ERROR,
+		Msg:  "build cpfp transaction fail",
+	}
+	if request.ConsumerToken != ConsumerToken {
+		resp.Msg = "consumer token is error"
+		return resp, nil
+	}
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
+
+	business, err := s.db.Business.QueryBusinessByUuid(request.RequestId)
+	if err != nil {
+		log.Error("query business fail", "err", err)
+		return nil, err
+	}
+	if business.CpfpMaxFeeRate == 0 {
+		resp.Msg = "cpfp is disabled for business"
+		return resp, nil
+	}
+	maxFeeRate := int64(business.CpfpMaxFeeRate)
+	feeRate := int64(request.FeeRate)
+	if feeRate == 0 {
+		feeRate, err = syncclient.EstimateFeeRate(cs.chainSource, cs.syncClient.Params)
+		if err != nil {
+			resp.Msg = "get fee fail"
+			return resp, nil
+		}
+		if feeRate > maxFeeRate {
+			feeRate = maxFeeRate
+		}
+	}
+	if feeRate > maxFeeRate {
+		resp.Msg = "fee rate exceeds cpfp max fee rate"
+		return resp, nil
+	}
+
+	parentTx, err := cs.chainSource.GetTransactionByHash(request.TxHash)
+	if err != nil {
+		resp.Msg = "deposit transaction not found"
+		return resp, nil
+	}
+	if height, ok := new(big.Int).SetString(parentTx.Height, 10); ok && height.Sign() > 0 {
+		resp.Msg = "deposit transaction already confirmed"
+		return resp, nil
+	}
+	if int(request.Index) >= len(parentTx.Tos) || int(request.Index) >= len(parentTx.Values) {
+		resp.Msg = "invalid deposit output index"
+		return resp, nil
+	}
+	userAddress, err := s.db.Addresses.QueryAddressesByToAddress(request.RequestId, parentTx.Tos[request.Index].Address)
+	if err != nil || userAddress.AddressType != 0 {
+		resp.Msg = "deposit output is not a user address"
+		return resp, nil
+	}
+	amount, err := strconv.ParseInt(parentTx.Values[request.Index].Value, 10, 64)
+	if err != nil {
+		resp.Msg = "invalid deposit output value"
+		return resp, nil
+	}
+	parentFee, err := strconv.ParseInt(parentTx.Fee, 10, 64)
+	if err != nil {
+		resp.Msg = "invalid deposit transaction fee"
+		return resp, nil
+	}
+	parentVSize, err := txMessageVSize(cs.syncClient.Params, parentTx)
+	if err != nil {
+		log.Error("estimate deposit transaction size fail", "hash", request.TxHash, "err", err)
+		return nil, err
+	}
+
+	hotWalletInfo, err := s.db.Addresses.QueryHotWalletInfo(request.RequestId)
+	if err != nil {
+		log.Error("query hotWalletInfo fail", "err", err)
+		return nil, err
+	}
+	inputType, err := cs.syncClient.Params.ScriptType(userAddress.Address)
+	if err != nil {
+		return nil, err
+	}
+	outputType, err := cs.syncClient.Params.ScriptType(hotWalletInfo.Address)
+	if err != nil {
+		return nil, err
+	}
+	cpfp, err := txfee.EstimateCpfp(txfee.CpfpParams{
+		ParentFee:   parentFee,
+		ParentVSize: parentVSize,
+		InputType:   inputType,
+		InputAmount: amount,
+		OutputType:  outputType,
+		FeeRate:     feeRate,
+		DustLimit:   cs.syncClient.Params.DustLimit,
+	})
+	if err != nil {
+		if errors.Is(err, txfee.ErrCpfpFeeNotCovered) {
+			resp.Msg = "deposit amount can not cover cpfp fee"
+			return resp, nil
+		}
+		return nil, err
+	}
+
+	utr := &utxo.UnSignTransactionRequest{
+		ConsumerToken: request.ConsumerToken,
+		Chain:         cs.syncClient.ChainName,
+		Network:       cs.syncClient.Params.Network,
+		Fee:           big.NewInt(cpfp.Estimate.Fee).String(),
+		Vin: []*utxo.Vin{{
+			Hash:    request.TxHash,
+			Index:   request.Index,
+			Amount:  amount,
+			Address: userAddress.Address,
+		}},
+		Vout: []*utxo.Vout{{
+			Address: hotWalletInfo.Address,
+			Amount:  cpfp.Amount,
+			Index:   0,
+		}},
+	}
+	txMessageHash, err := cs.syncClient.BtcRpcClient.CreateUnSignTransaction(ctx, utr)
+	if err != nil {
+		log.Error("create cpfp unsign transaction fail", "err", err)
+		return nil, err
+	}
+
+	txUuid := uuid.New()
+	now := uint64(time.Now().Unix())
+	internal := &database.Internals{
+		Guid:        txUuid,
+		BlockHash:   "0x00",
+		BlockNumber: big.NewInt(0),
+		Hash:        "0x00",
+		Fee:         big.NewInt(cpfp.Estimate.Fee),
+		LockTime:    big.NewInt(0),
+		Version:     "0x00",
+		TxType:      database.InternalTxTypeCpfp,
+		TxSignHex:   "0x00",
+		Status:      database.TxStatusWaitSign,
+		Timestamp:   now,
+	}
+	// hash 记录被加速的充值交易，充值被丢弃或冲突时据此把子交易置为失败
+	childTx := database.ChildTxs{
+		GUID:        uuid.New(),
+		Hash:        request.TxHash,
+		TxId:        txUuid.String(),
+		TxIndex:     big.NewInt(int64(request.Index)),
+		TxType:      "cpfp_vin",
+		FromAddress: userAddress.Address,
+		ToAddress:   hotWalletInfo.Address,
+		Amount:      strconv.FormatInt(amount, 10),
+		Timestamp:   now,
+	}
+	if err := s.db.Transaction(func(tx *database.DB) error {
+		if err := tx.ChildTxs.StoreChildTxs(request.RequestId, []database.ChildTxs{childTx}); err != nil {
+			return err
+		}
+		return tx.Internals.StoreInternal(request.RequestId, internal)
+	}); err != nil {
+		log.Error("store cpfp internal fail", "err", err)
+		return nil, err
+	}
+	log.Info("build cpfp transaction success", "transactionId", txUuid, "parentHash", request.TxHash, "fee", cpfp.Estimate.Fee, "packageFeeRate", cpfp.PackageFeeRate)
+
+	var signHashStr string
+	for _, signHash := range txMessageHash.SignHashes {
+		signHashStr += string(signHash) + "|"
+	}
+	resp.Code = dal_wallet_go.ReturnCode_SUCCESS
+	resp.Msg = "build cpfp transaction success"
+	resp.PackageFeeRate = uint64(cpfp.PackageFeeRate)
+	resp.ReturnTxHashes = []*dal_wallet_go.ReturnTransactionHashes{
+		{
+			TransactionUuid: txUuid.String(),
+			UnSignTx:        signHashStr,
+			TxData:          string(txMessageHash.TxData),
+			Fee:             big.NewInt(cpfp.Estimate.Fee).String(),
+			Vsize:           uint64(cpfp.Estimate.VSize),
+			FeeRate:         uint64(cpfp.Estimate.FeeRate),
+		},
+	}
+	return resp, nil
+}
+
+// cpfpSigner CPFP 子交易由充值所在的用户地址签名
+func (s *BusinessMiddleWareService) cpfpSigner(requestId string, transactionId string) (*database.Addresses, error) {
+	childTxs, err := s.db.ChildTxs.QueryChildTxnByTxId(requestId, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, childTx := range childTxs {
+		if childTx.TxType == "cpfp_vin" {
+			return s.db.Addresses.QueryAddressesByToAddress(requestId, childTx.FromAddress)
+		}
+	}
+	return nil, fmt.Errorf("cpfp input not found: %s", transactionId)
+}
+
+// txMessageVSize 按输入、输出地址类型估算链上交易的虚拟大小
+func txMessageVSize(params *chaincfg.Params, tx *utxo.TxMessage) (int64, error) {
+	var inputs, outputs []txfee.ScriptType
+	for _, from := range tx.Froms {
+		scriptType, err := params.ScriptType(from.Address)
+		if err != nil {
+			return 0, err
+		}
+		inputs = append(inputs, scriptType)
+	}
+	for _, to := range tx.Tos {
+		scriptType, err := params.ScriptType(to.Address)
+		if err != nil {
+			return 0, err
+		}
+		outputs = append(outputs, scriptType)
+	}
+	weight, err := txfee.Weight(inputs, outputs)
+	if err != nil {
+		return 0, err
+	}
+	return txfee.VSize(weight), nil
+}
+
+// estimateSelectionFee 按选中的输入和实际输出计算手续费，有找零输出时把多预留的手续费退回找零
+func estimateSelectionFee(selection *coinselect.Selection, inputType txfee.ScriptType, outputTypes []txfee.ScriptType, feeRate int64) (*txfee.Estimate, error) {
+	inputTypes := make([]txfee.ScriptType, len(selection.Inputs))
+	for i := range inputTypes {
+		inputTypes[i] = inputType
+	}
+	if selection.Change > 0 {
+		outputTypes = append(outputTypes[:len(outputTypes):len(outputTypes)], inputType)
+	}
+	estimate, err := txfee.EstimateFee(inputTypes, outputTypes, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	if selection.Change > 0 && estimate.Fee < selection.Fee {
+		selection.Change += selection.Fee - estimate.Fee
+		selection.Fee = estimate.Fee
+	}
+	return estimate, nil
+}
+
+// failWithdraw 构建交易失败时把提现置为失败，并释放锁定的 utxo
+func (s *BusinessMiddleWareService) failWithdraw(requestId string, txUuid uuid.UUID) {
+	if err := s.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Withdraws.UpdateWithdrawStatusByGuids(requestId, database.TxStatusFail, []database.Withdraws{{Guid: txUuid}}); err != nil {
+			return err
+		}
+		return tx.Vins.UnlockVins(requestId, []string{txUuid.String()})
+	}); err != nil {
+		log.Error("release withdraw vins fail", "transactionId", txUuid, "err", err)
+	}
+}
+
+// RescanBlocks 重新处理指定区间的区块，用于地址补录或者漏扫之后补录充值
+func (s *BusinessMiddleWareService) RescanBlocks(ctx context.Context, request *dal_wallet_go.RescanBlocksRequest) (*dal_wallet_go.RescanBlocksResponse, error) {
+	resp := &dal_wallet_go.RescanBlocksResponse{
+		Code: dal_wallet_go.ReturnCode_ERROR,
+		Msg:  "rescan blocks fail",
+	}
+	if request.ConsumerToken != ConsumerToken {
+		resp.Msg = "consumer token is error"
+		return resp, nil
+	}
+	cs, err := s.chainFor(request.Chain, request.RequestId)
+	if err != nil {
+		resp.Msg = err.Error()
+		return resp, nil
+	}
+	if err := cs.rescanner.Rescan(request.RequestId, request.FromHeight, request.ToHeight); err != nil {
+		if errors.Is(err, worker.ErrRescanRange) {
+			resp.Msg = err.Error()
+			return resp, nil
+		}
+		log.Error("rescan blocks fail", "businessId", request.RequestId, "from", request.FromHeight, "to", request.ToHeight, "err", err)
+		return nil, err
+	}
+	resp.Code = dal_wallet_go.ReturnCode_SUCCESS
+	resp.Msg = "rescan blocks success"
+	return resp, nil
+}
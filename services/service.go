@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync/atomic"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/0xshin-chan/multichain-sync-btc/database"
 	dal_wallet_go "github.com/0xshin-chan/multichain-sync-btc/protobuf/dal-wallet-go"
+	"github.com/0xshin-chan/multichain-sync-btc/rbf"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/worker"
 )
 
 const MaxRecvMessageSize = 1024 * 1024 * 300
@@ -20,28 +23,87 @@ const MaxRecvMessageSize = 1024 * 1024 * 300
 type BusinessMiddleConfig struct {
 	GrpcHostName string
 	GrpcPort     int
-	ChainName    string
-	NetWork      string
-	CoinName     string
 }
 
+// ChainService 一条链的钱包客户端、查询费率的数据源和重扫任务，RbfMaxFeeRate 为手动加速提现允许的最高费率
+type ChainService struct {
+	SyncClient    *syncclient.WalletBtcAccountClient
+	ChainSource   syncclient.ChainSource
+	Rescanner     *worker.Rescanner
+	RbfMaxFeeRate int64
+}
+
+type chainService struct {
+	syncClient  *syncclient.WalletBtcAccountClient
+	chainSource syncclient.ChainSource
+	db          *database.DB
+	bumper      *rbf.Bumper
+	rescanner   *worker.Rescanner
+}
+
+// BusinessMiddleWareService 多条链共用同一套接口，请求按 chain 字段或业务方注册的链路由到对应链
 type BusinessMiddleWareService struct {
 	*BusinessMiddleConfig
-	syncClient *syncclient.WalletBtcAccountClient
-	db         *database.DB
-	stopped    atomic.Bool
+	db           *database.DB
+	chains       map[string]*chainService
+	defaultChain string
+	server       *grpc.Server
+	stopped      atomic.Bool
 }
 
-func NewBusinessMiddleWareService(db *database.DB, config *BusinessMiddleConfig, syncClient *syncclient.WalletBtcAccountClient) (*BusinessMiddleWareService, error) {
-	return &BusinessMiddleWareService{
+// NewBusinessMiddleWareService chains 中的第一条链为业务方注册时不指定链的默认链
+func NewBusinessMiddleWareService(db *database.DB, config *BusinessMiddleConfig, chains []ChainService) (*BusinessMiddleWareService, error) {
+	if len(chains) == 0 {
+		return nil, errors.New("at least one chain is required")
+	}
+	s := &BusinessMiddleWareService{
 		BusinessMiddleConfig: config,
-		syncClient:           syncClient,
 		db:                   db,
-	}, nil
+		chains:               make(map[string]*chainService),
+		defaultChain:         chains[0].SyncClient.ChainName,
+	}
+	for _, chain := range chains {
+		chainDB := db.WithChain(chain.SyncClient.ChainName)
+		s.chains[chain.SyncClient.ChainName] = &chainService{
+			syncClient:  chain.SyncClient,
+			chainSource: chain.ChainSource,
+			db:          chainDB,
+			bumper:      rbf.NewBumper(chainDB, chain.SyncClient.Params, chain.SyncClient, chain.ChainSource, chain.RbfMaxFeeRate),
+			rescanner:   chain.Rescanner,
+		}
+	}
+	return s, nil
+}
+
+// chainFor 返回请求对应的链，chain 为空时使用业务方注册的链，业务方为空时使用默认链；
+// 指定的链与业务方注册的链不一致时返回错误，避免用其他链的地址格式和节点处理业务方的交易
+func (s *BusinessMiddleWareService) chainFor(chain string, businessId string) (*chainService, error) {
+	if businessId != "" {
+		business, err := s.db.Business.QueryBusinessByUuid(businessId)
+		if err != nil {
+			return nil, fmt.Errorf("business %s not found", businessId)
+		}
+		if chain == "" {
+			chain = business.Chain
+		} else if chain != business.Chain {
+			return nil, fmt.Errorf("business %s is registered on %s, not %s", businessId, business.Chain, chain)
+		}
+	}
+	if chain == "" {
+		chain = s.defaultChain
+	}
+	cs, ok := s.chains[chain]
+	if !ok {
+		return nil, fmt.Errorf("chain %s is not supported", chain)
+	}
+	return cs, nil
 }
 
 func (s *BusinessMiddleWareService) Stop(ctx context.Context) error {
 	s.stopped.Store(true)
+	if s.server != nil {
+		s.server.GracefulStop()
+	}
 	return nil
 }
 
@@ -50,22 +112,26 @@ func (s *BusinessMiddleWareService) Stopped() bool {
 }
 
 func (s *BusinessMiddleWareService) Start(ctx context.Context) error {
-	go func(s *BusinessMiddleWareService) {
-		addr := fmt.Sprintf("%s:%d", s.GrpcHostName, s.GrpcPort)
-		log.Info("start rpc server", "addr", addr)
-		listener, err := net.Listen("tcp", addr)
-		if err != nil {
-			log.Error("failed to start tcp server", "err", err)
-		}
-		gs := grpc.NewServer(
-			grpc.MaxRecvMsgSize(MaxRecvMessageSize),
-			grpc.ChainUnaryInterceptor(nil),
-		)
-		reflection.Register(gs)
+	addr := fmt.Sprintf("%s:%d", s.GrpcHostName, s.GrpcPort)
+	log.Info("start rpc server", "addr", addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Error("failed to start tcp server", "err", err)
+		return err
+	}
+	gs := grpc.NewServer(
+		grpc.MaxRecvMsgSize(MaxRecvMessageSize),
+	)
+	reflection.Register(gs)
 
-		dal_wallet_go.RegisterBusinessMiddleWireServicesServer(gs, s)
+	dal_wallet_go.RegisterBusinessMiddleWireServicesServer(gs, s)
+	s.server = gs
 
-		log.Info("Grpc info", "port", s.GrpcPort, "addr", listener.Addr())
-	}(s)
+	log.Info("Grpc info", "port", s.GrpcPort, "addr", listener.Addr())
+	go func() {
+		if err := gs.Serve(listener); err != nil {
+			log.Error("grpc server stopped", "err", err)
+		}
+	}()
 	return nil
 }
@@ -0,0 +1,47 @@
+package txfee
+
+import "errors"
+
+var ErrCpfpFeeNotCovered = errors.New("deposit amount can not cover cpfp fee")
+
+// CpfpParams 构建 CPFP 子交易的参数：子交易花费父交易的一个输出，转出到一个地址
+type CpfpParams struct {
+	ParentFee   int64
+	ParentVSize int64
+	InputType   ScriptType
+	InputAmount int64
+	OutputType  ScriptType
+	FeeRate     int64
+	DustLimit   int64
+}
+
+// Cpfp 子交易手续费、输出金额和父子交易整体的费率
+type Cpfp struct {
+	Estimate       *Estimate
+	Amount         int64
+	PackageFeeRate int64
+}
+
+// EstimateCpfp 计算子交易需要支付的手续费，使父子交易整体费率达到 FeeRate，
+// 子交易自身的费率不低于增量转发费率
+func EstimateCpfp(p CpfpParams) (*Cpfp, error) {
+	estimate, err := EstimateFee([]ScriptType{p.InputType}, []ScriptType{p.OutputType}, p.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	fee := p.FeeRate*(p.ParentVSize+estimate.VSize) - p.ParentFee
+	if minFee := estimate.VSize * IncrementalRelayFeeRate; fee < minFee {
+		fee = minFee
+	}
+	amount := p.InputAmount - fee
+	if amount < p.DustLimit {
+		return nil, ErrCpfpFeeNotCovered
+	}
+	estimate.Fee = fee
+	estimate.FeeRate = fee / estimate.VSize
+	return &Cpfp{
+		Estimate:       estimate,
+		Amount:         amount,
+		PackageFeeRate: (p.ParentFee + fee) / (p.ParentVSize + estimate.VSize),
+	}, nil
+}
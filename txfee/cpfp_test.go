@@ -0,0 +1,49 @@
+package txfee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateCpfp(t *testing.T) {
+	// 父交易 141 vB 付了 141 聪，子交易 1 进 1 出 P2WPKH 为 110 vB，整体目标 10 聪/虚拟字节
+	cpfp, err := EstimateCpfp(CpfpParams{
+		ParentFee:   141,
+		ParentVSize: 141,
+		InputType:   P2WPKH,
+		InputAmount: 100_000,
+		OutputType:  P2WPKH,
+		FeeRate:     10,
+		DustLimit:   546,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(110), cpfp.Estimate.VSize)
+	require.Equal(t, int64(2510-141), cpfp.Estimate.Fee)
+	require.Equal(t, int64(100_000-2369), cpfp.Amount)
+	require.Equal(t, int64(10), cpfp.PackageFeeRate)
+
+	// 父交易费率已经足够时子交易按最低费率支付
+	cpfp, err = EstimateCpfp(CpfpParams{
+		ParentFee:   5000,
+		ParentVSize: 141,
+		InputType:   P2WPKH,
+		InputAmount: 100_000,
+		OutputType:  P2WPKH,
+		FeeRate:     10,
+		DustLimit:   546,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(110), cpfp.Estimate.Fee)
+
+	_, err = EstimateCpfp(CpfpParams{
+		ParentFee:   141,
+		ParentVSize: 141,
+		InputType:   P2WPKH,
+		InputAmount: 2000,
+		OutputType:  P2WPKH,
+		FeeRate:     10,
+		DustLimit:   546,
+	})
+	require.ErrorIs(t, err, ErrCpfpFeeNotCovered)
+}
@@ -0,0 +1,103 @@
+package txfee
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// version 4 字节 + locktime 4 字节 + 输入、输出数量各 1 字节
+	overheadWeight = 10 * 4
+	// segwit marker 和 flag，只计入见证数据
+	segwitMarkerWeight = 2
+)
+
+// Estimate 交易大小和手续费预估结果
+type Estimate struct {
+	Weight  int64
+	VSize   int64
+	FeeRate int64
+	Fee     int64
+}
+
+func InputWeight(t ScriptType) (int64, error) {
+	weight, ok := inputWeights[t]
+	if !ok {
+		return 0, fmt.Errorf("unsupported input script type: %s", t)
+	}
+	return weight, nil
+}
+
+func OutputWeight(t ScriptType) (int64, error) {
+	weight, ok := outputWeights[t]
+	if !ok {
+		return 0, fmt.Errorf("unsupported output script type: %s", t)
+	}
+	return weight, nil
+}
+
+// Weight 根据输入、输出的脚本类型计算交易重量
+func Weight(inputs []ScriptType, outputs []ScriptType) (int64, error) {
+	weight := int64(overheadWeight)
+	segwit := false
+	for _, input := range inputs {
+		inputWeight, err := InputWeight(input)
+		if err != nil {
+			return 0, err
+		}
+		weight += inputWeight
+		segwit = segwit || input.IsSegwit()
+	}
+	for _, output := range outputs {
+		outputWeight, err := OutputWeight(output)
+		if err != nil {
+			return 0, err
+		}
+		weight += outputWeight
+	}
+	if segwit {
+		weight += segwitMarkerWeight
+	}
+	return weight, nil
+}
+
+// BaseWeight 所有输入都是 inputType 时，交易除输入以外部分的重量，选币时使用
+func BaseWeight(inputType ScriptType, outputs []ScriptType) (int64, error) {
+	weight, err := Weight(nil, outputs)
+	if err != nil {
+		return 0, err
+	}
+	if inputType.IsSegwit() {
+		weight += segwitMarkerWeight
+	}
+	return weight, nil
+}
+
+// VSize 重量换算成虚拟字节，向上取整
+func VSize(weight int64) int64 {
+	return (weight + 3) / 4
+}
+
+// EstimateFee 按 聪/虚拟字节 的费率计算手续费
+func EstimateFee(inputs []ScriptType, outputs []ScriptType, feeRate int64) (*Estimate, error) {
+	weight, err := Weight(inputs, outputs)
+	if err != nil {
+		return nil, err
+	}
+	vsize := VSize(weight)
+	return &Estimate{
+		Weight:  weight,
+		VSize:   vsize,
+		FeeRate: feeRate,
+		Fee:     vsize * feeRate,
+	}, nil
+}
+
+// SatPerVByte 上游返回的费率单位为 BTC/kB，换算成 聪/虚拟字节，最低 1 聪/虚拟字节
+func SatPerVByte(feeRate float32) int64 {
+	satPerByte := int64(math.Ceil(float64(feeRate) * 1e8 / 1000))
+	if satPerByte < 1 {
+		return 1
+	}
+	return satPerByte
+}
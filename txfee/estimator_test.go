@@ -0,0 +1,24 @@
+package txfee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateFee(t *testing.T) {
+	// 1 个 P2WPKH 输入、2 个 P2WPKH 输出：40 + 272 + 2*124 + 2 = 562 WU，141 vB
+	estimate, err := EstimateFee([]ScriptType{P2WPKH}, []ScriptType{P2WPKH, P2WPKH}, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(562), estimate.Weight)
+	require.Equal(t, int64(141), estimate.VSize)
+	require.Equal(t, int64(1410), estimate.Fee)
+
+	// legacy 交易没有见证数据
+	estimate, err = EstimateFee([]ScriptType{P2PKH}, []ScriptType{P2PKH, P2PKH}, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(226), estimate.VSize)
+
+	_, err = EstimateFee([]ScriptType{"unknown"}, nil, 1)
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package txfee
+
+import "errors"
+
+// IncrementalRelayFeeRate 节点默认的增量转发费率（聪/虚拟字节），BIP125 替换交易至少要多付这部分手续费
+const IncrementalRelayFeeRate = 1
+
+var ErrReplacementFeeNotCovered = errors.New("inputs can not cover replacement fee")
+
+// ReplacementParams 构建 BIP125 替换交易的参数，输入与原交易完全相同；
+// Outputs 为不含找零的提现输出，金额单位为聪
+type ReplacementParams struct {
+	InputType     ScriptType
+	InputAmounts  []int64
+	OutputTypes   []ScriptType
+	OutputAmounts []int64
+	OriginalFee   int64
+	FeeRate       int64
+	DustLimit     int64
+}
+
+// Replacement 替换交易的手续费和找零，Change 为 0 表示没有找零输出
+type Replacement struct {
+	Estimate *Estimate
+	Change   int64
+}
+
+// EstimateReplacement 计算替换交易的手续费：费率不低于原交易费率加上增量费率，
+// 总手续费不低于原交易手续费加上替换交易大小对应的增量费用，手续费从找零中扣除，
+// 找零低于粉尘值时全部并入手续费
+func EstimateReplacement(p ReplacementParams) (*Replacement, error) {
+	var inputTotal, outputTotal int64
+	for _, amount := range p.InputAmounts {
+		inputTotal += amount
+	}
+	for _, amount := range p.OutputAmounts {
+		outputTotal += amount
+	}
+	inputTypes := make([]ScriptType, len(p.InputAmounts))
+	for i := range inputTypes {
+		inputTypes[i] = p.InputType
+	}
+	withChange := append(p.OutputTypes[:len(p.OutputTypes):len(p.OutputTypes)], p.InputType)
+
+	// 原交易有剩余说明带找零输出，据此还原原交易的费率
+	originalOutputs := p.OutputTypes
+	if inputTotal-outputTotal > p.OriginalFee {
+		originalOutputs = withChange
+	}
+	original, err := EstimateFee(inputTypes, originalOutputs, 0)
+	if err != nil {
+		return nil, err
+	}
+	feeRate := (p.OriginalFee+original.VSize-1)/original.VSize + IncrementalRelayFeeRate
+	if p.FeeRate > feeRate {
+		feeRate = p.FeeRate
+	}
+
+	estimate, err := replacementFee(inputTypes, withChange, feeRate, p.OriginalFee)
+	if err != nil {
+		return nil, err
+	}
+	if change := inputTotal - outputTotal - estimate.Fee; change >= p.DustLimit {
+		return &Replacement{Estimate: estimate, Change: change}, nil
+	}
+
+	estimate, err = replacementFee(inputTypes, p.OutputTypes, feeRate, p.OriginalFee)
+	if err != nil {
+		return nil, err
+	}
+	if inputTotal-outputTotal < estimate.Fee {
+		return nil, ErrReplacementFeeNotCovered
+	}
+	estimate.Fee = inputTotal - outputTotal
+	estimate.FeeRate = estimate.Fee / estimate.VSize
+	return &Replacement{Estimate: estimate}, nil
+}
+
+func replacementFee(inputs []ScriptType, outputs []ScriptType, feeRate int64, originalFee int64) (*Estimate, error) {
+	estimate, err := EstimateFee(inputs, outputs, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	if minFee := originalFee + estimate.VSize*IncrementalRelayFeeRate; estimate.Fee < minFee {
+		estimate.Fee = minFee
+	}
+	return estimate, nil
+}
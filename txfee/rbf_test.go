@@ -0,0 +1,59 @@
+package txfee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateReplacement(t *testing.T) {
+	// 原交易 1 个 P2WPKH 输入、1 个提现输出加找零，141 vB，费率 2
+	params := ReplacementParams{
+		InputType:     P2WPKH,
+		InputAmounts:  []int64{100_000},
+		OutputTypes:   []ScriptType{P2WPKH},
+		OutputAmounts: []int64{50_000},
+		OriginalFee:   282,
+		FeeRate:       10,
+		DustLimit:     546,
+	}
+	replacement, err := EstimateReplacement(params)
+	require.NoError(t, err)
+	require.Equal(t, int64(141), replacement.Estimate.VSize)
+	require.Equal(t, int64(1410), replacement.Estimate.Fee)
+	require.Equal(t, int64(100_000-50_000-1410), replacement.Change)
+
+	// 目标费率不高于原费率时至少加上增量费率
+	params.FeeRate = 1
+	replacement, err = EstimateReplacement(params)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), replacement.Estimate.FeeRate)
+	require.Equal(t, int64(423), replacement.Estimate.Fee)
+}
+
+func TestEstimateReplacementDropChange(t *testing.T) {
+	// 找零不足粉尘值时去掉找零输出，剩余金额全部作为手续费
+	replacement, err := EstimateReplacement(ReplacementParams{
+		InputType:     P2WPKH,
+		InputAmounts:  []int64{51_800},
+		OutputTypes:   []ScriptType{P2WPKH},
+		OutputAmounts: []int64{50_000},
+		OriginalFee:   282,
+		FeeRate:       10,
+		DustLimit:     546,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), replacement.Change)
+	require.Equal(t, int64(1800), replacement.Estimate.Fee)
+
+	_, err = EstimateReplacement(ReplacementParams{
+		InputType:     P2WPKH,
+		InputAmounts:  []int64{50_500},
+		OutputTypes:   []ScriptType{P2WPKH},
+		OutputAmounts: []int64{50_000},
+		OriginalFee:   282,
+		FeeRate:       10,
+		DustLimit:     546,
+	})
+	require.ErrorIs(t, err, ErrReplacementFeeNotCovered)
+}
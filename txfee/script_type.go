@@ -0,0 +1,35 @@
+package txfee
+
+type ScriptType string
+
+const (
+	P2PKH      ScriptType = "p2pkh"
+	P2SHP2WPKH ScriptType = "p2sh-p2wpkh"
+	P2WPKH     ScriptType = "p2wpkh"
+	P2WSH      ScriptType = "p2wsh"
+	P2TR       ScriptType = "p2tr"
+)
+
+// 各类型输入、输出的重量（weight unit），虚拟字节 = 重量 / 4
+// P2WSH 输入按 2-of-3 多签估算，P2TR 输入按 key path 花费估算
+var (
+	inputWeights = map[ScriptType]int64{
+		P2PKH:      148 * 4,
+		P2SHP2WPKH: 64*4 + 108,
+		P2WPKH:     41*4 + 108,
+		P2WSH:      41*4 + 254,
+		P2TR:       41*4 + 66,
+	}
+	outputWeights = map[ScriptType]int64{
+		P2PKH:      34 * 4,
+		P2SHP2WPKH: 32 * 4,
+		P2WPKH:     31 * 4,
+		P2WSH:      43 * 4,
+		P2TR:       43 * 4,
+	}
+)
+
+// IsSegwit 花费该类型的输入是否带见证数据
+func (t ScriptType) IsSegwit() bool {
+	return t != P2PKH
+}
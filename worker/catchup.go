@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+)
+
+// catchUp 为扫块进度落后于全局进度的业务方补扫历史区块，每个业务方每轮最多补扫 headerBufferSize 个区块，
+// 追上全局进度后在下一批区块中加入实时扫块
+func (syncer *BaseSynchronizer) catchUp() error {
+	latestBlock, err := syncer.database.Blocks.LatestBlocks()
+	if err != nil {
+		return err
+	}
+	if latestBlock == nil {
+		return nil
+	}
+	tip := latestBlock.Number.Uint64()
+	businessList, err := syncer.database.Business.QueryBusinessList()
+	if err != nil {
+		log.Error("failed to fetch business list", "err", err)
+		return err
+	}
+	for _, business := range businessList {
+		if !business.CatchingUp(tip) {
+			continue
+		}
+		if err := syncer.catchUpBusiness(business, tip); err != nil {
+			log.Error("failed to catch up business", "businessId", business.BusinessUid, "syncHeight", business.SyncHeight, "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (syncer *BaseSynchronizer) catchUpBusiness(business database.Business, tip uint64) error {
+	start := business.SyncHeight + 1
+	end := start + syncer.headerBufferSize - 1
+	if end > tip {
+		end = tip
+	}
+	headers, err := syncer.blockBatch.HeadersByRange(start, end)
+	if err != nil {
+		return err
+	}
+	blocks, err := syncer.blockBatch.Blocks(headers)
+	if err != nil {
+		return err
+	}
+	if err := syncer.addressIndex.Refresh(business.BusinessUid); err != nil {
+		return err
+	}
+	var transactions []*Transaction
+	for i := range headers {
+		businessTransactions, err := syncer.classifyTransactions(business.BusinessUid, headers[i], blocks[i])
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, businessTransactions...)
+	}
+	log.Info("catch up business", "businessId", business.BusinessUid, "start", start, "end", end, "tip", tip, "txn", len(transactions))
+	if len(transactions) > 0 {
+		// 确认数按全局进度计算
+		syncer.sendBatch(map[string]*TransactionsChannel{
+			business.BusinessUid: {
+				BlockHeight:  tip,
+				Transactions: transactions,
+			},
+		})
+	}
+	return syncer.database.Business.UpdateBusinessSyncHeight([]string{business.BusinessUid}, end)
+}
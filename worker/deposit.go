@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
 	"github.com/0xshin-chan/multichain-sync-btc/common/retry"
 	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
 	"github.com/0xshin-chan/multichain-sync-btc/config"
@@ -11,21 +12,24 @@ import (
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Deposit struct {
 	BaseSynchronizer
-	confirms       uint8
+	confirms       uint16
 	latestHeader   syncclient.BlockHeader
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
 	tasks          tasks.Group
 }
 
-func NewDeposit(cfg config.Config, db *database.DB, rpcClient *syncclient.WalletBtcAccountClient, shutdown context.CancelCauseFunc) (*Deposit, error) {
+// NewDeposit newBlocks 收到信号时立即扫块，为 nil 时只按 SynchronizerInterval 轮询
+func NewDeposit(cfg config.Config, db *database.DB, rpcClient syncclient.ChainSource, newBlocks <-chan struct{}, shutdown context.CancelCauseFunc) (*Deposit, error) {
 	dbLatestBlockHeader, err := db.Blocks.LatestBlocks()
 	if err != nil {
 		log.Error("get latest block from database fail")
@@ -52,21 +56,38 @@ func NewDeposit(cfg config.Config, db *database.DB, rpcClient *syncclient.Wallet
 		fromHeader = chainLatestBlockHeader
 	}
 
+	// 启动时加载所有业务方的地址索引
+	addressIndex := cache.InitAddressIndex(db)
+	businessList, err := db.Business.QueryBusinessList()
+	if err != nil {
+		log.Error("query business list fail", "err", err)
+		return nil, err
+	}
+	for _, business := range businessList {
+		if err := addressIndex.Load(business.BusinessUid); err != nil {
+			log.Error("load address index fail", "businessId", business.BusinessUid, "err", err)
+			return nil, err
+		}
+	}
+
 	businessTxChannel := make(chan map[string]*TransactionsChannel)
 	baseSyncer := BaseSynchronizer{
 		loopInterval:     cfg.ChainNode.SynchronizerInterval,
+		trigger:          newBlocks,
 		headerBufferSize: cfg.ChainNode.BlocksStep,
 		businessChannels: businessTxChannel,
+		inFlight:         &sync.WaitGroup{},
 		rpcClient:        rpcClient,
-		blockBatch:       syncclient.NewBatchBlock(rpcClient, fromHeader, big.NewInt(int64(cfg.ChainNode.Confirmations))),
+		blockBatch:       syncclient.NewBatchBlock(rpcClient, fromHeader, big.NewInt(int64(cfg.ChainNode.Confirmations)), cfg.ChainNode.FetchParallelism),
 		database:         db,
+		addressIndex:     addressIndex,
 	}
 
 	resCtx, resCancel := context.WithCancel(context.Background())
 
 	return &Deposit{
 		BaseSynchronizer: baseSyncer,
-		confirms:         uint8(cfg.ChainNode.Confirmations),
+		confirms:         uint16(cfg.ChainNode.Confirmations),
 		resourceCtx:      resCtx,
 		resourceCancel:   resCancel,
 		tasks: tasks.Group{HandleCrit: func(err error) {
@@ -96,7 +117,9 @@ func (d *Deposit) Start() error {
 		log.Info("handle deposit task start")
 		for batch := range d.businessChannels {
 			log.Info("deposit business channel", "batch length", len(batch))
-			if err := d.handleBatch(batch); err != nil {
+			err := d.handleBatch(batch)
+			d.batchDone()
+			if err != nil {
 				log.Error("handle batch fail", "err", err)
 				return fmt.Errorf("failed to handle batch, stopping L2 Synchronizer: %w", err)
 			}
@@ -129,7 +152,8 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 			internalListChildTxFlowList []database.ChildTxs
 			vins                        []database.Vins
 			vouts                       []database.Vouts
-			balances                    []database.TokenBalance
+			txHashes                    []string
+			txBalances                  = make(map[string][]database.TokenBalance)
 		)
 
 		log.Info(
@@ -137,15 +161,7 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 			"chanLatestBlock", batch[business.BusinessUid].BlockHeight,
 			"txn", len(batch[business.BusinessUid].Transactions),
 		)
-		var pvList []*PrepareVoutList
 		for _, tx := range batch[business.BusinessUid].Transactions {
-			txItem, err := d.rpcClient.GetTransactionByHash(tx.Hash)
-			if err != nil {
-				log.Error("get transaction by hash", "err", err)
-				return err
-			}
-
-			log.Info("get transaction success", "txHash", txItem.Hash)
 			txFlow, txFlowChildTxs, err := d.HandleTransaction(tx)
 			if err != nil {
 				log.Error("handle transaction", "err", err)
@@ -155,18 +171,20 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 			txFlowChildTxs = append(txFlowChildTxs, txFlowChildTxs...)
 			vintListPre, vinBalances, err := d.HandleVin(tx)
 			if err != nil {
-				log.Error("handle vout fail", "err", err)
+				log.Error("handle vin fail", "err", err)
+				return err
 			}
 			vins = append(vins, vintListPre...)
-			balances = append(balances, vinBalances...)
+			txHashes = append(txHashes, tx.Hash)
+			txBalances[tx.Hash] = append(txBalances[tx.Hash], vinBalances...)
 
 			voutListPre, voutBalances, err := d.HandleVout(tx, business.BusinessUid)
 			if err != nil {
 				log.Error("handle vout fail", "err", err)
+				return err
 			}
-			balances = append(balances, voutBalances...)
+			txBalances[tx.Hash] = append(txBalances[tx.Hash], voutBalances...)
 
-			pvList = append(pvList, voutListPre)
 			vlist := voutListPre.VoutList
 			vouts = append(vouts, vlist...)
 
@@ -193,15 +211,72 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 		retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
 		if _, err := retry.Do[interface{}](d.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
 			if err := d.database.Transaction(func(tx *database.DB) error {
+				// 重扫与实时扫块可能同时处理同一业务方，加锁后再检查已记录的交易，避免重复计入余额
+				if err := tx.Business.LockBusiness(business.BusinessUid); err != nil {
+					return err
+				}
+				// 同一区块重复处理时已经记录过的交易不再重复计入余额，之前没有识别出类型的交易除外
+				recordedTypes, err := tx.Transactions.QueryTransactionTypes(business.BusinessUid, txHashes)
+				if err != nil {
+					return err
+				}
+				remined := make(map[string]bool)
 				if len(depositList) > 0 {
 					log.Info("Store deposit transaction success", "totalTx", len(depositList))
-					if err := tx.Deposits.StoreDeposits(business.BusinessUid, depositList); err != nil {
+					// 重组后在新区块中重新上链、还没有回滚的充值余额仍然有效，只更新所在区块
+					remined, err = tx.Deposits.UpdateOrphanedDepositsMined(business.BusinessUid, depositList)
+					if err != nil {
 						return err
 					}
-
-					if err := tx.ChildTxs.StoreChildTxs(business.BusinessUid, depositListChildTxFlowList); err != nil {
+					var minedDeposits []database.Deposits
+					for _, deposit := range depositList {
+						if !remined[deposit.Hash] {
+							minedDeposits = append(minedDeposits, deposit)
+						}
+					}
+					// 内存池中已经记录过的充值直接更新为上链状态
+					newDeposits, err := tx.Deposits.UpdatePendingDepositsMined(business.BusinessUid, minedDeposits)
+					if err != nil {
+						return err
+					}
+					if len(newDeposits) > 0 {
+						if err := tx.Deposits.StoreDeposits(business.BusinessUid, newDeposits); err != nil {
+							return err
+						}
+					}
+					// 内存池中记录的输出以区块中的交易为准
+					if err := tx.ChildTxs.ReplaceChildTxs(business.BusinessUid, depositListChildTxFlowList); err != nil {
+						return err
+					}
+				}
+				// 提现和内部交易同样先处理重组后重新上链、还没有回滚的记录，它们的余额仍然有效
+				if len(withdrawList) > 0 {
+					reminedWithdraws, err := tx.Withdraws.UpdateOrphanedWithdrawsMined(business.BusinessUid, withdrawList)
+					if err != nil {
+						return err
+					}
+					for hash := range reminedWithdraws {
+						remined[hash] = true
+					}
+				}
+				if len(internalList) > 0 {
+					reminedInternals, err := tx.Internals.UpdateOrphanedInternalsMined(business.BusinessUid, internalList)
+					if err != nil {
 						return err
 					}
+					for hash := range reminedInternals {
+						remined[hash] = true
+					}
+				}
+				var balances []database.TokenBalance
+				for _, hash := range txHashes {
+					if txType, ok := recordedTypes[hash]; ok && txType != "unknown" {
+						continue
+					}
+					if remined[hash] {
+						continue
+					}
+					balances = append(balances, txBalances[hash]...)
 				}
 				if err := tx.Deposits.UpdateDepositsComfirms(business.BusinessUid, batch[business.BusinessUid].BlockHeight, uint64(d.confirms)); err != nil {
 					log.Info("Handle confims fail", "totalTx", "err", err)
@@ -214,18 +289,26 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 					}
 				}
 				if len(withdrawList) > 0 {
-					if err := tx.Withdraws.UpdateWithdrawStatus(business.BusinessUid, database.TxStatusWithdrawed, withdrawList); err != nil {
+					if err := tx.Withdraws.UpdateWithdrawsOnChain(business.BusinessUid, withdrawList); err != nil {
 						return err
 					}
-					if err := tx.ChildTxs.StoreChildTxs(business.BusinessUid, withdrawListChildTxFlowList); err != nil {
+					for _, withdraw := range withdrawList {
+						if err := tx.Vins.SpendLockedVins(business.BusinessUid, withdraw.Hash, withdraw.BlockNumber); err != nil {
+							return err
+						}
+						if err := tx.Withdraws.UpdateWithdrawsReplaced(business.BusinessUid, withdraw.Hash); err != nil {
+							return err
+						}
+					}
+					if err := tx.ChildTxs.ReplaceChildTxs(business.BusinessUid, withdrawListChildTxFlowList); err != nil {
 						return err
 					}
 				}
 				if len(internalList) > 0 {
-					if err := tx.Internals.UpdateInternalStatus(business.BusinessUid, database.TxStatusSuccess, internalList); err != nil {
+					if err := tx.Internals.UpdateInternalsOnChain(business.BusinessUid, internalList); err != nil {
 						return err
 					}
-					if err := tx.ChildTxs.StoreChildTxs(business.BusinessUid, internalListChildTxFlowList); err != nil {
+					if err := tx.ChildTxs.ReplaceChildTxs(business.BusinessUid, internalListChildTxFlowList); err != nil {
 						return err
 					}
 				}
@@ -233,7 +316,7 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 					if err := tx.Transactions.StoreTransactions(business.BusinessUid, transactionFlowList); err != nil {
 						return err
 					}
-					if err := tx.ChildTxs.StoreChildTxs(business.BusinessUid, transactionChildTxFlowList); err != nil {
+					if err := tx.ChildTxs.ReplaceChildTxs(business.BusinessUid, transactionChildTxFlowList); err != nil {
 						return err
 					}
 				}
@@ -243,17 +326,16 @@ func (d *Deposit) handleBatch(batch map[string]*TransactionsChannel) error {
 					}
 				}
 				if len(vouts) > 0 {
-					if err := tx.Vouts.StoreVouts(business.BusinessUid, vouts); err != nil {
+					if err := tx.Vouts.ReplaceVouts(business.BusinessUid, vouts); err != nil {
 						return err
 					}
 				}
 
-				if len(pvList) > 0 {
-					for _, pvItem := range pvList {
-						for _, voutItmepv := range pvItem.VoutList {
-							if err := tx.Vins.UpdateVinsTx(business.BusinessUid, pvItem.TxId, voutItmepv.Address, true, pvItem.TxId, pvItem.BlockNumber); err != nil {
-								return err
-							}
+				// 标记本批交易花费掉的 utxo
+				for _, spendTx := range batch[business.BusinessUid].Transactions {
+					for _, vin := range spendTx.VinList {
+						if err := tx.Vins.UpdateVinsTx(business.BusinessUid, vin.TxId, vin.Vout, true, spendTx.Hash, spendTx.BlockNumber); err != nil {
+							return err
 						}
 					}
 				}
@@ -305,7 +387,7 @@ func (d *Deposit) HandleTransaction(tx *Transaction) (database.Transactions, []d
 	}
 	transactionTx := database.Transactions{
 		GUID:        uuid.New(),
-		BlockHash:   "",
+		BlockHash:   tx.BlockHash,
 		BlockNumber: tx.BlockNumber,
 		Hash:        tx.Hash,
 		Fee:         txFee,
@@ -355,6 +437,7 @@ func (d *Deposit) HandleVout(tx *Transaction, business string) (*PrepareVoutList
 	for _, vin := range tx.VinList {
 		vout := database.Vouts{
 			GUID:      uuid.New(),
+			TxId:      tx.Hash,
 			Address:   vin.Address,
 			N:         vin.Vout,
 			Amount:    vin.Amount,
@@ -362,20 +445,33 @@ func (d *Deposit) HandleVout(tx *Transaction, business string) (*PrepareVoutList
 		}
 		voutList = append(voutList, vout)
 		if tx.TxType == "withdraw" || tx.TxType == "collection" || tx.TxType == "hot2cold" || tx.TxType == "cold2hot" {
-			vinAddressess := strings.Split(vin.Address, "|")
-			for _, addr := range vinAddressess {
-				vinDetail, err := d.database.Vins.QueryVinByTxId(business, addr, tx.Hash)
-				if err != nil {
-					log.Error("query vins fail", "err", err)
-				}
-				balanceItem := database.TokenBalance{
-					FromAddress:  addr,
+			vinDetail, err := d.database.Vins.QueryVinByOutpoint(business, vin.TxId, vin.Vout)
+			if err == nil {
+				balanceList = append(balanceList, database.TokenBalance{
+					FromAddress:  vinDetail.Address,
 					ToAddress:    "",
 					TokenAddress: "",
 					Balance:      vinDetail.Amount,
 					TxType:       tx.TxType,
+				})
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Error("query vins fail", "txId", vin.TxId, "vout", vin.Vout, "err", err)
+				return nil, nil, err
+			}
+			// 被花费的输出早于扫块起点或在同一批次中产生，按节点返回的输入金额扣减
+			for _, addr := range strings.Split(vin.Address, "|") {
+				if addr == "" {
+					continue
 				}
-				balanceList = append(balanceList, balanceItem)
+				balanceList = append(balanceList, database.TokenBalance{
+					FromAddress:  addr,
+					ToAddress:    "",
+					TokenAddress: "",
+					Balance:      vin.Amount,
+					TxType:       tx.TxType,
+				})
 			}
 		}
 	}
@@ -404,7 +500,7 @@ func (deposit *Deposit) HandleDeposit(tx *Transaction) (database.Deposits, []dat
 	txFee, _ := new(big.Int).SetString(tx.TxFee, 10)
 	depositTx := database.Deposits{
 		GUID:        uuid.New(),
-		BlockHash:   "",
+		BlockHash:   tx.BlockHash,
 		BlockNumber: tx.BlockNumber,
 		Hash:        tx.Hash,
 		Fee:         txFee,
@@ -432,7 +528,7 @@ func (deposit *Deposit) HandleWithdraw(tx *Transaction) (database.Withdraws, []d
 	}
 	withdrawTx := database.Withdraws{
 		Guid:        uuid.New(),
-		BlockHash:   "",
+		BlockHash:   tx.BlockHash,
 		BlockNumber: tx.BlockNumber,
 		Hash:        tx.Hash,
 		Fee:         txFee,
@@ -532,9 +628,10 @@ func (deposit *Deposit) HandleInternalTx(tx *Transaction) (database.Internals, [
 	}
 	internalTx := database.Internals{
 		Guid:        uuid.New(),
-		BlockHash:   "",
+		BlockHash:   tx.BlockHash,
 		BlockNumber: tx.BlockNumber,
 		Hash:        tx.Hash,
+		TxType:      tx.TxType,
 		Status:      database.TxStatusSuccess,
 		Fee:         txFee,
 		Timestamp:   uint64(time.Now().Unix()),
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
+)
+
+// testDB 连接 MULTICHAIN_SYNC_TEST_DB_* 指定的 postgres 并执行迁移，没有配置时跳过
+func testDB(t *testing.T) *database.DB {
+	host := os.Getenv("MULTICHAIN_SYNC_TEST_DB_HOST")
+	if host == "" {
+		t.Skip("MULTICHAIN_SYNC_TEST_DB_HOST not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("MULTICHAIN_SYNC_TEST_DB_PORT"))
+	db, err := database.NewDB(context.Background(), config.DBConfig{
+		Host:     host,
+		Port:     port,
+		Name:     os.Getenv("MULTICHAIN_SYNC_TEST_DB_NAME"),
+		User:     os.Getenv("MULTICHAIN_SYNC_TEST_DB_USER"),
+		Password: os.Getenv("MULTICHAIN_SYNC_TEST_DB_PASSWORD"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.ExecuteSQLMigration("../migrations"))
+	return db
+}
+
+type replaySnapshot struct {
+	deposits []database.Deposits
+	balances []database.Balances
+	vins     []database.Vins
+	childTxs []string
+}
+
+func takeReplaySnapshot(t *testing.T, db *database.DB, businessId string, addresses []string, hashes []string) replaySnapshot {
+	var snapshot replaySnapshot
+	deposits, err := db.Deposits.QueryDepositsByStatus(businessId, database.TxStatusUnSafe)
+	require.NoError(t, err)
+	snapshot.deposits = deposits
+	for _, address := range addresses {
+		balance, err := db.Balances.QueryWalletBalanceByAddress(businessId, 0, address)
+		require.NoError(t, err)
+		snapshot.balances = append(snapshot.balances, *balance)
+		vins, err := db.Vins.QueryVinsByAddress(businessId, address)
+		require.NoError(t, err)
+		sort.Slice(vins, func(i, j int) bool { return vins[i].Vout < vins[j].Vout })
+		snapshot.vins = append(snapshot.vins, vins...)
+	}
+	childTxs, err := db.ChildTxs.QueryChildTxnByHashes(businessId, hashes, []string{"deposit", "hot_input", "user_output"})
+	require.NoError(t, err)
+	// 重新扫块时子交易会替换成新的记录，只比较内容
+	for _, childTx := range childTxs {
+		snapshot.childTxs = append(snapshot.childTxs, fmt.Sprintf("%s-%s-%s-%s-%s-%s",
+			childTx.Hash, childTx.TxIndex, childTx.TxType, childTx.FromAddress, childTx.ToAddress, childTx.Amount))
+	}
+	sort.Strings(snapshot.childTxs)
+	return snapshot
+}
+
+func TestHandleBatchReplay(t *testing.T) {
+	db := testDB(t)
+	businessId := strings.ReplaceAll(uuid.New().String(), "-", "")
+	dynamic.CreateTableFromTemplate(businessId, db)
+	require.NoError(t, db.Business.StoreBusiness(&database.Business{
+		GUID:        uuid.New(),
+		BusinessUid: businessId,
+		Chain:       "Bitcoin",
+		Timestamp:   uint64(time.Now().Unix()),
+	}))
+
+	deposit := &Deposit{
+		BaseSynchronizer: BaseSynchronizer{
+			database: db,
+		},
+		confirms:    6,
+		resourceCtx: context.Background(),
+	}
+
+	userAddress, hotAddress := "user-"+businessId, "hot-"+businessId
+	blockNumber := big.NewInt(100)
+	// 充值交易的第 0 和第 256 个输出都转入用户地址，归集交易花费其中第 256 个输出
+	depositTx := &Transaction{
+		BusinessId:  businessId,
+		BlockHash:   "block-" + businessId,
+		BlockNumber: blockNumber,
+		Hash:        "deposit-" + businessId,
+		TxFee:       "100",
+		TxType:      "deposit",
+		VinList:     []Vin{{Address: "external", TxId: "prev-" + businessId, Vout: 1, Amount: big.NewInt(3100)}},
+		VoutList: []Vout{
+			{Address: userAddress, TxIndex: 0, Amount: big.NewInt(1000)},
+			{Address: userAddress, TxIndex: 256, Amount: big.NewInt(2000)},
+		},
+	}
+	collectionTx := &Transaction{
+		BusinessId:  businessId,
+		BlockHash:   "block-" + businessId,
+		BlockNumber: blockNumber,
+		Hash:        "collection-" + businessId,
+		TxFee:       "100",
+		TxType:      "collection",
+		VinList:     []Vin{{Address: userAddress, TxId: depositTx.Hash, Vout: 256, Amount: big.NewInt(2000)}},
+		VoutList:    []Vout{{Address: hotAddress, TxIndex: 0, Amount: big.NewInt(1900)}},
+	}
+	batch := map[string]*TransactionsChannel{
+		businessId: {
+			BlockHeight:  blockNumber.Uint64(),
+			Transactions: []*Transaction{depositTx, collectionTx},
+		},
+	}
+	addresses := []string{userAddress, hotAddress}
+	hashes := []string{depositTx.Hash, collectionTx.Hash}
+
+	require.NoError(t, deposit.handleBatch(batch))
+	first := takeReplaySnapshot(t, db, businessId, addresses, hashes)
+	require.Len(t, first.deposits, 1)
+	require.Equal(t, "1000", first.balances[0].Balance.String())
+	require.Equal(t, "1900", first.balances[1].Balance.String())
+	require.Len(t, first.vins, 3)
+	require.Equal(t, uint32(256), first.vins[1].Vout)
+	require.True(t, first.vins[1].IsSpend)
+	require.Equal(t, collectionTx.Hash, first.vins[1].SpendTxHash)
+
+	require.NoError(t, deposit.handleBatch(batch))
+	second := takeReplaySnapshot(t, db, businessId, addresses, hashes)
+	require.Equal(t, first, second)
+}
@@ -2,18 +2,22 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/retry"
 	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
 	"github.com/0xshin-chan/multichain-sync-btc/config"
 	"github.com/0xshin-chan/multichain-sync-btc/database"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
-	"github.com/cockroachdb/errors"
-	"github.com/ethereum/go-ethereum/log"
-	"time"
 )
 
 type FallBack struct {
-	rpcClient      *syncclient.WalletBtcAccountClient
+	rpcClient      syncclient.ChainSource
 	db             *database.DB
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
@@ -21,7 +25,7 @@ type FallBack struct {
 	ticker         *time.Ticker
 }
 
-func NewFallBack(cfg *config.Config, db *database.DB, rpcClient *syncclient.WalletBtcAccountClient, shutdown context.CancelCauseFunc) (*FallBack, error) {
+func NewFallBack(cfg *config.Config, db *database.DB, rpcClient syncclient.ChainSource, shutdown context.CancelCauseFunc) (*FallBack, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &FallBack{
 		rpcClient:      rpcClient,
@@ -55,6 +59,17 @@ func (f *FallBack) Start() error {
 			select {
 			case <-f.ticker.C:
 				log.Info("fallback process start")
+				businessList, err := f.db.Business.QueryBusinessList()
+				if err != nil {
+					log.Error("query business list fail", "err", err)
+					continue
+				}
+				for _, business := range businessList {
+					if err := f.handleFallback(business.BusinessUid); err != nil {
+						log.Error("handle fallback fail", "businessId", business.BusinessUid, "err", err)
+						return err
+					}
+				}
 			case <-f.resourceCtx.Done():
 				log.Info("stop fallback in worker")
 				return nil
@@ -63,3 +78,131 @@ func (f *FallBack) Start() error {
 	})
 	return nil
 }
+
+// handleFallback 找出所在区块已经不在主链上的充值、提现和内部交易，撤销它们对余额的影响并置为回滚状态；
+// 回滚已通知业务方的交易进入回滚终态。查询和回滚在同一事务中对业务方加锁执行，
+// 避免扫块同时把重新上链的交易更新到新区块后又被回滚
+func (f *FallBack) handleFallback(businessId string) error {
+	retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
+	_, err := retry.Do[interface{}](f.resourceCtx, 10, retryStrategy, func() (interface{}, error) {
+		if err := f.db.Transaction(func(tx *database.DB) error {
+			if err := tx.Business.LockBusiness(businessId); err != nil {
+				return err
+			}
+			deposits, err := tx.Deposits.QueryFallbackDeposits(businessId)
+			if err != nil {
+				return err
+			}
+			withdraws, err := tx.Withdraws.QueryFallbackWithdraws(businessId)
+			if err != nil {
+				return err
+			}
+			internals, err := tx.Internals.QueryFallbackInternals(businessId)
+			if err != nil {
+				return err
+			}
+
+			var hashes []string
+			for _, deposit := range deposits {
+				hashes = append(hashes, deposit.Hash)
+			}
+			for _, withdraw := range withdraws {
+				hashes = append(hashes, withdraw.Hash)
+			}
+			for _, internal := range internals {
+				hashes = append(hashes, internal.Hash)
+			}
+			balances, err := rollbackBalances(tx, businessId, hashes)
+			if err != nil {
+				return err
+			}
+
+			notifiedDeposits, err := tx.Deposits.QueryDepositsByStatus(businessId, database.TxStatusFallbackNotify)
+			if err != nil {
+				return err
+			}
+			notifiedWithdraws, err := tx.Withdraws.QueryWithdrawsByStatus(businessId, database.TxStatusFallbackNotify)
+			if err != nil {
+				return err
+			}
+			notifiedInternals, err := tx.Internals.QueryInternalsByStatus(businessId, database.TxStatusFallbackNotify)
+			if err != nil {
+				return err
+			}
+
+			if len(hashes) == 0 && len(notifiedDeposits) == 0 && len(notifiedWithdraws) == 0 && len(notifiedInternals) == 0 {
+				return nil
+			}
+			log.Info("handle fallback", "businessId", businessId, "deposits", len(deposits), "withdraws", len(withdraws), "internals", len(internals), "balances", len(balances))
+
+			if len(balances) > 0 {
+				if err := tx.Balances.RollbackBalances(businessId, balances); err != nil {
+					return err
+				}
+			}
+			if err := tx.Deposits.UpdateDepositsStatus(businessId, database.TxStatusFallback, deposits); err != nil {
+				return err
+			}
+			if err := tx.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusFallback, withdraws); err != nil {
+				return err
+			}
+			if err := tx.Internals.UpdateInternalStatusByGuids(businessId, database.TxStatusFallback, internals); err != nil {
+				return err
+			}
+			if err := tx.Deposits.UpdateDepositsStatus(businessId, database.TxStatusFallbackDone, notifiedDeposits); err != nil {
+				return err
+			}
+			if err := tx.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusFallbackDone, notifiedWithdraws); err != nil {
+				return err
+			}
+			return tx.Internals.UpdateInternalStatusByGuids(businessId, database.TxStatusFallbackDone, notifiedInternals)
+		}); err != nil {
+			log.Error("unable to persist fallback batch", "err", err)
+			return nil, err
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// rollbackBalances 根据扫块时记录的子交易生成需要撤销的余额变动：
+// 收款子交易（deposit、*_input）扣回 ToAddress，付款子交易（withdraw、*_output）加回对应地址
+func rollbackBalances(db *database.DB, businessId string, hashes []string) ([]database.TokenBalance, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	childTxs, err := db.ChildTxs.QueryChildTxnByHashes(businessId, hashes, []string{
+		"deposit", "withdraw", "hot_input", "cold_input", "user_output", "hot_output", "cold_output",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 扫块用 ReplaceChildTxs 保存子交易，同一笔交易重新扫到时不会重复记录，每条子交易对应一笔余额变动
+	var balances []database.TokenBalance
+	for _, childTx := range childTxs {
+		amount, ok := new(big.Int).SetString(childTx.Amount, 10)
+		if !ok {
+			log.Warn("invalid child tx amount", "hash", childTx.Hash, "amount", childTx.Amount)
+			continue
+		}
+		switch childTx.TxType {
+		case "deposit":
+			balances = append(balances, database.TokenBalance{ToAddress: childTx.ToAddress, Balance: amount, TxType: "deposit"})
+		case "withdraw":
+			balances = append(balances, database.TokenBalance{FromAddress: childTx.FromAddress, Balance: amount, TxType: "withdraw"})
+		case "hot_input":
+			// 归集和冷转热都会转入热钱包
+			balances = append(balances, database.TokenBalance{ToAddress: childTx.ToAddress, Balance: amount, TxType: "collection"})
+		case "cold_input":
+			balances = append(balances, database.TokenBalance{ToAddress: childTx.ToAddress, Balance: amount, TxType: "hot2cold"})
+		case "user_output":
+			balances = append(balances, database.TokenBalance{FromAddress: childTx.ToAddress, Balance: amount, TxType: "collection"})
+		case "hot_output":
+			balances = append(balances, database.TokenBalance{FromAddress: childTx.ToAddress, Balance: amount, TxType: "hot2cold"})
+		case "cold_output":
+			balances = append(balances, database.TokenBalance{FromAddress: childTx.ToAddress, Balance: amount, TxType: "cold2hot"})
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
+)
+
+func TestRollbackBalancesSameVoutInputs(t *testing.T) {
+	db := testDB(t)
+	businessId := strings.ReplaceAll(uuid.New().String(), "-", "")
+	dynamic.CreateTableFromTemplate(businessId, db)
+
+	userAddress, hotAddress := "user-"+businessId, "hot-"+businessId
+	// 归集交易的两个输入来自同一个用户地址，花费的都是各自充值交易的第 0 个输出
+	collectionTx := &Transaction{
+		BusinessId:  businessId,
+		BlockHash:   "block-" + businessId,
+		BlockNumber: big.NewInt(100),
+		Hash:        "collection-" + businessId,
+		TxFee:       "100",
+		TxType:      "collection",
+		VinList: []Vin{
+			{Address: userAddress, TxId: "deposit-a-" + businessId, Vout: 0, Amount: big.NewInt(1000)},
+			{Address: userAddress, TxId: "deposit-b-" + businessId, Vout: 0, Amount: big.NewInt(2000)},
+		},
+		VoutList: []Vout{{Address: hotAddress, TxIndex: 0, Amount: big.NewInt(2900)}},
+	}
+	deposit := &Deposit{}
+	_, childTxs, err := deposit.HandleInternalTx(collectionTx)
+	require.NoError(t, err)
+	require.NoError(t, db.ChildTxs.ReplaceChildTxs(businessId, childTxs))
+
+	balances, err := rollbackBalances(db, businessId, []string{collectionTx.Hash})
+	require.NoError(t, err)
+	var userTotal, hotTotal int64
+	for _, balance := range balances {
+		if balance.FromAddress == userAddress {
+			userTotal += balance.Balance.Int64()
+		}
+		if balance.ToAddress == hotAddress {
+			hotTotal += balance.Balance.Int64()
+		}
+	}
+	require.Len(t, balances, 3)
+	require.Equal(t, int64(3000), userTotal)
+	require.Equal(t, int64(2900), hotTotal)
+}
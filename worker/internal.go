@@ -15,7 +15,7 @@ import (
 )
 
 type Internal struct {
-	rpcClient      *syncclient.WalletBtcAccountClient
+	rpcClient      syncclient.ChainSource
 	db             *database.DB
 	resourceCtx    context.Context
 	resourceCancel context.CancelFunc
@@ -23,7 +23,7 @@ type Internal struct {
 	ticker         *time.Ticker
 }
 
-func NewInternal(cfg *config.Config, db *database.DB, rpcclient *syncclient.WalletBtcAccountClient, shutdown context.CancelCauseFunc) (*Internal, error) {
+func NewInternal(cfg *config.Config, db *database.DB, rpcclient syncclient.ChainSource, shutdown context.CancelCauseFunc) (*Internal, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &Internal{
 		rpcClient:      rpcclient,
@@ -69,14 +69,41 @@ func (i *Internal) Start() error {
 						continue
 					}
 
-					var balanceList []database.Balances
+					var (
+						balanceList  []database.Balances
+						sentList     []database.Internals
+						rejectedList []database.Internals
+					)
 					for _, unSendInternalTx := range unSendInternalTxList {
+						txHash, err := i.rpcClient.SendTx(unSendInternalTx.TxSignHex)
+						if errors.Is(err, syncclient.ErrTxRejected) {
+							log.Error("internal tx rejected", "transactionId", unSendInternalTx.Guid, "err", err)
+							rejectedList = append(rejectedList, unSendInternalTx)
+							continue
+						} else if err != nil && !errors.Is(err, syncclient.ErrTxAlreadyInChain) {
+							log.Error("send transaction fail, retry next round", "transactionId", unSendInternalTx.Guid, "err", err)
+							continue
+						}
+						if txHash == "" {
+							log.Error("send tx returned empty hash, retry next round", "transactionId", unSendInternalTx.Guid, "err", err)
+							continue
+						}
+						log.Info("send internal tx success", "transactionId", unSendInternalTx.Guid, "txHash", txHash)
+						unSendInternalTx.Hash = txHash
+						unSendInternalTx.Status = database.TxStatusSent
+						sentList = append(sentList, unSendInternalTx)
+
 						childTxList, err := i.db.ChildTxs.QueryChildTxnByTxId(business.BusinessUid, unSendInternalTx.Guid.String())
 						if err != nil {
 							log.Error("query child txn fail", "err", err)
 							return err
 						}
 						for _, childTx := range childTxList {
+							// CPFP 子交易花费的是还没有确认的充值，充值上链前没有计入用户余额，广播时不锁定余额；
+							// 充值和子交易上链后扫块分别按充值、归集记账，充值被丢弃或冲突时子交易由内存池检测置为失败
+							if childTx.TxType == "cpfp_vin" {
+								continue
+							}
 							lockBalance, _ := new(big.Int).SetString(childTx.Amount, 10)
 							userBalanceItem := database.Balances{
 								Address:     childTx.FromAddress,
@@ -91,15 +118,9 @@ func (i *Internal) Start() error {
 							}
 							balanceList = append(balanceList, hotBalanceItem)
 						}
-
-						txHash, err := i.rpcClient.SendTx(unSendInternalTx.TxSignHex)
-						if err != nil {
-							log.Error("send transaction fail", "err", err)
-							continue
-						} else {
-							unSendInternalTx.Hash = txHash
-							unSendInternalTx.Status = database.TxStatusSuccess
-						}
+					}
+					if len(sentList) == 0 && len(rejectedList) == 0 {
+						continue
 					}
 
 					retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
@@ -113,11 +134,13 @@ func (i *Internal) Start() error {
 								}
 							}
 
-							if len(unSendInternalTxList) > 0 {
-								if err := i.db.Internals.UpdateInternalStatus(business.BusinessUid, database.TxStatusSuccess, unSendInternalTxList); err != nil {
-									log.Error("update internal status fail", "err", err)
-									return err
-								}
+							if err := tx.Internals.UpdateInternalsSent(business.BusinessUid, sentList); err != nil {
+								log.Error("update internal status fail", "err", err)
+								return err
+							}
+							if err := tx.Internals.UpdateInternalStatusByGuids(business.BusinessUid, database.TxStatusFail, rejectedList); err != nil {
+								log.Error("update internal status fail", "err", err)
+								return err
 							}
 							return nil
 						}); err != nil {
@@ -0,0 +1,491 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
+	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+const (
+	// mempoolMinWatchInterval 新交易通知触发检测的最小间隔
+	mempoolMinWatchInterval = 2 * time.Second
+	// mempoolSpendRetention 交易离开内存池后保留它花费的输出的时长，充值在宽限期之后检测冲突时仍能找到已上链的冲突交易
+	mempoolSpendRetention = 2 * droppedGracePeriod
+	// mempoolFetchLimit 每轮最多查询的新交易数，剩余的留到下一轮
+	mempoolFetchLimit = 2000
+	// conflictScanBlocks 在区块中查找冲突交易时最多查找的区块数，约一天
+	conflictScanBlocks = 144
+)
+
+// mempoolSpend 花费某个输出的内存池交易，leftAt 为交易离开内存池的时间
+type mempoolSpend struct {
+	hash   string
+	leftAt time.Time
+}
+
+// MempoolWatcher 每轮扫描一次内存池，用地址索引匹配转入用户地址的新交易，提前记录 pending 状态的充值并通知业务方；
+// 交易上链后由扫块更新为 unsafe，输入被其他交易花费的置为 conflicted，其余从内存池消失的置为 dropped
+type MempoolWatcher struct {
+	chainSource  syncclient.ChainSource
+	mempool      syncclient.MempoolSource
+	addressIndex *cache.AddressIndex
+	db           *database.DB
+	// seen 已经查询过的内存池交易，只查询新进入内存池的交易；为 nil 时还没有扫描过内存池
+	seen map[string]bool
+	// spends 扫描到的内存池交易花费的输出，按 txid:vout 索引
+	spends         map[string]*mempoolSpend
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+	ticker         *time.Ticker
+	newTxs         <-chan struct{}
+	lastWatch      time.Time
+}
+
+// NewMempoolWatcher newTxs 收到信号时提前检测一次，为 nil 时只按 MempoolInterval 轮询；
+// mempool 为 nil 时配置关闭了内存池充值检测，只跟踪已记录的 pending 充值
+func NewMempoolWatcher(cfg *config.Config, db *database.DB, chainSource syncclient.ChainSource, mempool syncclient.MempoolSource, newTxs <-chan struct{}, shutdown context.CancelCauseFunc) (*MempoolWatcher, error) {
+	if mempool == nil {
+		log.Info("mempool deposit detection disabled", "chain", cfg.ChainNode.ChainName)
+	}
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &MempoolWatcher{
+		chainSource:    chainSource,
+		mempool:        mempool,
+		addressIndex:   cache.InitAddressIndex(db),
+		db:             db,
+		spends:         make(map[string]*mempoolSpend),
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in mempool watcher: %w", err))
+		}},
+		ticker: time.NewTicker(cfg.ChainNode.MempoolInterval),
+		newTxs: newTxs,
+	}, nil
+}
+
+func (m *MempoolWatcher) Close() error {
+	var result error
+	m.resourceCancel()
+	m.ticker.Stop()
+	log.Info("stop mempool watcher...")
+	if err := m.tasks.Wait(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to await mempool watcher %w", err))
+		return result
+	}
+	log.Info("stop mempool watcher success")
+	return nil
+}
+
+func (m *MempoolWatcher) Start() error {
+	log.Info("start mempool watcher......")
+	m.tasks.Go(func() error {
+		for {
+			select {
+			case <-m.ticker.C:
+				m.watch()
+			case <-m.newTxs:
+				// 内存池交易频繁，限制检测频率，漏掉的交易由下一次检测或轮询覆盖
+				if time.Since(m.lastWatch) < mempoolMinWatchInterval {
+					continue
+				}
+				m.watch()
+			case <-m.resourceCtx.Done():
+				log.Info("stop mempool watcher in worker")
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+func (m *MempoolWatcher) watch() {
+	m.lastWatch = time.Now()
+	businessList, err := m.db.Business.QueryBusinessList()
+	if err != nil {
+		log.Error("query business list fail", "err", err)
+		return
+	}
+	txList, err := m.scanMempool()
+	if err != nil {
+		log.Error("scan mempool fail", "err", err)
+	}
+	blocks := newBlockSpends(m.chainSource)
+	for _, business := range businessList {
+		if len(txList) > 0 {
+			if err := m.watchDeposits(business.BusinessUid, txList); err != nil {
+				log.Error("watch mempool deposits fail", "businessId", business.BusinessUid, "err", err)
+			}
+		}
+		if err := m.checkPendingDeposits(business.BusinessUid, blocks); err != nil {
+			log.Error("check pending deposits fail", "businessId", business.BusinessUid, "err", err)
+		}
+	}
+}
+
+// scanMempool 返回上一轮扫描之后新进入内存池的交易
+func (m *MempoolWatcher) scanMempool() ([]*utxo.TransactionList, error) {
+	if m.mempool == nil {
+		return nil, nil
+	}
+	txIds, err := m.mempool.GetMempoolTxIds()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]bool, len(txIds))
+	for _, txId := range txIds {
+		current[txId] = true
+	}
+	// 第一轮只记录内存池快照，不逐笔查询启动前已经在内存池中的交易，它们上链后由扫块记录
+	if m.seen == nil {
+		m.seen = current
+		log.Info("seed mempool snapshot", "txn", len(txIds))
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(txIds))
+	var newTxIds []string
+	for _, txId := range txIds {
+		if m.seen[txId] {
+			seen[txId] = true
+		} else if len(newTxIds) < mempoolFetchLimit {
+			seen[txId] = true
+			newTxIds = append(newTxIds, txId)
+		}
+	}
+	txList, err := m.mempool.GetMempoolTransactions(newTxIds)
+	if err != nil {
+		return nil, err
+	}
+	m.seen = seen
+	now := time.Now()
+	for _, tx := range txList {
+		for _, vin := range tx.Vin {
+			if vin.Hash == "" {
+				continue
+			}
+			m.spends[outpointKey(vin.Hash, vin.Index)] = &mempoolSpend{hash: tx.Hash}
+		}
+	}
+	for key, spend := range m.spends {
+		if current[spend.hash] {
+			continue
+		}
+		if spend.leftAt.IsZero() {
+			spend.leftAt = now
+		} else if now.Sub(spend.leftAt) > mempoolSpendRetention {
+			delete(m.spends, key)
+		}
+	}
+	log.Debug("scan mempool", "txn", len(txIds), "new", len(newTxIds))
+	return txList, nil
+}
+
+// watchDeposits 把转入用户地址的新内存池交易记录为 pending 充值
+func (m *MempoolWatcher) watchDeposits(businessId string, txList []*utxo.TransactionList) error {
+	if err := m.addressIndex.Refresh(businessId); err != nil {
+		return err
+	}
+	var hashes []string
+	outputs := make(map[string][]*utxo.Vout)
+	inputs := make(map[string][]*utxo.Vin)
+	for _, tx := range txList {
+		inputs[tx.Hash] = tx.Vin
+		for _, vout := range tx.Vout {
+			address, err := m.addressIndex.Lookup(businessId, vout.Address)
+			if err != nil {
+				return err
+			}
+			if address == nil || address.AddressType != 0 {
+				continue
+			}
+			if _, ok := outputs[tx.Hash]; !ok {
+				hashes = append(hashes, tx.Hash)
+			}
+			outputs[tx.Hash] = append(outputs[tx.Hash], vout)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	exists, err := m.db.Deposits.QueryDepositHashes(businessId, hashes)
+	if err != nil {
+		return err
+	}
+	var seenHeight uint64
+	latestBlock, err := m.db.Blocks.LatestBlocks()
+	if err != nil {
+		return err
+	}
+	if latestBlock != nil {
+		seenHeight = latestBlock.Number.Uint64()
+	}
+	var (
+		deposits []database.Deposits
+		childTxs []database.ChildTxs
+	)
+	now := uint64(time.Now().Unix())
+	for _, hash := range hashes {
+		if exists[hash] {
+			continue
+		}
+		// 记录充值交易花费的输出，tx_id 和 tx_index 为输出所在的交易和序号，交易从内存池消失时据此查找冲突交易
+		for _, vin := range inputs[hash] {
+			if vin.Hash == "" {
+				continue
+			}
+			childTxs = append(childTxs, database.ChildTxs{
+				GUID:        uuid.New(),
+				Hash:        hash,
+				TxId:        vin.Hash,
+				TxIndex:     big.NewInt(int64(vin.Index)),
+				TxType:      "deposit_vin",
+				FromAddress: vin.Address,
+				ToAddress:   "",
+				Amount:      "0",
+				Timestamp:   now,
+			})
+		}
+		for _, output := range outputs[hash] {
+			childTxs = append(childTxs, database.ChildTxs{
+				GUID:        uuid.New(),
+				Hash:        hash,
+				TxIndex:     big.NewInt(int64(output.Index)),
+				TxType:      "deposit",
+				FromAddress: "",
+				ToAddress:   output.Address,
+				Amount:      strconv.FormatInt(output.Amount, 10),
+				Timestamp:   now,
+			})
+		}
+		log.Info("found mempool deposit", "businessId", businessId, "hash", hash, "outputs", len(outputs[hash]))
+		deposits = append(deposits, database.Deposits{
+			GUID:        uuid.New(),
+			BlockHash:   "0x00",
+			BlockNumber: big.NewInt(0),
+			Hash:        hash,
+			Fee:         big.NewInt(0),
+			LockTime:    big.NewInt(0),
+			Version:     "0x00",
+			Status:      database.TxStatusPending,
+			SeenHeight:  seenHeight,
+			Timestamp:   now,
+		})
+	}
+	if len(deposits) == 0 {
+		return nil
+	}
+	return m.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Deposits.StoreDeposits(businessId, deposits); err != nil {
+			return err
+		}
+		if len(childTxs) == 0 {
+			return nil
+		}
+		return tx.ChildTxs.StoreChildTxs(businessId, childTxs)
+	})
+}
+
+// checkPendingDeposits 检查已经不在内存池中、也没有上链的 pending 充值，找到花费相同输入的交易时置为 conflicted
+// 并记录冲突交易，否则置为 dropped。充值离开内存池后立即查找内存池中的替换交易，
+// 宽限期之后才在区块中查找冲突交易，仍然找不到时认为被丢弃；blocks 在一轮检测的所有业务方之间共享
+func (m *MempoolWatcher) checkPendingDeposits(businessId string, blocks *blockSpends) error {
+	pendingDeposits, err := m.db.Deposits.QueryPendingDeposits(businessId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var droppedList []database.Deposits
+	for _, deposit := range pendingDeposits {
+		if m.seen[deposit.Hash] {
+			continue
+		}
+		expired := now.Sub(time.Unix(int64(deposit.Timestamp), 0)) >= droppedGracePeriod
+		vinTxs, err := m.db.ChildTxs.QueryChildTxnByHashes(businessId, []string{deposit.Hash}, []string{"deposit_vin"})
+		if err != nil {
+			return err
+		}
+		conflictHash, err := m.mempoolSpender(deposit, vinTxs)
+		if err != nil {
+			log.Error("find pending deposit conflict fail", "hash", deposit.Hash, "err", err)
+			continue
+		}
+		if conflictHash == "" && !expired {
+			continue
+		}
+		tx, err := m.chainSource.GetTransactionByHash(deposit.Hash)
+		if err != nil && !errors.Is(err, syncclient.ErrTxNotFound) {
+			log.Error("get pending deposit tx fail", "hash", deposit.Hash, "err", err)
+			continue
+		}
+		if err == nil && tx.Status != utxo.TxStatus_NotFound {
+			continue
+		}
+		if conflictHash == "" {
+			conflictHash, err = findChainConflict(deposit, vinTxs, blocks)
+			if err != nil {
+				log.Error("find pending deposit conflict fail", "hash", deposit.Hash, "err", err)
+				continue
+			}
+		}
+		if conflictHash != "" {
+			log.Warn("pending deposit conflicted", "businessId", businessId, "hash", deposit.Hash, "conflictHash", conflictHash)
+			// 按查询时的状态更新，扫块已经把充值更新为上链状态时不会被覆盖
+			if err := m.db.Transaction(func(tx *database.DB) error {
+				if err := tx.Deposits.UpdateDepositConflicted(businessId, deposit, conflictHash); err != nil {
+					return err
+				}
+				return tx.Internals.FailCpfpInternals(businessId, []string{deposit.Hash})
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Warn("pending deposit dropped", "businessId", businessId, "hash", deposit.Hash)
+		droppedList = append(droppedList, deposit)
+	}
+	if len(droppedList) == 0 {
+		return nil
+	}
+	var droppedHashes []string
+	for _, deposit := range droppedList {
+		droppedHashes = append(droppedHashes, deposit.Hash)
+	}
+	return m.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Deposits.UpdateDepositsNotifyStatus(businessId, database.TxStatusDropped, droppedList); err != nil {
+			return err
+		}
+		// 加速这些充值的 CPFP 子交易不可能再上链
+		return tx.Internals.FailCpfpInternals(businessId, droppedHashes)
+	})
+}
+
+// mempoolSpender 在扫描内存池时记录的花费和内存池数据源中查找花费了充值交易输入的其他交易，
+// 重启之后没有扫描到替换交易时也能通过数据源找到
+func (m *MempoolWatcher) mempoolSpender(deposit database.Deposits, vinTxs []database.ChildTxs) (string, error) {
+	for _, vinTx := range vinTxs {
+		if vinTx.TxId == "" || vinTx.TxIndex == nil {
+			continue
+		}
+		vout := uint32(vinTx.TxIndex.Uint64())
+		spend, ok := m.spends[outpointKey(vinTx.TxId, vout)]
+		if ok && spend.hash != deposit.Hash {
+			return spend.hash, nil
+		}
+		if m.mempool != nil {
+			spender, err := m.mempool.GetOutpointSpender(vinTx.TxId, vout)
+			if err != nil {
+				return "", err
+			}
+			if spender != "" && spender != deposit.Hash {
+				return spender, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// findChainConflict 在充值被发现之后上链的区块中查找花费了充值交易输入的其他交易，最多查找最近 conflictScanBlocks 个区块
+func findChainConflict(deposit database.Deposits, vinTxs []database.ChildTxs, blocks *blockSpends) (string, error) {
+	var outpoints []string
+	for _, vinTx := range vinTxs {
+		if vinTx.TxId == "" || vinTx.TxIndex == nil {
+			continue
+		}
+		outpoints = append(outpoints, outpointKey(vinTx.TxId, uint32(vinTx.TxIndex.Uint64())))
+	}
+	if len(outpoints) == 0 {
+		return "", nil
+	}
+	tip, err := blocks.tipHeight()
+	if err != nil {
+		return "", err
+	}
+	from := deposit.SeenHeight + 1
+	if tip >= conflictScanBlocks && from+conflictScanBlocks <= tip {
+		from = tip - conflictScanBlocks + 1
+	}
+	for height := from; height <= tip; height++ {
+		spenders, err := blocks.spendersAt(height)
+		if err != nil {
+			return "", err
+		}
+		for _, outpoint := range outpoints {
+			if spender, ok := spenders[outpoint]; ok && spender != deposit.Hash {
+				return spender, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// blockSpends 一轮检测中查询过的区块花费的输出，每个高度每轮最多查询一次
+type blockSpends struct {
+	chainSource syncclient.ChainSource
+	tip         *uint64
+	// spenders 按高度记录区块中每个输出的花费交易，按 txid:vout 索引
+	spenders map[uint64]map[string]string
+}
+
+func newBlockSpends(chainSource syncclient.ChainSource) *blockSpends {
+	return &blockSpends{
+		chainSource: chainSource,
+		spenders:    make(map[uint64]map[string]string),
+	}
+}
+
+func (b *blockSpends) tipHeight() (uint64, error) {
+	if b.tip != nil {
+		return *b.tip, nil
+	}
+	header, err := b.chainSource.GetBlockHeader(nil)
+	if err != nil {
+		return 0, err
+	}
+	tip := header.Number.Uint64()
+	b.tip = &tip
+	return tip, nil
+}
+
+func (b *blockSpends) spendersAt(height uint64) (map[string]string, error) {
+	if spenders, ok := b.spenders[height]; ok {
+		return spenders, nil
+	}
+	header, err := b.chainSource.GetBlockHeader(new(big.Int).SetUint64(height))
+	if err != nil {
+		return nil, err
+	}
+	txList, err := b.chainSource.GetBlockByHash(header.Hash)
+	if err != nil {
+		return nil, err
+	}
+	spenders := make(map[string]string)
+	for _, tx := range txList {
+		for _, vin := range tx.Vin {
+			if vin.Hash == "" {
+				continue
+			}
+			spenders[outpointKey(vin.Hash, vin.Index)] = tx.Hash
+		}
+	}
+	b.spenders[height] = spenders
+	return spenders, nil
+}
+
+func outpointKey(txId string, vout uint32) string {
+	return fmt.Sprintf("%s:%d", txId, vout)
+}
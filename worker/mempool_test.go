@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// stubMempool 返回固定的内存池交易，记录每次查询的交易
+type stubMempool struct {
+	txIds    []string
+	fetched  [][]string
+	spenders map[string]string
+}
+
+func (s *stubMempool) GetMempoolTxIds() ([]string, error) {
+	return s.txIds, nil
+}
+
+func (s *stubMempool) GetMempoolTransactions(hashes []string) ([]*utxo.TransactionList, error) {
+	s.fetched = append(s.fetched, hashes)
+	var txList []*utxo.TransactionList
+	for _, hash := range hashes {
+		txList = append(txList, &utxo.TransactionList{Hash: hash})
+	}
+	return txList, nil
+}
+
+func (s *stubMempool) GetOutpointSpender(txId string, vout uint32) (string, error) {
+	return s.spenders[outpointKey(txId, vout)], nil
+}
+
+// stubChain 按高度返回固定的区块，只实现查找冲突交易用到的方法
+type stubChain struct {
+	syncclient.ChainSource
+	blocks [][]*utxo.TransactionList
+}
+
+func (s *stubChain) GetBlockHeader(number *big.Int) (*syncclient.BlockHeader, error) {
+	if number == nil {
+		number = big.NewInt(int64(len(s.blocks) - 1))
+	}
+	return &syncclient.BlockHeader{Hash: number.String(), Number: number}, nil
+}
+
+func (s *stubChain) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	height, err := strconv.Atoi(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.blocks[height], nil
+}
+
+func TestScanMempoolSeedsAndCapsFetches(t *testing.T) {
+	mempool := &stubMempool{txIds: []string{"initial"}}
+	watcher := &MempoolWatcher{mempool: mempool, spends: make(map[string]*mempoolSpend)}
+
+	// 第一轮只记录快照
+	txList, err := watcher.scanMempool()
+	require.NoError(t, err)
+	require.Empty(t, txList)
+	require.Empty(t, mempool.fetched)
+
+	mempool.txIds = []string{"initial"}
+	for i := 0; i < mempoolFetchLimit+10; i++ {
+		mempool.txIds = append(mempool.txIds, fmt.Sprintf("tx-%d", i))
+	}
+	txList, err = watcher.scanMempool()
+	require.NoError(t, err)
+	require.Len(t, txList, mempoolFetchLimit)
+
+	// 超出上限的交易在下一轮查询
+	txList, err = watcher.scanMempool()
+	require.NoError(t, err)
+	require.Len(t, txList, 10)
+	require.Equal(t, fmt.Sprintf("tx-%d", mempoolFetchLimit), txList[0].Hash)
+
+	txList, err = watcher.scanMempool()
+	require.NoError(t, err)
+	require.Empty(t, txList)
+}
+
+func TestConflictSpenderWithoutScannedSpends(t *testing.T) {
+	deposit := database.Deposits{Hash: "deposit", SeenHeight: 1}
+	vinTxs := []database.ChildTxs{
+		{Hash: "deposit", TxId: "prev", TxIndex: big.NewInt(0), TxType: "deposit_vin"},
+		{Hash: "deposit", TxId: "prev", TxIndex: big.NewInt(1), TxType: "deposit_vin"},
+	}
+	mempool := &stubMempool{spenders: map[string]string{outpointKey("prev", 0): "deposit"}}
+	chain := &stubChain{blocks: [][]*utxo.TransactionList{
+		{{Hash: "old", Vin: []*utxo.Vin{{Hash: "prev", Index: 1}}}},
+		{},
+		{{Hash: "other", Vin: []*utxo.Vin{{Hash: "unrelated", Index: 1}}}},
+	}}
+	// 重启之后没有扫描到的花费记录，冲突交易只在发现充值之前的区块中时按 dropped 处理
+	watcher := &MempoolWatcher{chainSource: chain, mempool: mempool, spends: make(map[string]*mempoolSpend)}
+	spender, err := watcher.mempoolSpender(deposit, vinTxs)
+	require.NoError(t, err)
+	require.Empty(t, spender)
+	spender, err = findChainConflict(deposit, vinTxs, newBlockSpends(chain))
+	require.NoError(t, err)
+	require.Empty(t, spender)
+
+	// 替换交易已经上链
+	chain.blocks = append(chain.blocks, []*utxo.TransactionList{{Hash: "mined", Vin: []*utxo.Vin{{Hash: "prev", Index: 1}}}})
+	spender, err = findChainConflict(deposit, vinTxs, newBlockSpends(chain))
+	require.NoError(t, err)
+	require.Equal(t, "mined", spender)
+
+	// 替换交易还在内存池中
+	mempool.spenders[outpointKey("prev", 1)] = "replacement"
+	spender, err = watcher.mempoolSpender(deposit, vinTxs)
+	require.NoError(t, err)
+	require.Equal(t, "replacement", spender)
+}
+
+func TestBlockSpendsFetchesEachHeightOnce(t *testing.T) {
+	chain := &countingChain{stubChain: stubChain{blocks: [][]*utxo.TransactionList{
+		{},
+		{{Hash: "a", Vin: []*utxo.Vin{{Hash: "prev", Index: 0}}}},
+		{{Hash: "b", Vin: []*utxo.Vin{{Hash: "prev", Index: 1}}}},
+	}}}
+	blocks := newBlockSpends(chain)
+	vinTxs := []database.ChildTxs{{Hash: "deposit", TxId: "prev", TxIndex: big.NewInt(2), TxType: "deposit_vin"}}
+	for i := 0; i < 3; i++ {
+		spender, err := findChainConflict(database.Deposits{Hash: "deposit"}, vinTxs, blocks)
+		require.NoError(t, err)
+		require.Empty(t, spender)
+	}
+	// 只查找发现充值之后的区块
+	spender, err := findChainConflict(database.Deposits{Hash: "deposit", SeenHeight: 1}, []database.ChildTxs{{TxId: "prev", TxIndex: big.NewInt(0)}}, blocks)
+	require.NoError(t, err)
+	require.Empty(t, spender)
+	require.Equal(t, map[string]int{"1": 1, "2": 1}, chain.fetched)
+}
+
+// countingChain 记录每个区块被查询的次数
+type countingChain struct {
+	stubChain
+	fetched map[string]int
+}
+
+func (c *countingChain) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	if c.fetched == nil {
+		c.fetched = make(map[string]int)
+	}
+	c.fetched[hash]++
+	return c.stubChain.GetBlockByHash(hash)
+}
+
+func storeTestCpfp(t *testing.T, db *database.DB, businessId string, parentHash string, status database.TxStatus) *database.Internals {
+	internal := &database.Internals{
+		Guid:        uuid.New(),
+		BlockHash:   "0x00",
+		BlockNumber: big.NewInt(0),
+		Hash:        "cpfp-" + uuid.New().String(),
+		Fee:         big.NewInt(1000),
+		LockTime:    big.NewInt(0),
+		Version:     "0x00",
+		TxType:      database.InternalTxTypeCpfp,
+		TxSignHex:   "0x00",
+		Status:      status,
+		Timestamp:   uint64(time.Now().Unix()),
+	}
+	require.NoError(t, db.Internals.StoreInternal(businessId, internal))
+	require.NoError(t, db.ChildTxs.StoreChildTxs(businessId, []database.ChildTxs{{
+		GUID:        uuid.New(),
+		Hash:        parentHash,
+		TxId:        internal.Guid.String(),
+		TxIndex:     big.NewInt(0),
+		TxType:      "cpfp_vin",
+		FromAddress: "user-" + businessId,
+		ToAddress:   "hot-" + businessId,
+		Amount:      "10000",
+		Timestamp:   internal.Timestamp,
+	}}))
+	return internal
+}
+
+func TestFailCpfpInternalsOfDroppedDeposit(t *testing.T) {
+	db := testDB(t)
+	businessId := strings.ReplaceAll(uuid.New().String(), "-", "")
+	dynamic.CreateTableFromTemplate(businessId, db)
+
+	sent := storeTestCpfp(t, db, businessId, "dropped-parent", database.TxStatusSentNotify)
+	waitSign := storeTestCpfp(t, db, businessId, "dropped-parent", database.TxStatusWaitSign)
+	// 已经上链的子交易和加速其他充值的子交易不受影响
+	mined := storeTestCpfp(t, db, businessId, "dropped-parent", database.TxStatusSuccessNotify)
+	other := storeTestCpfp(t, db, businessId, "other-parent", database.TxStatusSent)
+
+	require.NoError(t, db.Internals.FailCpfpInternals(businessId, []string{"dropped-parent"}))
+	for internal, status := range map[*database.Internals]database.TxStatus{
+		sent:     database.TxStatusFail,
+		waitSign: database.TxStatusFail,
+		mined:    database.TxStatusSuccessNotify,
+		other:    database.TxStatusSent,
+	} {
+		stored, err := db.Internals.QueryInternalByGuid(businessId, internal.Guid.String())
+		require.NoError(t, err)
+		require.Equal(t, status, stored.Status)
+	}
+}
@@ -0,0 +1,299 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/retry"
+	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/notifier"
+)
+
+const (
+	// notifyTimeout 单次推送的超时时间
+	notifyTimeout = 10 * time.Second
+	// notifyMaxAttempts 每轮推送的最大尝试次数，仍然失败的交易进入 *_notify_fail 状态，下一轮重新推送
+	notifyMaxAttempts = 3
+	// notifyParallelism 同时推送的业务方数，一个业务方的接口变慢不会拖慢其他业务方
+	notifyParallelism = 8
+)
+
+// cachedNotifyClient 业务方的推送客户端，业务方修改 notify url 后重新创建
+type cachedNotifyClient struct {
+	notifyUrl string
+	client    *notifier.NotifyClient
+}
+
+type Notifier struct {
+	db             *database.DB
+	notifyClients  map[string]*cachedNotifyClient
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+	ticker         *time.Ticker
+}
+
+func NewNotifier(cfg *config.Config, db *database.DB, shutdown context.CancelCauseFunc) (*Notifier, error) {
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &Notifier{
+		db:             db,
+		notifyClients:  make(map[string]*cachedNotifyClient),
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in notifier: %w", err))
+		}},
+		ticker: time.NewTicker(cfg.ChainNode.WorkerInterval),
+	}, nil
+}
+
+func (n *Notifier) Close() error {
+	var result error
+	n.resourceCancel()
+	n.ticker.Stop()
+	log.Info("stop notifier...")
+	if err := n.tasks.Wait(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to await notifier %w", err))
+		return result
+	}
+	log.Info("stop notifier success")
+	return nil
+}
+
+func (n *Notifier) Start() error {
+	log.Info("start notifier......")
+	n.tasks.Go(func() error {
+		for {
+			select {
+			case <-n.ticker.C:
+				businessList, err := n.db.Business.QueryBusinessList()
+				if err != nil {
+					log.Error("query business list fail", "err", err)
+					continue
+				}
+				n.notifyBusinesses(businessList)
+			case <-n.resourceCtx.Done():
+				log.Info("stop notifier in worker")
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// notifyBusinesses 最多 notifyParallelism 个业务方并发推送，等待本轮所有推送结束
+func (n *Notifier) notifyBusinesses(businessList []database.Business) {
+	var group errgroup.Group
+	group.SetLimit(notifyParallelism)
+	for _, business := range businessList {
+		client, err := n.notifyClient(business)
+		if err != nil {
+			log.Warn("business notify client unavailable", "businessId", business.BusinessUid, "err", err)
+			continue
+		}
+		group.Go(func() error {
+			if err := n.processNotify(business, client); err != nil {
+				log.Error("process notify fail", "businessId", business.BusinessUid, "err", err)
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+}
+
+// processNotify 把一个业务方所有待通知的交易合并成一次请求推送，失败或超时后退避重试，
+// 推送成功后交易进入对应的 *_notify_success 状态，重试后仍然失败则进入 *_notify_fail 状态，下一轮重新推送
+func (n *Notifier) processNotify(business database.Business, client *notifier.NotifyClient) error {
+
+	deposits, err := n.db.Deposits.QueryNotifyDeposits(business.BusinessUid)
+	if err != nil {
+		return err
+	}
+	withdraws, err := n.db.Withdraws.QueryNotifyWithdraws(business.BusinessUid)
+	if err != nil {
+		return err
+	}
+	internals, err := n.db.Internals.QueryNotifyInternal(business.BusinessUid)
+	if err != nil {
+		return err
+	}
+	if len(deposits) == 0 && len(withdraws) == 0 && len(internals) == 0 {
+		return nil
+	}
+
+	notifyRequest, err := n.buildNotifyRequest(business.BusinessUid, deposits, withdraws, internals)
+	if err != nil {
+		return err
+	}
+
+	retryStrategy := &retry.ExponentialStrategy{Min: time.Second, Max: 10 * time.Second, MaxJitter: 250 * time.Millisecond}
+	_, notifyErr := retry.Do[interface{}](n.resourceCtx, notifyMaxAttempts, retryStrategy, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(n.resourceCtx, notifyTimeout)
+		defer cancel()
+		return nil, client.BusinessNotify(ctx, notifyRequest)
+	})
+	if notifyErr != nil {
+		// 退出时中断的推送不改变状态
+		if n.resourceCtx.Err() != nil {
+			return nil
+		}
+		log.Error("notify business fail", "businessId", business.BusinessUid, "txn", len(notifyRequest.Txn), "err", notifyErr)
+	} else {
+		log.Info("notify business success", "businessId", business.BusinessUid, "txn", len(notifyRequest.Txn))
+	}
+	notified := notifyErr == nil
+
+	return n.db.Transaction(func(tx *database.DB) error {
+		for _, deposit := range deposits {
+			if err := tx.Deposits.UpdateDepositsNotifyStatus(business.BusinessUid, notifyResultStatus(deposit.Status, notified), []database.Deposits{deposit}); err != nil {
+				return err
+			}
+		}
+		for _, withdraw := range withdraws {
+			if err := tx.Withdraws.UpdateWithdrawsNotifyStatus(business.BusinessUid, notifyResultStatus(withdraw.Status, notified), []database.Withdraws{withdraw}); err != nil {
+				return err
+			}
+		}
+		for _, internal := range internals {
+			if err := tx.Internals.UpdateInternalsNotifyStatus(business.BusinessUid, notifyResultStatus(internal.Status, notified), []database.Internals{internal}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// notifyClient 按业务方缓存推送客户端，notify url 变化时重新创建
+func (n *Notifier) notifyClient(business database.Business) (*notifier.NotifyClient, error) {
+	if cached, ok := n.notifyClients[business.BusinessUid]; ok && cached.notifyUrl == business.NotifyUrl {
+		return cached.client, nil
+	}
+	client, err := notifier.NewNotifyClient(business.NotifyUrl)
+	if err != nil {
+		delete(n.notifyClients, business.BusinessUid)
+		return nil, err
+	}
+	n.notifyClients[business.BusinessUid] = &cachedNotifyClient{notifyUrl: business.NotifyUrl, client: client}
+	return client, nil
+}
+
+func (n *Notifier) buildNotifyRequest(businessId string, deposits []database.Deposits, withdraws []database.Withdraws, internals []database.Internals) (*notifier.NotifyRequest, error) {
+	var txn []notifier.Transaction
+	for _, deposit := range deposits {
+		childTxs, err := n.db.ChildTxs.QueryChildTxnByHashes(businessId, []string{deposit.Hash}, []string{"deposit"})
+		if err != nil {
+			return nil, err
+		}
+		txn = append(txn, notifier.Transaction{
+			TransactionId: deposit.GUID.String(),
+			ConflictHash:  deposit.ConflictHash,
+			BlockHash:     deposit.BlockHash,
+			BlockNumber:   uint64OrZero(deposit.BlockNumber),
+			Hash:          deposit.Hash,
+			Fee:           stringOrZero(deposit.Fee),
+			TxType:        "deposit",
+			Status:        string(notifyStatus(deposit.Status)),
+			Confirms:      deposit.Confirms,
+			Timestamp:     deposit.Timestamp,
+			ChildTxs:      notifyChildTxs(childTxs),
+		})
+	}
+	for _, withdraw := range withdraws {
+		childTxs, err := n.db.ChildTxs.QueryChildTxnByTxId(businessId, withdraw.Guid.String())
+		if err != nil {
+			return nil, err
+		}
+		txn = append(txn, notifier.Transaction{
+			TransactionId:        withdraw.Guid.String(),
+			ReplaceTransactionId: withdraw.ReplaceGuid,
+			BlockHash:            withdraw.BlockHash,
+			BlockNumber:          uint64OrZero(withdraw.BlockNumber),
+			Hash:                 withdraw.Hash,
+			Fee:                  stringOrZero(withdraw.Fee),
+			TxType:               "withdraw",
+			Status:               string(notifyStatus(withdraw.Status)),
+			Timestamp:            withdraw.Timestamp,
+			UnSignTx:             withdraw.UnSignTx,
+			TxData:               withdraw.TxData,
+			ChildTxs:             notifyChildTxs(childTxs),
+		})
+	}
+	for _, internal := range internals {
+		childTxs, err := n.db.ChildTxs.QueryChildTxnByTxId(businessId, internal.Guid.String())
+		if err != nil {
+			return nil, err
+		}
+		txn = append(txn, notifier.Transaction{
+			TransactionId: internal.Guid.String(),
+			BlockHash:     internal.BlockHash,
+			BlockNumber:   uint64OrZero(internal.BlockNumber),
+			Hash:          internal.Hash,
+			Fee:           stringOrZero(internal.Fee),
+			TxType:        internal.TxType,
+			Status:        string(notifyStatus(internal.Status)),
+			Timestamp:     internal.Timestamp,
+			ChildTxs:      notifyChildTxs(childTxs),
+		})
+	}
+	return &notifier.NotifyRequest{Txn: txn}, nil
+}
+
+// notifyChildTxs 同一笔交易被重新扫到时会再写一份子交易，推送前按序号和地址去重
+func notifyChildTxs(childTxs []database.ChildTxs) []notifier.ChildTx {
+	seen := make(map[string]bool)
+	var result []notifier.ChildTx
+	for _, childTx := range childTxs {
+		key := fmt.Sprintf("%s-%s-%s", childTx.TxIndex, childTx.FromAddress, childTx.ToAddress)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, notifier.ChildTx{
+			TxIndex:     uint64OrZero(childTx.TxIndex),
+			FromAddress: childTx.FromAddress,
+			ToAddress:   childTx.ToAddress,
+			Amount:      childTx.Amount,
+		})
+	}
+	return result
+}
+
+// notifyStatus 通知失败重新推送时，告诉业务方的仍然是原来的状态
+func notifyStatus(status database.TxStatus) database.TxStatus {
+	for origin, transition := range database.NotifyTransitions {
+		if transition.Fail == status && origin != status {
+			return origin
+		}
+	}
+	return status
+}
+
+func notifyResultStatus(status database.TxStatus, notified bool) database.TxStatus {
+	transition := database.NotifyTransitions[status]
+	if notified {
+		return transition.Success
+	}
+	return transition.Fail
+}
+
+func uint64OrZero(num *big.Int) uint64 {
+	if num == nil {
+		return 0
+	}
+	return num.Uint64()
+}
+
+func stringOrZero(num *big.Int) string {
+	if num == nil {
+		return "0"
+	}
+	return num.String()
+}
@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+)
+
+func TestNotifyClientFollowsNotifyUrl(t *testing.T) {
+	n := &Notifier{notifyClients: make(map[string]*cachedNotifyClient)}
+	business := database.Business{BusinessUid: "business", NotifyUrl: "http://127.0.0.1:8080/notify"}
+
+	first, err := n.notifyClient(business)
+	require.NoError(t, err)
+	cached, err := n.notifyClient(business)
+	require.NoError(t, err)
+	require.Same(t, first, cached)
+
+	// 业务方修改 notify url 后使用新的客户端
+	business.NotifyUrl = "http://127.0.0.1:9090/notify"
+	updated, err := n.notifyClient(business)
+	require.NoError(t, err)
+	require.NotSame(t, first, updated)
+
+	// notify url 被清空时不再使用旧的客户端
+	business.NotifyUrl = ""
+	_, err = n.notifyClient(business)
+	require.Error(t, err)
+	require.NotContains(t, n.notifyClients, business.BusinessUid)
+}
@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/bigint"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+)
+
+// handleReorg 处理链重组：从数据库最新区块往回找到与链上一致的公共祖先，
+// 把祖先之后的孤块移到 reorg_blocks，并回滚这些孤块产生的交易流水和 utxo，
+// 业务方的扫块进度也退回到公共祖先，最后把区块遍历位置重置到公共祖先，下一轮从祖先之后重新扫描。
+// 回滚前先等待已经发出的批次处理完，避免孤块中的交易在回滚之后才入库
+func (syncer *BaseSynchronizer) handleReorg() error {
+	syncer.inFlight.Wait()
+
+	ancestor, orphanedBlocks, err := syncer.findCommonAncestor()
+	if err != nil {
+		return err
+	}
+	log.Info("found common ancestor", "number", ancestor.Number, "hash", ancestor.Hash, "orphanedBlocks", len(orphanedBlocks))
+
+	if len(orphanedBlocks) > 0 {
+		businessList, err := syncer.database.Business.QueryBusinessList()
+		if err != nil {
+			log.Error("failed to fetch business list", "err", err)
+			return err
+		}
+		// 按固定顺序获取业务方锁，和扫块批次、FallBack 任务互斥，避免回滚和它们交错
+		sort.Slice(businessList, func(i, j int) bool {
+			return businessList[i].BusinessUid < businessList[j].BusinessUid
+		})
+		if err := syncer.database.Transaction(func(tx *database.DB) error {
+			for _, business := range businessList {
+				if err := tx.Business.LockBusiness(business.BusinessUid); err != nil {
+					return err
+				}
+			}
+			if err := tx.ReorgBlocks.StoreReorgBlocks(orphanedBlocks); err != nil {
+				return err
+			}
+			if err := tx.Blocks.DeleteBlocksAfterNumber(ancestor.Number); err != nil {
+				return err
+			}
+			// 扫块进度只会向前推进，回滚后要退回到祖先，替换链上的区块才会重新分类
+			if err := tx.Business.RollbackBusinessSyncHeight(ancestor.Number.Uint64()); err != nil {
+				return err
+			}
+			for _, business := range businessList {
+				if err := rollbackBusiness(tx, business.BusinessUid, ancestor.Number); err != nil {
+					return fmt.Errorf("rollback business %s fail: %w", business.BusinessUid, err)
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Error("unable to persist reorg rollback", "err", err)
+			return err
+		}
+	}
+
+	syncer.blockBatch.Reset(ancestor)
+	return nil
+}
+
+// findCommonAncestor 返回公共祖先区块以及需要回滚的孤块
+func (syncer *BaseSynchronizer) findCommonAncestor() (*syncclient.BlockHeader, []database.ReorgBlocks, error) {
+	var orphanedBlocks []database.ReorgBlocks
+	header, err := syncer.database.Blocks.LatestBlocks()
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		// 数据库中还没有区块，起始区块本身被重组，直接按高度重新获取
+		lastTraversed := syncer.blockBatch.LastTraversedHeader()
+		chainHeader, err := syncer.rpcClient.GetBlockHeader(lastTraversed.Number)
+		if err != nil {
+			return nil, nil, err
+		}
+		return chainHeader, nil, nil
+	}
+
+	for header != nil {
+		chainHeader, err := syncer.rpcClient.GetBlockHeader(header.Number)
+		if err != nil {
+			return nil, nil, err
+		}
+		if chainHeader.Hash == header.Hash {
+			return header, orphanedBlocks, nil
+		}
+		log.Warn("orphaned block", "number", header.Number, "hash", header.Hash, "source", header.Source,
+			"canonicalHash", chainHeader.Hash, "canonicalSource", chainHeader.Source)
+		orphanedBlocks = append(orphanedBlocks, database.ReorgBlocks{
+			Hash:      header.Hash,
+			PrevHash:  header.PrevHash,
+			Number:    header.Number,
+			Timestamp: header.Timestamp,
+			Source:    header.Source,
+		})
+
+		prevNumber := new(big.Int).Sub(header.Number, bigint.One)
+		parent, err := syncer.database.Blocks.BlockHeaderByNumber(prevNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parent == nil {
+			// 已经回退到数据库中最早的区块，以链上该高度的区块作为祖先
+			chainParent, err := syncer.rpcClient.GetBlockHeader(prevNumber)
+			if err != nil {
+				return nil, nil, err
+			}
+			return chainParent, orphanedBlocks, nil
+		}
+		header = parent
+	}
+	return nil, nil, fmt.Errorf("common ancestor not found")
+}
+
+// rollbackBusiness 回滚单个业务方在 number 之后的孤块数据；
+// 充值、提现和内部交易及其余额由 FallBack 任务按回滚流程处理
+func rollbackBusiness(tx *database.DB, businessId string, number *big.Int) error {
+	orphanedTxs, err := tx.Transactions.QueryTransactionsAfterBlock(businessId, number)
+	if err != nil {
+		return err
+	}
+	var txHashes []string
+	for _, orphanedTx := range orphanedTxs {
+		txHashes = append(txHashes, orphanedTx.Hash)
+	}
+
+	log.Info("rollback business", "businessId", businessId, "number", number, "transactions", len(txHashes))
+	if err := tx.Vins.DeleteVinsByTxIds(businessId, txHashes); err != nil {
+		return err
+	}
+	if err := tx.Vins.RevertVinsSpend(businessId, txHashes); err != nil {
+		return err
+	}
+	if err := tx.Vouts.DeleteVoutsByTxIds(businessId, txHashes); err != nil {
+		return err
+	}
+	return tx.Transactions.DeleteTransactionsAfterBlock(businessId, number)
+}
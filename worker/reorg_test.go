@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// reorgChainSource 按高度返回当前规范链上的区块头，按 hash 返回区块内容
+type reorgChainSource struct {
+	syncclient.ChainSource
+	headers map[uint64]*syncclient.BlockHeader
+	latest  uint64
+	blocks  map[string][]*utxo.TransactionList
+}
+
+func (s *reorgChainSource) GetBlockHeader(number *big.Int) (*syncclient.BlockHeader, error) {
+	height := s.latest
+	if number != nil {
+		height = number.Uint64()
+	}
+	header, ok := s.headers[height]
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return header, nil
+}
+
+func (s *reorgChainSource) GetBlockByHash(hash string) ([]*utxo.TransactionList, error) {
+	return s.blocks[hash], nil
+}
+
+func testHeader(number uint64, hash string, prevHash string) *syncclient.BlockHeader {
+	return &syncclient.BlockHeader{Hash: hash, PrevHash: prevHash, Number: new(big.Int).SetUint64(number)}
+}
+
+func TestReorgRecordsReplacementDeposit(t *testing.T) {
+	businessId := strings.ReplaceAll(uuid.New().String(), "-", "")
+	// 区块按链区分，每个测试使用独立的链避免和其他测试的区块冲突
+	chain := "reorg-" + businessId
+	db := testDB(t).WithChain(chain)
+	dynamic.CreateTableFromTemplate(businessId, db)
+	require.NoError(t, db.Business.StoreBusiness(&database.Business{
+		GUID:        uuid.New(),
+		BusinessUid: businessId,
+		Chain:       chain,
+		Timestamp:   uint64(time.Now().Unix()),
+	}))
+	userAddress := "user-" + businessId
+	require.NoError(t, db.Addresses.StoreAddresses(businessId, []database.Addresses{{
+		GUID:      uuid.New(),
+		Address:   userAddress,
+		Timestamp: uint64(time.Now().Unix()),
+	}}))
+
+	// 数据库中已经扫描了 100 和孤块 101a，链上 101a 被 101b、102b 替换，充值在 101b 中
+	block100 := testHeader(100, "100-"+businessId, "99-"+businessId)
+	block101a := testHeader(101, "101a-"+businessId, block100.Hash)
+	block101b := testHeader(101, "101b-"+businessId, block100.Hash)
+	block102b := testHeader(102, "102b-"+businessId, block101b.Hash)
+	for _, header := range []*syncclient.BlockHeader{block100, block101a} {
+		require.NoError(t, db.Blocks.StoreBlockss([]database.Blocks{{
+			Hash:     header.Hash,
+			PrevHash: header.PrevHash,
+			Number:   header.Number,
+		}}))
+	}
+	require.NoError(t, db.Business.UpdateBusinessSyncHeight([]string{businessId}, 101))
+
+	depositHash := "deposit-" + businessId
+	source := &reorgChainSource{
+		headers: map[uint64]*syncclient.BlockHeader{100: block100, 101: block101b, 102: block102b},
+		latest:  102,
+		blocks: map[string][]*utxo.TransactionList{
+			block101b.Hash: {{
+				Hash: depositHash,
+				Fee:  "100",
+				Vin:  []*utxo.Vin{{Hash: "prev-" + businessId, Index: 0, Amount: 1100, Address: "external"}},
+				Vout: []*utxo.Vout{{Address: userAddress, Amount: 1000, Index: 0}},
+			}},
+		},
+	}
+	businessChannels := make(chan map[string]*TransactionsChannel, 1)
+	deposit := &Deposit{
+		BaseSynchronizer: BaseSynchronizer{
+			headerBufferSize: 10,
+			businessChannels: businessChannels,
+			rpcClient:        source,
+			blockBatch:       syncclient.NewBatchBlock(source, block101a, big.NewInt(0), 1),
+			database:         db,
+			addressIndex:     cache.InitAddressIndex(db),
+			inFlight:         &sync.WaitGroup{},
+		},
+		confirms:    6,
+		resourceCtx: context.Background(),
+	}
+
+	// 第一轮发现 102b 接不上 101a，回滚到 100
+	deposit.tick(context.Background())
+	business, err := db.Business.QueryBusinessByUuid(businessId)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), business.SyncHeight)
+
+	// 第二轮扫描替换链上的 101b、102b
+	deposit.tick(context.Background())
+	batch := <-businessChannels
+	require.NoError(t, deposit.handleBatch(batch))
+	deposit.batchDone()
+
+	deposits, err := db.Deposits.QueryDepositsByStatus(businessId, database.TxStatusUnSafe)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	require.Equal(t, depositHash, deposits[0].Hash)
+	business, err = db.Business.QueryBusinessByUuid(businessId)
+	require.NoError(t, err)
+	require.Equal(t, uint64(102), business.SyncHeight)
+}
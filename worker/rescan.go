@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+)
+
+var ErrRescanRange = errors.New("invalid rescan range")
+
+// Rescanner 重新处理指定区间的区块，分类和入库复用扫块的 classifyTransactions 和 Deposit.handleBatch；
+// 只补录之前没有记录或者没有识别出类型的交易，已经按某个类型记账的交易即使重新分类为其他类型也会被跳过；
+// 重扫可能在其他进程中与实时扫块同时运行，handleBatch 入库前对业务方加锁串行执行
+type Rescanner struct {
+	deposit *Deposit
+}
+
+func NewRescanner(ctx context.Context, cfg config.Config, db *database.DB, rpcClient syncclient.ChainSource) *Rescanner {
+	return &Rescanner{
+		deposit: &Deposit{
+			BaseSynchronizer: BaseSynchronizer{
+				headerBufferSize: cfg.ChainNode.BlocksStep,
+				rpcClient:        rpcClient,
+				blockBatch:       syncclient.NewBatchBlock(rpcClient, nil, big.NewInt(int64(cfg.ChainNode.Confirmations)), cfg.ChainNode.FetchParallelism),
+				database:         db,
+				addressIndex:     cache.InitAddressIndex(db),
+			},
+			confirms:    uint16(cfg.ChainNode.Confirmations),
+			resourceCtx: ctx,
+		},
+	}
+}
+
+// Rescan 重新处理 [from, to] 之间的区块，businessId 为空时处理所有业务方；to 不能超过已扫描的最新区块
+func (r *Rescanner) Rescan(businessId string, from uint64, to uint64) error {
+	db := r.deposit.database
+	latestBlock, err := db.Blocks.LatestBlocks()
+	if err != nil {
+		return err
+	}
+	if latestBlock == nil || from > to || to > latestBlock.Number.Uint64() {
+		return fmt.Errorf("%w: [%d, %d]", ErrRescanRange, from, to)
+	}
+	tip := latestBlock.Number.Uint64()
+
+	var businessList []database.Business
+	if businessId != "" {
+		business, err := db.Business.QueryBusinessByUuid(businessId)
+		if err != nil {
+			return err
+		}
+		businessList = append(businessList, *business)
+	} else {
+		businessList, err = db.Business.QueryBusinessList()
+		if err != nil {
+			return err
+		}
+	}
+	for _, business := range businessList {
+		if err := r.deposit.addressIndex.Refresh(business.BusinessUid); err != nil {
+			return err
+		}
+	}
+
+	step := r.deposit.headerBufferSize
+	if step == 0 {
+		step = 1
+	}
+	for start := from; start <= to; start += step {
+		if err := r.deposit.resourceCtx.Err(); err != nil {
+			return err
+		}
+		end := start + step - 1
+		if end > to {
+			end = to
+		}
+		if err := r.rescanRange(businessList, start, end, tip); err != nil {
+			log.Error("rescan blocks fail", "start", start, "end", end, "err", err)
+			return err
+		}
+	}
+	log.Info("rescan blocks success", "businessId", businessId, "from", from, "to", to)
+	return nil
+}
+
+func (r *Rescanner) rescanRange(businessList []database.Business, start uint64, end uint64, tip uint64) error {
+	headers, err := r.deposit.blockBatch.HeadersByRange(start, end)
+	if err != nil {
+		return err
+	}
+	blocks, err := r.deposit.blockBatch.Blocks(headers)
+	if err != nil {
+		return err
+	}
+	batch := make(map[string]*TransactionsChannel)
+	for _, business := range businessList {
+		var transactions []*Transaction
+		for i := range headers {
+			businessTransactions, err := r.deposit.classifyTransactions(business.BusinessUid, headers[i], blocks[i])
+			if err != nil {
+				return err
+			}
+			transactions = append(transactions, businessTransactions...)
+		}
+		transactions, err = r.unprocessed(business.BusinessUid, transactions)
+		if err != nil {
+			return err
+		}
+		log.Info("rescan business blocks", "businessId", business.BusinessUid, "start", start, "end", end, "txn", len(transactions))
+		if len(transactions) > 0 {
+			// 确认数按全局进度计算
+			batch[business.BusinessUid] = &TransactionsChannel{
+				BlockHeight:  tip,
+				Transactions: transactions,
+			}
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return r.deposit.handleBatch(batch)
+}
+
+// unprocessed 过滤掉已经记账的交易，只保留之前没有记录或者没有识别出类型的交易，与 handleBatch 计入余额的条件一致；
+// 已经按某个类型记账的交易重新分类后需要先撤销旧的余额，重扫不处理这种情况
+func (r *Rescanner) unprocessed(businessId string, transactions []*Transaction) ([]*Transaction, error) {
+	var hashes []string
+	for _, tx := range transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	txTypes, err := r.deposit.database.Transactions.QueryTransactionTypes(businessId, hashes)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Transaction
+	for _, tx := range transactions {
+		txType, ok := txTypes[tx.Hash]
+		if ok && (txType != "unknown" || tx.TxType == "unknown") {
+			continue
+		}
+		result = append(result, tx)
+	}
+	return result, nil
+}
@@ -3,30 +3,35 @@ package worker
 import (
 	"context"
 	"errors"
+	"github.com/0xshin-chan/multichain-sync-btc/common/cache"
 	"github.com/0xshin-chan/multichain-sync-btc/common/clock"
 	"github.com/0xshin-chan/multichain-sync-btc/database"
 	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
 	"github.com/ethereum/go-ethereum/log"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Vin 交易的输入，TxId 和 Vout 是被花费输出所在的交易和序号
 type Vin struct {
 	Address string
 	TxId    string
-	Vout    uint8
+	Vout    uint32
 	Amount  *big.Int
 }
 
 type Vout struct {
 	Address string
-	TxIndex uint8
+	TxIndex uint32
 	Amount  *big.Int
 }
 
 type Transaction struct {
 	BusinessId  string
+	BlockHash   string
 	BlockNumber *big.Int
 	Hash        string
 	TxFee       string
@@ -43,24 +48,29 @@ type TransactionsChannel struct {
 
 type BaseSynchronizer struct {
 	loopInterval     time.Duration
+	trigger          <-chan struct{}
 	headerBufferSize uint64
 	businessChannels chan map[string]*TransactionsChannel
-	rpcClient        *syncclient.WalletBtcAccountClient
+	rpcClient        syncclient.ChainSource
 	blockBatch       *syncclient.BatchBlock
 	database         *database.DB
+	addressIndex     *cache.AddressIndex
 	headers          []syncclient.BlockHeader
 	worker           *clock.LoopFn
+
+	// inFlight 已经发出、还没有处理完的批次，重组回滚前等待它们入库
+	inFlight *sync.WaitGroup
 }
 
 func (syncer *BaseSynchronizer) Start() error {
 	if syncer.worker != nil {
 		return errors.New("already started")
 	}
-	syncer.worker = clock.NewLoopFn(clock.SystemClock, syncer.tick, func() error {
+	syncer.worker = clock.NewTriggeredLoopFn(clock.SystemClock, syncer.tick, func() error {
 		log.Info("shutting down batch producer")
 		close(syncer.businessChannels)
 		return nil
-	}, syncer.loopInterval)
+	}, syncer.loopInterval, syncer.trigger)
 	return nil
 }
 
@@ -76,7 +86,13 @@ func (syncer *BaseSynchronizer) tick(_ context.Context) {
 		log.Info("retrying previous batch")
 	} else {
 		newHeaders, err := syncer.blockBatch.NextHeaders(syncer.headerBufferSize)
-		if err != nil {
+		if errors.Is(err, syncclient.ErrBatchBlockAndProviderMismatchedState) {
+			log.Warn("chain reorg detected, rolling back to common ancestor")
+			if err := syncer.handleReorg(); err != nil {
+				log.Error("failed to handle chain reorg", "err", err)
+			}
+			return
+		} else if err != nil {
 			log.Error("failed to fetch headers", "err", err)
 		} else if len(newHeaders) == 0 {
 			log.Warn("no new headers")
@@ -84,6 +100,9 @@ func (syncer *BaseSynchronizer) tick(_ context.Context) {
 			syncer.headers = newHeaders
 		}
 	}
+	if err := syncer.catchUp(); err != nil {
+		log.Error("failed to catch up business", "err", err)
+	}
 	err := syncer.processBatch(syncer.headers)
 	if err == nil {
 		syncer.headers = nil
@@ -99,6 +118,42 @@ func (syncer *BaseSynchronizer) processBatch(headers []syncclient.BlockHeader) e
 	businessTxChannel := make(map[string]*TransactionsChannel)
 	blockHeaders := make([]database.Blocks, len(headers))
 
+	blocks, err := syncer.blockBatch.Blocks(headers)
+	if err != nil {
+		return err
+	}
+	businessList, err := syncer.database.Business.QueryBusinessList()
+	if err != nil {
+		log.Error("failed to fetch business list", "err", err)
+		return err
+	}
+	// 还在补扫历史区块的业务方不参与实时扫块，追上进度后再加入
+	var tip uint64
+	if headers[0].Number.Sign() > 0 {
+		tip = headers[0].Number.Uint64() - 1
+	}
+	var (
+		liveBusinesses []database.Business
+		liveUids       []string
+	)
+	for _, business := range businessList {
+		if business.CatchingUp(tip) {
+			log.Info("business is catching up, skip live batch", "businessId", business.BusinessUid, "syncHeight", business.SyncHeight, "tip", tip)
+			continue
+		}
+		// 起始高度还没有扫到的业务方，等全局进度到达起始高度后再加入，中间跳过的区块由补扫处理
+		if business.StartPending(tip) {
+			log.Info("business start height not reached, skip live batch", "businessId", business.BusinessUid, "startHeight", business.StartHeight, "tip", tip)
+			continue
+		}
+		// 加载其他进程新生成的地址，之后的交易分类不再查询数据库
+		if err := syncer.addressIndex.Refresh(business.BusinessUid); err != nil {
+			log.Error("failed to refresh address index", "businessId", business.BusinessUid, "err", err)
+			return err
+		}
+		liveBusinesses = append(liveBusinesses, business)
+		liveUids = append(liveUids, business.BusinessUid)
+	}
 	for i := range headers {
 		log.Info("Sync block data", "height", headers[i].Number)
 		blockHeaders[i] = database.Blocks{
@@ -106,118 +161,18 @@ func (syncer *BaseSynchronizer) processBatch(headers []syncclient.BlockHeader) e
 			PrevHash:  headers[i].PrevHash,
 			Number:    headers[i].Number,
 			Timestamp: headers[i].Timestamp,
+			Source:    headers[i].Source,
 		}
 
-		txList, err := syncer.rpcClient.GetBlockByNumber(headers[i].Number)
-		if err != nil {
-			return err
-		}
-		businessList, err := syncer.database.Business.QueryBusinessList()
-		if err != nil {
-			log.Error("failed to fetch business list", "err", err)
-			return err
-		}
-		for _, business := range businessList {
-			var businessTransactions []*Transaction
-			for _, tx := range txList {
-				txItem := &Transaction{
-					BusinessId:  business.BusinessUid,
-					BlockNumber: headers[i].Number,
-					Hash:        tx.Hash,
-					TxFee:       tx.Fee,
-					TxType:      "unknown",
-				}
-				var toAddressList []string
-				var voutArray []Vout
-				var vinArray []Vin
-				for _, vout := range tx.Vout {
-					toAddressList = append(toAddressList, vout.Address)
-					voutItem := Vout{
-						Address: vout.Address,
-						TxIndex: uint8(vout.Index),
-						Amount:  big.NewInt(int64(vout.Amount)),
-					}
-					voutArray = append(voutArray, voutItem)
-				}
-				txItem.VoutList = voutArray
-
-				var (
-					existToAddress bool
-					toAddressType  uint8
-					isDeposit      bool = false
-					isWithdraw     bool = false
-					isCollection   bool = false
-					isToCold       bool = false
-					isToHot        bool = false
-				)
-				for index := range toAddressList {
-					existToAddress, toAddressType = syncer.database.Addresses.AddressExist(business.BusinessUid, toAddressList[index])
-					hotWalletAddress, errHot := syncer.database.Addresses.QueryHotWalletInfo(business.BusinessUid)
-					if errHot != nil {
-						log.Error("failed to fetch hot wallet address", "err", errHot)
-						return errHot
-					}
-					coldWalletAddress, errCold := syncer.database.Addresses.QueryColdWalletInfo(business.BusinessUid)
-					if errCold != nil {
-						log.Error("failed to fetch cold wallet address", "err", errCold)
-						return errCold
-					}
-					for _, txVin := range tx.Vin {
-						vinItem := Vin{
-							Address: txVin.Address,
-							TxId:    tx.Hash,
-							Vout:    uint8(txVin.Index),
-							Amount:  big.NewInt(int64(txVin.Amount)),
-						}
-						vinArray = append(vinArray, vinItem)
-						addressList := strings.Split(txVin.Address, "|")
-						for _, address := range addressList {
-							vinAddress, errQuery := syncer.database.Addresses.QueryAddressesByToAddress(business.BusinessUid, address)
-							if errQuery != nil {
-								log.Error("failed to fetch address", "err", errQuery)
-								return errQuery
-							}
-							if vinAddress == nil && existToAddress && toAddressType == 0 {
-								isDeposit = true
-							}
-							if existToAddress && toAddressType == 1 && vinAddress != nil {
-								isCollection = true
-							}
-							if address == hotWalletAddress.Address && !existToAddress {
-								isWithdraw = true
-							}
-							if existToAddress && toAddressType == 2 && address == hotWalletAddress.Address {
-								isToCold = true
-							}
-							if existToAddress && toAddressType == 1 && address == coldWalletAddress.Address {
-								isToHot = true
-							}
-						}
-					}
-				}
-
-				if isDeposit {
-					txItem.TxType = "deposit"
-				}
-				if isWithdraw { // 提现
-					txItem.TxType = "withdraw"
-				}
-				if isCollection { // 归集； 1: 代表热钱包地址
-					txItem.TxType = "collection"
-				}
-				if isToCold { // 热转冷；2 是冷钱包地址
-					txItem.TxType = "hot2cold"
-				}
-				if isToHot { // 冷转热；
-					txItem.TxType = "cold2hot"
-				}
-
-				businessTransactions = append(businessTransactions, txItem)
+		for _, business := range liveBusinesses {
+			businessTransactions, err := syncer.classifyTransactions(business.BusinessUid, headers[i], blocks[i])
+			if err != nil {
+				return err
 			}
 			if len(businessTransactions) > 0 {
 				if businessTxChannel[business.BusinessUid] == nil {
 					businessTxChannel[business.BusinessUid] = &TransactionsChannel{
-						BlockHeight: headers[i].Number.Uint64(),
+						BlockHeight:  headers[i].Number.Uint64(),
 						Transactions: businessTransactions,
 					}
 				} else {
@@ -228,7 +183,7 @@ func (syncer *BaseSynchronizer) processBatch(headers []syncclient.BlockHeader) e
 		}
 	}
 	if len(businessTxChannel) >= 0 {
-		syncer.businessChannels <- businessTxChannel
+		syncer.sendBatch(businessTxChannel)
 	}
 	if len(blockHeaders) > 0 {
 		log.Info("Store block headers success", "totalBlockHeader", len(blockHeaders))
@@ -236,5 +191,129 @@ func (syncer *BaseSynchronizer) processBatch(headers []syncclient.BlockHeader) e
 			return err
 		}
 	}
-	return nil
+	return syncer.database.Business.UpdateBusinessSyncHeight(liveUids, headers[len(headers)-1].Number.Uint64())
+}
+
+// sendBatch 把批次交给充值任务处理，处理完成后由 batchDone 标记
+func (syncer *BaseSynchronizer) sendBatch(batch map[string]*TransactionsChannel) {
+	syncer.inFlight.Add(1)
+	syncer.businessChannels <- batch
+}
+
+func (syncer *BaseSynchronizer) batchDone() {
+	syncer.inFlight.Done()
+}
+
+// classifyTransactions 按业务方的地址对区块中的交易分类
+func (syncer *BaseSynchronizer) classifyTransactions(businessId string, header syncclient.BlockHeader, txList []*utxo.TransactionList) ([]*Transaction, error) {
+	hotWalletAddress := addressOrEmpty(syncer.addressIndex.HotWallet(businessId))
+	coldWalletAddress := addressOrEmpty(syncer.addressIndex.ColdWallet(businessId))
+	var businessTransactions []*Transaction
+	for _, tx := range txList {
+		txItem := &Transaction{
+			BusinessId:  businessId,
+			BlockHash:   header.Hash,
+			BlockNumber: header.Number,
+			Hash:        tx.Hash,
+			TxFee:       tx.Fee,
+			TxType:      "unknown",
+		}
+		var toAddressList []string
+		var voutArray []Vout
+		var vinArray []Vin
+		for _, txVin := range tx.Vin {
+			vinArray = append(vinArray, Vin{
+				Address: txVin.Address,
+				TxId:    txVin.Hash,
+				Vout:    txVin.Index,
+				Amount:  big.NewInt(int64(txVin.Amount)),
+			})
+		}
+		txItem.VinList = vinArray
+		for _, vout := range tx.Vout {
+			toAddressList = append(toAddressList, vout.Address)
+			voutItem := Vout{
+				Address: vout.Address,
+				TxIndex: vout.Index,
+				Amount:  big.NewInt(int64(vout.Amount)),
+			}
+			voutArray = append(voutArray, voutItem)
+		}
+		txItem.VoutList = voutArray
+
+		var (
+			existToAddress bool
+			toAddressType  uint8
+			isDeposit      bool = false
+			isWithdraw     bool = false
+			isCollection   bool = false
+			isToCold       bool = false
+			isToHot        bool = false
+		)
+		for index := range toAddressList {
+			toAddress, errQuery := syncer.addressIndex.Lookup(businessId, toAddressList[index])
+			if errQuery != nil {
+				log.Error("failed to lookup address", "err", errQuery)
+				return nil, errQuery
+			}
+			existToAddress, toAddressType = false, 0
+			if toAddress != nil {
+				existToAddress, toAddressType = true, toAddress.AddressType
+			}
+			for _, txVin := range tx.Vin {
+				addressList := strings.Split(txVin.Address, "|")
+				for _, address := range addressList {
+					vinAddress, errQuery := syncer.addressIndex.Lookup(businessId, address)
+					if errQuery != nil {
+						log.Error("failed to lookup address", "err", errQuery)
+						return nil, errQuery
+					}
+					if vinAddress == nil && existToAddress && toAddressType == 0 {
+						isDeposit = true
+					}
+					if existToAddress && toAddressType == 1 && vinAddress != nil {
+						isCollection = true
+					}
+					if address == "" {
+						continue
+					}
+					if address == hotWalletAddress && !existToAddress {
+						isWithdraw = true
+					}
+					if existToAddress && toAddressType == 2 && address == hotWalletAddress {
+						isToCold = true
+					}
+					if existToAddress && toAddressType == 1 && address == coldWalletAddress {
+						isToHot = true
+					}
+				}
+			}
+		}
+
+		if isDeposit {
+			txItem.TxType = "deposit"
+		}
+		if isWithdraw { // 提现
+			txItem.TxType = "withdraw"
+		}
+		if isCollection { // 归集； 1: 代表热钱包地址
+			txItem.TxType = "collection"
+		}
+		if isToCold { // 热转冷；2 是冷钱包地址
+			txItem.TxType = "hot2cold"
+		}
+		if isToHot { // 冷转热；
+			txItem.TxType = "cold2hot"
+		}
+
+		businessTransactions = append(businessTransactions, txItem)
+	}
+	return businessTransactions, nil
+}
+
+func addressOrEmpty(address *database.Addresses) string {
+	if address == nil {
+		return ""
+	}
+	return address.Address
 }
@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/chaincfg"
+	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/rbf"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/syncclient/utxo"
+)
+
+// droppedGracePeriod 刚广播的交易可能还没有传播到上游节点，查不到时先不认为被丢弃
+const droppedGracePeriod = 10 * time.Minute
+
+// WithdrawTracker 跟踪已广播的提现，上链后记录确认区块，
+// 超时未确认的标记为 stuck 并按配置自动构建 RBF 替换交易，从内存池消失的标记为 dropped
+type WithdrawTracker struct {
+	chainSource    syncclient.ChainSource
+	db             *database.DB
+	bumper         *rbf.Bumper
+	stuckTimeout   time.Duration
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+	ticker         *time.Ticker
+}
+
+// NewWithdrawTracker chainSource 用于查询提现交易、确认区块和自动加速时的网络费率，
+// txBuilder 只在开启自动加速时用于构建替换交易，可以为 nil
+func NewWithdrawTracker(cfg *config.Config, db *database.DB, params *chaincfg.Params, txBuilder rbf.TxBuilder, chainSource syncclient.ChainSource, shutdown context.CancelCauseFunc) (*WithdrawTracker, error) {
+	var bumper *rbf.Bumper
+	if cfg.ChainNode.RbfAutoBump && params.ReplaceByFee {
+		if txBuilder == nil {
+			return nil, errors.New("rbf auto bump requires the chain account rpc to build replacement transactions")
+		}
+		bumper = rbf.NewBumper(db, params, txBuilder, chainSource, cfg.ChainNode.RbfMaxFeeRate)
+	}
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &WithdrawTracker{
+		chainSource:    chainSource,
+		db:             db,
+		bumper:         bumper,
+		stuckTimeout:   cfg.ChainNode.WithdrawStuckTimeout,
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in withdraw tracker: %w", err))
+		}},
+		ticker: time.NewTicker(cfg.ChainNode.WorkerInterval),
+	}, nil
+}
+
+func (t *WithdrawTracker) Close() error {
+	var result error
+	t.resourceCancel()
+	t.ticker.Stop()
+	log.Info("stop withdraw tracker...")
+	if err := t.tasks.Wait(); err != nil {
+		result = errors.Join(result, fmt.Errorf("failed to await withdraw tracker %w", err))
+		return result
+	}
+	log.Info("stop withdraw tracker success")
+	return nil
+}
+
+func (t *WithdrawTracker) Start() error {
+	log.Info("start withdraw tracker......")
+	t.tasks.Go(func() error {
+		for {
+			select {
+			case <-t.ticker.C:
+				businessList, err := t.db.Business.QueryBusinessList()
+				if err != nil {
+					log.Error("query business list fail", "err", err)
+					continue
+				}
+				for _, business := range businessList {
+					if err := t.trackWithdraws(business.BusinessUid); err != nil {
+						log.Error("track withdraws fail", "businessId", business.BusinessUid, "err", err)
+					}
+				}
+			case <-t.resourceCtx.Done():
+				log.Info("stop withdraw tracker in worker")
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+func (t *WithdrawTracker) trackWithdraws(businessId string) error {
+	withdrawsList, err := t.db.Withdraws.QueryTrackWithdraws(businessId)
+	if err != nil {
+		return err
+	}
+
+	var (
+		confirmedList []database.Withdraws
+		stuckList     []database.Withdraws
+		droppedList   []database.Withdraws
+	)
+	now := time.Now()
+	for _, withdraw := range withdrawsList {
+		sentAt := time.Unix(int64(withdraw.SentAt), 0)
+		tx, err := t.chainSource.GetTransactionByHash(withdraw.Hash)
+		if errors.Is(err, syncclient.ErrTxNotFound) || (err == nil && tx.Status == utxo.TxStatus_NotFound) {
+			if now.Sub(sentAt) > droppedGracePeriod {
+				log.Warn("withdraw dropped from mempool", "businessId", businessId, "transactionId", withdraw.Guid, "hash", withdraw.Hash)
+				droppedList = append(droppedList, withdraw)
+			}
+			continue
+		} else if err != nil {
+			log.Error("get withdraw tx fail", "hash", withdraw.Hash, "err", err)
+			continue
+		}
+
+		height, ok := new(big.Int).SetString(tx.Height, 10)
+		if ok && height.Sign() > 0 {
+			header, err := t.chainSource.GetBlockHeader(height)
+			if err != nil {
+				log.Error("get withdraw block header fail", "hash", withdraw.Hash, "height", height, "err", err)
+				continue
+			}
+			log.Info("withdraw confirmed", "businessId", businessId, "transactionId", withdraw.Guid, "hash", withdraw.Hash, "blockNumber", height)
+			withdraw.BlockHash = header.Hash
+			withdraw.BlockNumber = height
+			confirmedList = append(confirmedList, withdraw)
+			continue
+		}
+
+		if withdraw.Status != database.TxStatusStuck && now.Sub(sentAt) > t.stuckTimeout {
+			log.Warn("withdraw stuck", "businessId", businessId, "transactionId", withdraw.Guid, "hash", withdraw.Hash, "sentAt", sentAt)
+			stuckList = append(stuckList, withdraw)
+		}
+	}
+	if len(confirmedList) == 0 && len(stuckList) == 0 && len(droppedList) == 0 {
+		return nil
+	}
+
+	if err := t.db.Transaction(func(tx *database.DB) error {
+		if len(confirmedList) > 0 {
+			if err := tx.Withdraws.UpdateWithdrawsOnChain(businessId, confirmedList); err != nil {
+				return err
+			}
+			for _, withdraw := range confirmedList {
+				if err := tx.Vins.SpendLockedVins(businessId, withdraw.Hash, withdraw.BlockNumber); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusStuck, stuckList); err != nil {
+			return err
+		}
+		if err := tx.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusDropped, droppedList); err != nil {
+			return err
+		}
+		// 同组所有版本都被丢弃或失败后释放锁定的 utxo
+		if len(droppedList) > 0 {
+			if err := tx.Vins.UnlockFailedWithdrawVins(businessId); err != nil {
+				return err
+			}
+		}
+		// 同一提现的原始交易和替换交易互相冲突，其中一个上链后其他版本置为已替换
+		for _, withdraw := range confirmedList {
+			if err := tx.Withdraws.UpdateWithdrawsReplaced(businessId, withdraw.Hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if t.bumper != nil {
+		for _, withdraw := range stuckList {
+			t.autoBumpFee(businessId, withdraw)
+		}
+	}
+	return nil
+}
+
+// autoBumpFee 刚被标记为 stuck 的提现是同组最新的版本时，按当前网络费率构建替换交易，推送业务方签名；
+// 已经有更新的替换交易（包括失败的）时不再自动加速，避免反复生成被拒绝的替换交易
+func (t *WithdrawTracker) autoBumpFee(businessId string, withdraw database.Withdraws) {
+	originGuid := withdraw.ReplaceGuid
+	if originGuid == "" {
+		originGuid = withdraw.Guid.String()
+	}
+	replaceWithdraws, err := t.db.Withdraws.QueryReplaceWithdraws(businessId, originGuid)
+	if err != nil {
+		log.Error("query replace withdraws fail", "transactionId", withdraw.Guid, "err", err)
+		return
+	}
+	for _, item := range replaceWithdraws {
+		if item.Guid != withdraw.Guid && item.ReplaceGuid != "" && item.Timestamp >= withdraw.Timestamp {
+			return
+		}
+	}
+
+	result, err := t.bumper.BumpFee(businessId, withdraw.Guid.String(), 0, database.TxStatusReplaceWaitSign)
+	if err != nil {
+		log.Warn("auto bump withdraw fee fail", "businessId", businessId, "transactionId", withdraw.Guid, "err", err)
+		return
+	}
+	log.Info("auto bump withdraw fee", "businessId", businessId, "transactionId", withdraw.Guid, "replaceTransactionId", result.Withdraw.Guid, "feeRate", result.Estimate.FeeRate)
+}
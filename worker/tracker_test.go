@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xshin-chan/multichain-sync-btc/database"
+	"github.com/0xshin-chan/multichain-sync-btc/database/dynamic"
+)
+
+func storeTestWithdraw(t *testing.T, db *database.DB, businessId string, status database.TxStatus, replaceGuid string) *database.Withdraws {
+	withdraw := &database.Withdraws{
+		Guid:        uuid.New(),
+		BlockNumber: big.NewInt(0),
+		Hash:        "withdraw-" + uuid.New().String(),
+		Fee:         big.NewInt(0),
+		LockTime:    big.NewInt(0),
+		Status:      status,
+		ReplaceGuid: replaceGuid,
+		Timestamp:   uint64(time.Now().Unix()),
+	}
+	require.NoError(t, db.Withdraws.StoreWithdraws(businessId, withdraw))
+	return withdraw
+}
+
+func storeLockedVin(t *testing.T, db *database.DB, businessId string, lockTxId string) {
+	vin := database.Vins{
+		GUID:             uuid.New(),
+		Address:          "hot-" + businessId,
+		TxId:             "prev-" + uuid.New().String(),
+		Amount:           big.NewInt(10_000),
+		SpendBlockHeight: big.NewInt(0),
+		Timestamp:        uint64(time.Now().Unix()),
+	}
+	require.NoError(t, db.Vins.StoreVins(businessId, []database.Vins{vin}))
+	require.NoError(t, db.Vins.LockVins(businessId, lockTxId, []uuid.UUID{vin.GUID}))
+}
+
+func lockedVinCount(t *testing.T, db *database.DB, businessId string, lockTxId string) int {
+	vins, err := db.Vins.QueryVinsByLockTxId(businessId, lockTxId)
+	require.NoError(t, err)
+	return len(vins)
+}
+
+func TestUnlockDroppedWithdrawVins(t *testing.T) {
+	db := testDB(t)
+	businessId := strings.ReplaceAll(uuid.New().String(), "-", "")
+	dynamic.CreateTableFromTemplate(businessId, db)
+
+	// 广播的替换交易把原始提现挤出内存池，原始提现被标记为丢弃，替换交易仍在等待确认
+	replacedOrigin := storeTestWithdraw(t, db, businessId, database.TxStatusDropped, "")
+	replacement := storeTestWithdraw(t, db, businessId, database.TxStatusSent, replacedOrigin.Guid.String())
+	storeLockedVin(t, db, businessId, replacedOrigin.Guid.String())
+
+	// 没有替换交易的提现被丢弃
+	droppedOrigin := storeTestWithdraw(t, db, businessId, database.TxStatusDropped, "")
+	storeLockedVin(t, db, businessId, droppedOrigin.Guid.String())
+
+	// 原始提现被丢弃，替换交易失败
+	failedOrigin := storeTestWithdraw(t, db, businessId, database.TxStatusDropped, "")
+	storeTestWithdraw(t, db, businessId, database.TxStatusFail, failedOrigin.Guid.String())
+	storeLockedVin(t, db, businessId, failedOrigin.Guid.String())
+
+	require.NoError(t, db.Vins.UnlockFailedWithdrawVins(businessId))
+	require.Equal(t, 1, lockedVinCount(t, db, businessId, replacedOrigin.Guid.String()))
+	require.Equal(t, 0, lockedVinCount(t, db, businessId, droppedOrigin.Guid.String()))
+	require.Equal(t, 0, lockedVinCount(t, db, businessId, failedOrigin.Guid.String()))
+
+	// 替换交易也被丢弃后整组释放
+	require.NoError(t, db.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusDropped, []database.Withdraws{*replacement}))
+	require.NoError(t, db.Vins.UnlockFailedWithdrawVins(businessId))
+	require.Equal(t, 0, lockedVinCount(t, db, businessId, replacedOrigin.Guid.String()))
+}
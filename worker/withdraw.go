@@ -17,21 +17,23 @@ import (
 )
 
 type Withdraw struct {
-	rpcClient      *syncclient.WalletBtcAccountClient
-	db             *database.DB
-	resourceCtx    context.Context
-	resourceCancel context.CancelFunc
-	tasks          tasks.Group
-	ticker         *time.Ticker
+	rpcClient       syncclient.ChainSource
+	db              *database.DB
+	utxoLockTimeout time.Duration
+	resourceCtx     context.Context
+	resourceCancel  context.CancelFunc
+	tasks           tasks.Group
+	ticker          *time.Ticker
 }
 
-func NewWithdraw(cfg *config.Config, db *database.DB, rpcClient *syncclient.WalletBtcAccountClient, shutdown context.CancelCauseFunc) (*Withdraw, error) {
+func NewWithdraw(cfg *config.Config, db *database.DB, rpcClient syncclient.ChainSource, shutdown context.CancelCauseFunc) (*Withdraw, error) {
 	resCtx, resCancel := context.WithCancel(context.Background())
 	return &Withdraw{
-		rpcClient:      rpcClient,
-		db:             db,
-		resourceCtx:    resCtx,
-		resourceCancel: resCancel,
+		rpcClient:       rpcClient,
+		db:              db,
+		utxoLockTimeout: cfg.ChainNode.UtxoLockTimeout,
+		resourceCtx:     resCtx,
+		resourceCancel:  resCancel,
 		tasks: tasks.Group{HandleCrit: func(err error) {
 			shutdown(fmt.Errorf("critical error in withdraw: %w", err))
 		}},
@@ -52,7 +54,7 @@ func (w *Withdraw) Close() error {
 	return nil
 }
 
-func (w *Withdraw) Start() {
+func (w *Withdraw) Start() error {
 	log.Info("start withdraw...")
 	w.tasks.Go(func() error {
 		for {
@@ -64,6 +66,9 @@ func (w *Withdraw) Start() {
 					continue
 				}
 				for _, business := range businessList {
+					if err := w.releaseVins(business.BusinessUid); err != nil {
+						log.Error("release withdraw vins fail", "businessId", business.BusinessUid, "err", err)
+					}
 					unSendTransactionList, err := w.db.Withdraws.UnSendWithdrawsList(business.BusinessUid)
 					if err != nil {
 						log.Error("query un send withdraw list fail", "err", err)
@@ -73,14 +78,39 @@ func (w *Withdraw) Start() {
 						log.Error("withdraw start", "businessId", business, "unSendTransactionList", "is null")
 						continue
 					}
-					var balanceList []database.Balances
+					var (
+						balanceList  []database.Balances
+						sentList     []database.Withdraws
+						rejectedList []database.Withdraws
+					)
 					for _, unSendTransaction := range unSendTransactionList {
+						txHash, err := w.rpcClient.SendTx(unSendTransaction.TxSignHex)
+						if errors.Is(err, syncclient.ErrTxRejected) {
+							log.Error("withdraw tx rejected", "transactionId", unSendTransaction.Guid, "err", err)
+							rejectedList = append(rejectedList, unSendTransaction)
+							continue
+						} else if err != nil && !errors.Is(err, syncclient.ErrTxAlreadyInChain) {
+							log.Error("send tx fail, retry next round", "transactionId", unSendTransaction.Guid, "err", err)
+							continue
+						}
+						if txHash == "" {
+							log.Error("send tx returned empty hash, retry next round", "transactionId", unSendTransaction.Guid, "err", err)
+							continue
+						}
+						log.Info("send withdraw tx success", "transactionId", unSendTransaction.Guid, "txHash", txHash)
+						unSendTransaction.Hash = txHash
+						unSendTransaction.Status = database.TxStatusSent
+						sentList = append(sentList, unSendTransaction)
+
 						childTxList, err := w.db.ChildTxs.QueryChildTxnByTxId(business.BusinessUid, unSendTransaction.Guid.String())
 						if err != nil {
 							log.Error("query child txn fail", "err", err)
 							return err
 						}
 						for _, childTx := range childTxList {
+							if childTx.TxType != "withdraw" {
+								continue
+							}
 							lockBalance, _ := new(big.Int).SetString(childTx.Amount, 10)
 							balanceItem := database.Balances{
 								Address: childTx.FromAddress,
@@ -88,14 +118,9 @@ func (w *Withdraw) Start() {
 							}
 							balanceList = append(balanceList, balanceItem)
 						}
-						txHash, err := w.rpcClient.SendTx(unSendTransaction.TxSignHex)
-						if err != nil {
-							log.Error("send tx fail", "err", err)
-							continue
-						} else {
-							unSendTransaction.Hash = txHash
-							unSendTransaction.Status = database.TxStatusSent
-						}
+					}
+					if len(sentList) == 0 && len(rejectedList) == 0 {
+						continue
 					}
 
 					retryStrategy := &retry.ExponentialStrategy{Min: 1000, Max: 20_000, MaxJitter: 250}
@@ -109,12 +134,14 @@ func (w *Withdraw) Start() {
 								}
 
 							}
-							if len(unSendTransactionList) > 0 {
-								err = w.db.Withdraws.UpdateWithdrawStatus(business.BusinessUid, database.TxStatusSent, unSendTransactionList)
-								if err != nil {
-									log.Error("update withdraw status fail", "err", err)
-									return err
-								}
+							if err := tx.Withdraws.UpdateWithdrawsSent(business.BusinessUid, sentList); err != nil {
+								log.Error("update withdraw status fail", "err", err)
+								return err
+							}
+							// 被拒绝的提现置为失败，锁定的 utxo 在下一轮释放
+							if err := tx.Withdraws.UpdateWithdrawStatusByGuids(business.BusinessUid, database.TxStatusFail, rejectedList); err != nil {
+								log.Error("update withdraw status fail", "err", err)
+								return err
 							}
 							return nil
 						}); err != nil {
@@ -132,4 +159,23 @@ func (w *Withdraw) Start() {
 			}
 		}
 	})
+	return nil
+}
+
+// releaseVins 超时未广播的提现置为失败，并释放所有失败提现锁定的 utxo
+func (w *Withdraw) releaseVins(businessId string) error {
+	before := uint64(time.Now().Add(-w.utxoLockTimeout).Unix())
+	expiredWithdraws, err := w.db.Withdraws.QueryExpiredWithdraws(businessId, before)
+	if err != nil {
+		return err
+	}
+	if len(expiredWithdraws) > 0 {
+		log.Warn("withdraws expired", "businessId", businessId, "count", len(expiredWithdraws))
+	}
+	return w.db.Transaction(func(tx *database.DB) error {
+		if err := tx.Withdraws.UpdateWithdrawStatusByGuids(businessId, database.TxStatusFail, expiredWithdraws); err != nil {
+			return err
+		}
+		return tx.Vins.UnlockFailedWithdrawVins(businessId)
+	})
 }
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/0xshin-chan/multichain-sync-btc/common/tasks"
+	"github.com/0xshin-chan/multichain-sync-btc/config"
+	"github.com/0xshin-chan/multichain-sync-btc/rpcclient/zmqclient"
+)
+
+const zmqReconnectDelay = 5 * time.Second
+
+// ZmqListener 订阅 bitcoind 的 zmqpubhashblock / zmqpubrawtx，收到新区块时立即唤醒扫块，收到新交易时唤醒内存池充值检测；
+// 连接断开或超过 quietTimeout 没有消息时重连，期间扫块和内存池检测仍按原来的间隔轮询
+type ZmqListener struct {
+	subscriptions  map[string][]string
+	quietTimeout   time.Duration
+	blocks         chan struct{}
+	txs            chan struct{}
+	resourceCtx    context.Context
+	resourceCancel context.CancelFunc
+	tasks          tasks.Group
+}
+
+func NewZmqListener(cfg *config.Config, shutdown context.CancelCauseFunc) *ZmqListener {
+	// bitcoind 的两个 topic 可以配置在同一个地址上，同一地址只建立一个连接
+	subscriptions := make(map[string][]string)
+	if cfg.ChainNode.ZmqBlockAddr != "" {
+		subscriptions[cfg.ChainNode.ZmqBlockAddr] = append(subscriptions[cfg.ChainNode.ZmqBlockAddr], zmqclient.TopicHashBlock)
+	}
+	if cfg.ChainNode.ZmqTxAddr != "" {
+		subscriptions[cfg.ChainNode.ZmqTxAddr] = append(subscriptions[cfg.ChainNode.ZmqTxAddr], zmqclient.TopicRawTx)
+	}
+	resCtx, resCancel := context.WithCancel(context.Background())
+	return &ZmqListener{
+		subscriptions:  subscriptions,
+		quietTimeout:   cfg.ChainNode.ZmqQuietTimeout,
+		blocks:         make(chan struct{}, 1),
+		txs:            make(chan struct{}, 1),
+		resourceCtx:    resCtx,
+		resourceCancel: resCancel,
+		tasks: tasks.Group{HandleCrit: func(err error) {
+			shutdown(fmt.Errorf("critical error in zmq listener: %w", err))
+		}},
+	}
+}
+
+// Blocks 收到新区块时发出信号，处理不及时的多个信号合并为一个
+func (z *ZmqListener) Blocks() <-chan struct{} {
+	return z.blocks
+}
+
+// Txs 收到新交易时发出信号，处理不及时的多个信号合并为一个
+func (z *ZmqListener) Txs() <-chan struct{} {
+	return z.txs
+}
+
+func (z *ZmqListener) Start() error {
+	for addr, topics := range z.subscriptions {
+		log.Info("start zmq listener", "addr", addr, "topics", topics)
+		z.tasks.Go(func() error {
+			for {
+				if err := z.listen(addr, topics); err != nil && z.resourceCtx.Err() == nil {
+					log.Warn("zmq subscription interrupted, fall back to polling until reconnected", "addr", addr, "err", err)
+				}
+				select {
+				case <-z.resourceCtx.Done():
+					log.Info("stop zmq listener in worker", "addr", addr)
+					return nil
+				case <-time.After(zmqReconnectDelay):
+				}
+			}
+		})
+	}
+	return nil
+}
+
+func (z *ZmqListener) Close() error {
+	z.resourceCancel()
+	if err := z.tasks.Wait(); err != nil {
+		return fmt.Errorf("failed to await zmq listener %w", err)
+	}
+	log.Info("stop zmq listener success")
+	return nil
+}
+
+func (z *ZmqListener) listen(addr string, topics []string) error {
+	sub, err := zmqclient.Dial(z.resourceCtx, addr, topics...)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	sequences := make(map[string]uint32)
+	for {
+		message, err := sub.Recv(z.quietTimeout)
+		if err != nil {
+			return err
+		}
+		// bitcoind 每个 topic 的序号连续递增，出现缺口说明有消息丢失，由轮询兜底
+		if last, ok := sequences[message.Topic]; ok && message.Sequence != last+1 {
+			log.Warn("zmq message sequence gap", "topic", message.Topic, "last", last, "sequence", message.Sequence)
+		}
+		sequences[message.Topic] = message.Sequence
+		switch message.Topic {
+		case zmqclient.TopicHashBlock:
+			log.Info("zmq new block", "hash", hex.EncodeToString(message.Body))
+			wake(z.blocks)
+		case zmqclient.TopicRawTx:
+			wake(z.txs)
+		}
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}